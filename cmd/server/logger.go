@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io"
+	"log"
+	"weight-tracker/pkg/config"
+)
+
+// the log levels of the config, from the most to the least verbose
+var logLevels = map[string]int{
+	config.LogDebug: 0,
+	config.LogInfo:  1,
+	config.LogWarn:  2,
+	config.LogError: 3,
+}
+
+// logger writes the log lines of the server that are at or above the log level
+// of the config, the same level decides which requests gin logs
+type logger struct {
+	level int
+	out   *log.Logger
+}
+
+func newLogger(level string, out io.Writer) *logger {
+	return &logger{
+		level: logLevels[level],
+		out:   log.New(out, "", log.LstdFlags),
+	}
+}
+
+// enabled tells if lines of the level are written
+func (l *logger) enabled(level string) bool {
+	return logLevels[level] >= l.level
+}
+
+func (l *logger) Infof(format string, args ...interface{}) {
+	l.printf(config.LogInfo, format, args...)
+}
+
+func (l *logger) Warnf(format string, args ...interface{}) {
+	l.printf(config.LogWarn, format, args...)
+}
+
+func (l *logger) Errorf(format string, args ...interface{}) {
+	l.printf(config.LogError, format, args...)
+}
+
+func (l *logger) printf(level, format string, args ...interface{}) {
+	if l.enabled(level) {
+		l.out.Printf(level+": "+format, args...)
+	}
+}
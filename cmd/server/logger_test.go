@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"weight-tracker/pkg/config"
+)
+
+func TestLoggerLevels(t *testing.T) {
+	tests := []struct {
+		name       string
+		level      string
+		want_lines []string
+	}{
+		{
+			name:       "should write every line at debug",
+			level:      config.LogDebug,
+			want_lines: []string{"info: purged 2 deleted users", "warn: keeping data in memory", "error: purging deleted users failed"},
+		}, {
+			name:       "should write every line at info",
+			level:      config.LogInfo,
+			want_lines: []string{"info: purged 2 deleted users", "warn: keeping data in memory", "error: purging deleted users failed"},
+		}, {
+			name:       "should leave out info lines at warn",
+			level:      config.LogWarn,
+			want_lines: []string{"warn: keeping data in memory", "error: purging deleted users failed"},
+		}, {
+			name:       "should only write errors at error",
+			level:      config.LogError,
+			want_lines: []string{"error: purging deleted users failed"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			logger := newLogger(test.level, &out)
+
+			logger.Infof("purged %d deleted users", 2)
+			logger.Warnf("keeping data in memory")
+			logger.Errorf("purging deleted users failed")
+
+			lines := []string{}
+
+			// leave out the date and time in front of every line
+			for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+				if fields := strings.SplitN(line, " ", 3); len(fields) == 3 {
+					lines = append(lines, fields[2])
+				}
+			}
+
+			if !reflect.DeepEqual(lines, test.want_lines) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, lines, test.want_lines)
+			}
+		})
+	}
+}
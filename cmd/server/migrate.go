@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"weight-tracker/pkg/config"
+	"weight-tracker/pkg/repository"
+)
+
+const migrateUsage = "usage: weight-tracker migrate [flags] up|down|goto N|version|force N"
+
+// runMigrate runs the migrate command, e.g. weight-tracker migrate goto 3. It
+// takes the same flags, environment and config file as the server.
+func runMigrate(args []string) error {
+	cfg, command, err := config.LoadMigrate(args, os.Getenv)
+
+	if err != nil {
+		return err
+	}
+
+	if len(command) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	migrator, err := repository.NewMigrator(cfg.Migrations.Dir, cfg.DatabaseURL)
+
+	if err != nil {
+		return err
+	}
+
+	defer migrator.Close()
+
+	switch {
+	case command[0] == "up" && len(command) == 1:
+		err = migrator.Up()
+	case command[0] == "down" && len(command) == 1:
+		err = migrator.Down()
+	case command[0] == "goto" && len(command) == 2:
+		var version uint64
+
+		if version, err = strconv.ParseUint(command[1], 10, 0); err != nil {
+			return fmt.Errorf("migrate - goto needs a version, got %q", command[1])
+		}
+
+		err = migrator.Goto(uint(version))
+	case command[0] == "force" && len(command) == 2:
+		var version int
+
+		// -1 marks the database as not migrated at all
+		if version, err = strconv.Atoi(command[1]); err != nil || version < -1 {
+			return fmt.Errorf("migrate - force needs a version, got %q", command[1])
+		}
+
+		err = migrator.Force(version)
+	case command[0] == "version" && len(command) == 1:
+		// the version is printed below
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	version, dirty, err := migrator.Version()
+
+	if err != nil {
+		return err
+	}
+
+	if dirty {
+		fmt.Printf("version %d (dirty)\n", version)
+	} else {
+		fmt.Printf("version %d\n", version)
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"weight-tracker/pkg/api"
+	"weight-tracker/pkg/config"
+)
+
+const promoteUsage = "usage: weight-tracker promote [flags] EMAIL..."
+
+// runPromote runs the promote command, e.g. weight-tracker promote admin@email.com.
+// Sign up only creates members, this makes the first admins of a deployment. It
+// takes the same flags, environment and config file as the server.
+func runPromote(args []string) error {
+	cfg, emails, err := config.LoadPromote(args, os.Getenv)
+
+	if err != nil {
+		return err
+	}
+
+	if len(emails) == 0 {
+		return errors.New(promoteUsage)
+	}
+
+	storage, db, err := setupStorage(cfg.DatabaseURL)
+
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	accessService := api.NewAccessService(storage)
+
+	for _, email := range emails {
+		user, err := accessService.Promote(context.Background(), email)
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("user %d (%s) is now an admin\n", user.ID, user.Email)
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+	"weight-tracker/pkg/api"
+	"weight-tracker/pkg/repository"
+)
+
+func TestRunPromote(t *testing.T) {
+	databaseURL := repository.SQLiteScheme + filepath.Join(t.TempDir(), "weight-tracker.db")
+	storage, db, err := setupStorage(databaseURL)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	if err = storage.RunMigrations("", databaseURL); err != nil {
+		t.Fatal(err)
+	}
+
+	// sign up creates members only
+	userID, err := api.NewUserService(storage).New(context.Background(), api.NewUserRequest{
+		Name: "rabbit", DateOfBirth: api.DateOf(time.Date(1992, 5, 17, 0, 0, 0, 0, time.UTC)), Height: 170,
+		Sex: "female", ActivityLevel: 2, WeightGoal: "maintain", Email: "rabbit@email.com", Password: "correct horse",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		args       []string
+		want_error error
+	}{
+		{
+			name:       "should need an email",
+			args:       []string{"-database-url", databaseURL},
+			want_error: errors.New(promoteUsage),
+		}, {
+			name:       "should return an error when no user has the email",
+			args:       []string{"-database-url", databaseURL, "nobody@email.com"},
+			want_error: api.NotFoundError("access service - no user has the email nobody@email.com"),
+		}, {
+			name: "should make the user with the email an admin",
+			args: []string{"-database-url", databaseURL, "rabbit@email.com"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := runPromote(test.args)
+
+			if !reflect.DeepEqual(err, test.want_error) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want_error)
+			}
+		})
+	}
+
+	user, err := storage.GetUser(context.Background(), userID)
+
+	if err != nil || user.Role != api.RoleAdmin {
+		t.Errorf("got: %v %v, wanted: %v", user.Role, err, api.RoleAdmin)
+	}
+}
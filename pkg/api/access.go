@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"strings"
+)
+
+// roles a user can have. Members only see themselves, coaches can also read
+// the data of their clients and admins can manage everyone.
+const (
+	RoleMember = "member"
+	RoleCoach  = "coach"
+	RoleAdmin  = "admin"
+)
+
+// Access is what an actor wants to do with the data of a user
+type Access string
+
+const (
+	ReadAccess   Access = "read"
+	ManageAccess Access = "manage"
+)
+
+// AccessService contains the methods of the access service
+type AccessService interface {
+	Allowed(ctx context.Context, actor User, userID int, access Access) (bool, error)
+	VisibleUsers(ctx context.Context, actor User) ([]User, error)
+	UpdateRole(ctx context.Context, request UpdateRoleRequest) (User, error)
+	Promote(ctx context.Context, email string) (User, error)
+	AddClient(ctx context.Context, request ClientRequest) error
+	RemoveClient(ctx context.Context, request ClientRequest) error
+	Clients(ctx context.Context, coachID int) ([]User, error)
+}
+
+// AccessRepository is what lets the access service look up roles and coach-client relationships
+type AccessRepository interface {
+	GetUser(ctx context.Context, userID int) (User, error)
+	GetUsers(ctx context.Context) ([]User, error)
+	GetUserByEmail(ctx context.Context, userEmail string) (User, error)
+	UpdateRole(ctx context.Context, userID int, role string) (User, error)
+	IsCoachOf(ctx context.Context, coachID, clientID int) (bool, error)
+	AddClient(ctx context.Context, coachID, clientID int) error
+	RemoveClient(ctx context.Context, coachID, clientID int) (removed bool, err error)
+	GetClients(ctx context.Context, coachID int) ([]User, error)
+}
+
+type accessService struct {
+	storage AccessRepository
+}
+
+func NewAccessService(accessRepo AccessRepository) AccessService {
+	return &accessService{
+		storage: accessRepo,
+	}
+}
+
+// Allowed tells whether the actor may access the data of the user with the given id
+func (a *accessService) Allowed(ctx context.Context, actor User, userID int, access Access) (bool, error) {
+	if actor.ID == 0 {
+		return false, nil
+	}
+
+	if actor.ID == userID || actor.Role == RoleAdmin {
+		return true, nil
+	}
+
+	if actor.Role == RoleCoach && access == ReadAccess {
+		return a.storage.IsCoachOf(ctx, actor.ID, userID)
+	}
+
+	return false, nil
+}
+
+// VisibleUsers returns every user for admins, coaches get themselves and their
+// clients and members only themselves
+func (a *accessService) VisibleUsers(ctx context.Context, actor User) ([]User, error) {
+	var users []User
+	var err error
+
+	switch actor.Role {
+	case RoleAdmin:
+		users, err = a.storage.GetUsers(ctx)
+	case RoleCoach:
+		users, err = a.storage.GetClients(ctx, actor.ID)
+		users = append([]User{actor}, users...)
+	default:
+		users = []User{actor}
+	}
+
+	if err != nil {
+		return []User{}, err
+	}
+
+	for i := range users {
+		users[i] = users[i].inPreferredUnits()
+	}
+
+	return users, nil
+}
+
+func (a *accessService) UpdateRole(ctx context.Context, request UpdateRoleRequest) (User, error) {
+	switch request.Role {
+	case RoleMember, RoleCoach, RoleAdmin:
+	default:
+		return User{}, ValidationError("role", "access service - role must be member, coach or admin")
+	}
+
+	user, err := a.storage.UpdateRole(ctx, request.UserID, request.Role)
+
+	if err != nil {
+		return User{}, err
+	}
+
+	return user.inPreferredUnits(), nil
+}
+
+// Promote makes the user with the email an admin. Sign up only creates members
+// and only admins can change roles, so this is how the first admin is made.
+func (a *accessService) Promote(ctx context.Context, email string) (User, error) {
+	user, err := a.storage.GetUserByEmail(ctx, strings.TrimSpace(email))
+
+	if err != nil {
+		return User{}, err
+	}
+
+	if user.ID == 0 {
+		return User{}, NotFoundError("access service - no user has the email " + email)
+	}
+
+	return a.UpdateRole(ctx, UpdateRoleRequest{UserID: user.ID, Role: RoleAdmin})
+}
+
+func (a *accessService) AddClient(ctx context.Context, request ClientRequest) error {
+	if request.CoachID == request.ClientID {
+		return ValidationError("client_id", "access service - a coach cannot be their own client")
+	}
+
+	coach, err := a.storage.GetUser(ctx, request.CoachID)
+
+	if err != nil {
+		return err
+	} else if coach.Role != RoleCoach {
+		return ValidationError("coach_id", "access service - only coaches can have clients")
+	}
+
+	_, err = a.storage.GetUser(ctx, request.ClientID)
+
+	if err != nil {
+		return err
+	}
+
+	exists, err := a.storage.IsCoachOf(ctx, request.CoachID, request.ClientID)
+
+	if err != nil {
+		return err
+	} else if exists {
+		return ConflictError("access service - user is already a client of this coach")
+	}
+
+	return a.storage.AddClient(ctx, request.CoachID, request.ClientID)
+}
+
+func (a *accessService) RemoveClient(ctx context.Context, request ClientRequest) error {
+	removed, err := a.storage.RemoveClient(ctx, request.CoachID, request.ClientID)
+
+	if err != nil {
+		return err
+	} else if !removed {
+		return NotFoundError("access service - user is not a client of this coach")
+	}
+
+	return nil
+}
+
+func (a *accessService) Clients(ctx context.Context, coachID int) ([]User, error) {
+	clients, err := a.storage.GetClients(ctx, coachID)
+
+	if err != nil {
+		return []User{}, err
+	}
+
+	for i := range clients {
+		clients[i] = clients[i].inPreferredUnits()
+	}
+
+	if clients == nil {
+		clients = []User{}
+	}
+
+	return clients, nil
+}
@@ -0,0 +1,319 @@
+package api_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"weight-tracker/pkg/api"
+)
+
+// the access repo reuses the users of the user repo mock, with roles and clients on top
+type mockAccessRepo struct {
+	mockUserRepo
+	clients map[int][]int
+}
+
+func (m mockAccessRepo) GetUser(ctx context.Context, userID int) (api.User, error) {
+	user, present := m.users[userID]
+
+	if !present {
+		return api.User{}, api.NotFoundError("storage - user does not exist")
+	}
+
+	return user, nil
+}
+
+func (m mockAccessRepo) UpdateRole(ctx context.Context, userID int, role string) (api.User, error) {
+	user, err := m.GetUser(ctx, userID)
+
+	if err != nil {
+		return api.User{}, err
+	}
+
+	user.Role = role
+	m.users[userID] = user
+
+	return user, nil
+}
+
+func (m mockAccessRepo) IsCoachOf(ctx context.Context, coachID, clientID int) (bool, error) {
+	for _, id := range m.clients[coachID] {
+		if id == clientID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (m mockAccessRepo) AddClient(ctx context.Context, coachID, clientID int) error {
+	m.clients[coachID] = append(m.clients[coachID], clientID)
+	return nil
+}
+
+func (m mockAccessRepo) RemoveClient(ctx context.Context, coachID, clientID int) (bool, error) {
+	for i, id := range m.clients[coachID] {
+		if id == clientID {
+			m.clients[coachID] = append(m.clients[coachID][:i], m.clients[coachID][i+1:]...)
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (m mockAccessRepo) GetClients(ctx context.Context, coachID int) (clients []api.User, err error) {
+	for _, id := range m.clients[coachID] {
+		clients = append(clients, m.users[id])
+	}
+
+	return
+}
+
+// user 1 is a member and client of coach 3, user 2 is a member without a coach
+// and user 4 is an admin
+func newMockAccessRepo() mockAccessRepo {
+	test_users := copyUserMap(users)
+
+	for id, user := range test_users {
+		user.Role = api.RoleMember
+		test_users[id] = user
+	}
+
+	test_users[3] = api.User{ID: 3, Name: "Badger", Email: "coach@email.com", UnitSystem: "metric", Role: api.RoleCoach}
+	test_users[4] = api.User{ID: 4, Name: "Toad", Email: "admin@email.com", UnitSystem: "metric", Role: api.RoleAdmin}
+
+	return mockAccessRepo{
+		mockUserRepo: mockUserRepo{users: test_users},
+		clients:      map[int][]int{3: {1}},
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	mockRepo := newMockAccessRepo()
+	mockAccessService := api.NewAccessService(&mockRepo)
+
+	tests := []struct {
+		name   string
+		actor  int
+		userID int
+		access api.Access
+		want   bool
+	}{
+		{
+			name:   "should let a member manage themselves",
+			actor:  1,
+			userID: 1,
+			access: api.ManageAccess,
+			want:   true,
+		}, {
+			name:   "should not let a member read someone else",
+			actor:  1,
+			userID: 2,
+			access: api.ReadAccess,
+			want:   false,
+		}, {
+			name:   "should let a coach read their client",
+			actor:  3,
+			userID: 1,
+			access: api.ReadAccess,
+			want:   true,
+		}, {
+			name:   "should not let a coach manage their client",
+			actor:  3,
+			userID: 1,
+			access: api.ManageAccess,
+			want:   false,
+		}, {
+			name:   "should not let a coach read someone who is not their client",
+			actor:  3,
+			userID: 2,
+			access: api.ReadAccess,
+			want:   false,
+		}, {
+			name:   "should let an admin manage everyone",
+			actor:  4,
+			userID: 2,
+			access: api.ManageAccess,
+			want:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			allowed, err := mockAccessService.Allowed(context.Background(), mockRepo.users[test.actor], test.userID, test.access)
+
+			if err != nil {
+				t.Errorf("test: %v failed. got error: %v", test.name, err)
+			}
+
+			if allowed != test.want {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, allowed, test.want)
+			}
+		})
+	}
+}
+
+func TestVisibleUsers(t *testing.T) {
+	mockRepo := newMockAccessRepo()
+	mockAccessService := api.NewAccessService(&mockRepo)
+
+	tests := []struct {
+		name     string
+		actor    int
+		want_ids []int
+	}{
+		{
+			name:     "should only show a member themselves",
+			actor:    2,
+			want_ids: []int{2},
+		}, {
+			name:     "should show a coach themselves and their clients",
+			actor:    3,
+			want_ids: []int{3, 1},
+		}, {
+			name:     "should show an admin everyone",
+			actor:    4,
+			want_ids: []int{1, 2, 3, 4},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			visible, err := mockAccessService.VisibleUsers(context.Background(), mockRepo.users[test.actor])
+
+			if err != nil {
+				t.Errorf("test: %v failed. got error: %v", test.name, err)
+			}
+
+			var ids []int
+			for _, user := range visible {
+				ids = append(ids, user.ID)
+			}
+
+			if !reflect.DeepEqual(ids, test.want_ids) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, ids, test.want_ids)
+			}
+		})
+	}
+}
+
+func TestUpdateRole(t *testing.T) {
+	tests := []struct {
+		name       string
+		request    api.UpdateRoleRequest
+		want_error error
+	}{
+		{
+			name:    "should make a member a coach",
+			request: api.UpdateRoleRequest{UserID: 2, Role: api.RoleCoach},
+		}, {
+			name:       "should return an error for an unknown role",
+			request:    api.UpdateRoleRequest{UserID: 2, Role: "owner"},
+			want_error: api.ValidationError("role", "access service - role must be member, coach or admin"),
+		}, {
+			name:       "should return an error for an unknown user",
+			request:    api.UpdateRoleRequest{UserID: 25, Role: api.RoleCoach},
+			want_error: api.NotFoundError("storage - user does not exist"),
+		},
+	}
+
+	for _, test := range tests {
+		mockRepo := newMockAccessRepo()
+		mockAccessService := api.NewAccessService(&mockRepo)
+
+		t.Run(test.name, func(t *testing.T) {
+			user, err := mockAccessService.UpdateRole(context.Background(), test.request)
+
+			if !reflect.DeepEqual(err, test.want_error) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want_error)
+			}
+
+			if err == nil && user.Role != test.request.Role {
+				t.Errorf("test: %v failed. got role: %v, wanted: %v", test.name, user.Role, test.request.Role)
+			}
+		})
+	}
+}
+
+func TestPromote(t *testing.T) {
+	tests := []struct {
+		name       string
+		email      string
+		want_id    int
+		want_error error
+	}{
+		{
+			name:    "should make the member with the email an admin",
+			email:   taken_email,
+			want_id: 2,
+		}, {
+			name:    "should ignore spaces around the email",
+			email:   " coach@email.com ",
+			want_id: 3,
+		}, {
+			name:       "should return an error when no user has the email",
+			email:      "nobody@email.com",
+			want_error: api.NotFoundError("access service - no user has the email nobody@email.com"),
+		},
+	}
+
+	for _, test := range tests {
+		mockRepo := newMockAccessRepo()
+		mockAccessService := api.NewAccessService(&mockRepo)
+
+		t.Run(test.name, func(t *testing.T) {
+			user, err := mockAccessService.Promote(context.Background(), test.email)
+
+			if !reflect.DeepEqual(err, test.want_error) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want_error)
+			}
+
+			if err == nil && (user.ID != test.want_id || mockRepo.users[test.want_id].Role != api.RoleAdmin) {
+				t.Errorf("test: %v failed. got: user %v with role %v, wanted: user %v with role %v", test.name, user.ID, mockRepo.users[test.want_id].Role, test.want_id, api.RoleAdmin)
+			}
+		})
+	}
+}
+
+func TestAddClient(t *testing.T) {
+	tests := []struct {
+		name       string
+		request    api.ClientRequest
+		want_error error
+	}{
+		{
+			name:    "should add a client to a coach",
+			request: api.ClientRequest{CoachID: 3, ClientID: 2},
+		}, {
+			name:       "should return an error when the coach is not a coach",
+			request:    api.ClientRequest{CoachID: 1, ClientID: 2},
+			want_error: api.ValidationError("coach_id", "access service - only coaches can have clients"),
+		}, {
+			name:       "should return an error when the coach is their own client",
+			request:    api.ClientRequest{CoachID: 3, ClientID: 3},
+			want_error: api.ValidationError("client_id", "access service - a coach cannot be their own client"),
+		}, {
+			name:       "should return an error when the user is already a client",
+			request:    api.ClientRequest{CoachID: 3, ClientID: 1},
+			want_error: api.ConflictError("access service - user is already a client of this coach"),
+		}, {
+			name:       "should return an error for an unknown client",
+			request:    api.ClientRequest{CoachID: 3, ClientID: 25},
+			want_error: api.NotFoundError("storage - user does not exist"),
+		},
+	}
+
+	for _, test := range tests {
+		mockRepo := newMockAccessRepo()
+		mockAccessService := api.NewAccessService(&mockRepo)
+
+		t.Run(test.name, func(t *testing.T) {
+			err := mockAccessService.AddClient(context.Background(), test.request)
+
+			if !reflect.DeepEqual(err, test.want_error) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want_error)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+var errInvalidDate = errors.New("date must be formatted as " + dateLayout)
+
+// Date is a calendar day, sent and received as 2006-01-02. The zero Date is
+// sent as null.
+type Date struct {
+	time.Time
+}
+
+// DateOf returns the day t falls on, at midnight UTC
+func DateOf(t time.Time) Date {
+	year, month, day := t.Date()
+
+	return Date{time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+
+	return []byte(`"` + d.Format(dateLayout) + `"`), nil
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		return nil
+	}
+
+	if len(data) < 2 || data[0] != '"' || data[len(data)-1] != '"' {
+		return errInvalidDate
+	}
+
+	parsed, err := time.Parse(dateLayout, string(data[1:len(data)-1]))
+
+	if err != nil {
+		return errInvalidDate
+	}
+
+	d.Time = parsed
+
+	return nil
+}
+
+// ageAt is the age in whole years of someone born on dateOfBirth at the given time
+func ageAt(dateOfBirth Date, at time.Time) int {
+	year, month, day := at.Date()
+	age := year - dateOfBirth.Year()
+
+	if month < dateOfBirth.Month() || (month == dateOfBirth.Month() && day < dateOfBirth.Day()) {
+		age--
+	}
+
+	return age
+}
+
+// approximateDateOfBirth guesses the date of birth of someone who was age at the
+// given time. Their birthday could be any day of the year, the middle of it is
+// the best guess.
+func approximateDateOfBirth(age int, at time.Time) Date {
+	return DateOf(at.AddDate(-age, -6, 0))
+}
+
+// dateOfBirthOrAge returns the date of birth, or when it was left out an
+// approximation of it from the age, which older clients still send instead.
+// The stored date of birth is kept while it still gives that age, so a client
+// sending back the age it was shown does not move the date of birth each time.
+func dateOfBirthOrAge(dateOfBirth Date, age int, stored Date) Date {
+	if !dateOfBirth.IsZero() || age == 0 {
+		return dateOfBirth
+	}
+
+	now := time.Now()
+
+	if !stored.IsZero() && ageAt(stored, now) == age {
+		return stored
+	}
+
+	return approximateDateOfBirth(age, now)
+}
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// AnalyticsService contains the methods of the analytics service
+type AnalyticsService interface {
+	Trend(ctx context.Context, request TrendRequest) (Trend, error)
+	Metrics(ctx context.Context, userID int) (MetricsSummary, error)
+}
+
+type analyticsService struct {
+	storage WeightRepository
+}
+
+// NewAnalyticsService only reads weights, so it shares the weight repository
+func NewAnalyticsService(weightRepo WeightRepository) AnalyticsService {
+	return &analyticsService{
+		storage: weightRepo,
+	}
+}
+
+// smoothing factor of the trend line, the 10% the Hacker's Diet uses for daily weigh-ins
+const trendSmoothing = 0.1
+
+const (
+	day   = 24 * time.Hour
+	month = 30 * day
+)
+
+// Trend smooths the weight entries of a user with an exponential moving average,
+// along with 7 and 30 day simple moving averages, and groups them per period.
+// Entries from before From are read too, so the averages are warmed up.
+func (a *analyticsService) Trend(ctx context.Context, request TrendRequest) (Trend, error) {
+	if request.UserID == 0 {
+		return Trend{}, ValidationError("user_id", "analytics service - user ID cannot be 0")
+	}
+
+	if request.From != nil && request.To != nil && request.From.After(*request.To) {
+		return Trend{}, ValidationError("from", "analytics service - from must not be after to")
+	}
+
+	switch request.Period {
+	case "":
+		request.Period = "week"
+	case "week", "month":
+	default:
+		return Trend{}, ValidationError("period", "analytics service - period must be week or month")
+	}
+
+	user, err := a.storage.GetUser(ctx, request.UserID)
+
+	if err != nil {
+		return Trend{}, err
+	}
+
+	filter := WeightFilter{UserID: user.ID, To: request.To}
+
+	if request.From != nil {
+		warmUp := request.From.Add(-month)
+		filter.From = &warmUp
+	}
+
+	weights, err := a.storage.GetWeights(ctx, filter)
+
+	if err != nil {
+		return Trend{}, err
+	}
+
+	points := trendPoints(weights)
+
+	// drop the points that were only read to warm up the averages
+	if request.From != nil {
+		for len(points) > 0 && points[0].MeasuredAt.Before(*request.From) {
+			points = points[1:]
+		}
+	}
+
+	trend := Trend{
+		Unit:    weightUnit(user.UnitSystem),
+		Points:  points,
+		Periods: trendPeriods(points, request.Period),
+	}
+
+	if rate, ok := weeklyTrend(trendLine(points)); ok {
+		trend.WeeklyRate = &rate
+	}
+
+	return trend.inUnits(user.UnitSystem), nil
+}
+
+// trendPoints calculates the smoothed values of weights sorted from oldest to newest
+func trendPoints(weights []Weight) []TrendPoint {
+	points := make([]TrendPoint, 0, len(weights))
+
+	for i, weight := range weights {
+		point := TrendPoint{MeasuredAt: weight.MeasuredAt, Weight: weight.Weight}
+
+		if i == 0 {
+			point.Trend = weight.Weight
+		} else {
+			previous := points[i-1].Trend
+			point.Trend = previous + trendSmoothing*(weight.Weight-previous)
+		}
+
+		point.MovingAverage7Days = movingAverage(weights[:i+1], weight.MeasuredAt, 7*day)
+		point.MovingAverage30Days = movingAverage(weights[:i+1], weight.MeasuredAt, month)
+
+		points = append(points, point)
+	}
+
+	return points
+}
+
+// movingAverage is the average of the weights measured within window up to and including until.
+// The weights have to be sorted from oldest to newest.
+func movingAverage(weights []Weight, until time.Time, window time.Duration) float64 {
+	var sum float64
+	var count int
+
+	for i := len(weights) - 1; i >= 0; i-- {
+		if !weights[i].MeasuredAt.After(until.Add(-window)) {
+			break
+		}
+
+		sum += weights[i].Weight
+		count++
+	}
+
+	if count == 0 {
+		return 0
+	}
+
+	return sum / float64(count)
+}
+
+// trendLine turns the smoothed values back into weights, so the rate can be fitted on them
+func trendLine(points []TrendPoint) []Weight {
+	line := make([]Weight, len(points))
+
+	for i, point := range points {
+		line[i] = Weight{MeasuredAt: point.MeasuredAt, Weight: point.Trend}
+	}
+
+	return line
+}
+
+// trendPeriods groups the points per calendar week, starting on monday, or per calendar month
+func trendPeriods(points []TrendPoint, period string) []TrendPeriod {
+	periods := []TrendPeriod{}
+
+	for _, point := range points {
+		start, end := periodBounds(point.MeasuredAt, period)
+
+		if len(periods) == 0 || !periods[len(periods)-1].Start.Equal(start) {
+			periods = append(periods, TrendPeriod{
+				Start: start,
+				End:   end,
+				Min:   point.Weight,
+				Max:   point.Weight,
+			})
+		}
+
+		current := &periods[len(periods)-1]
+		current.Min = math.Min(current.Min, point.Weight)
+		current.Max = math.Max(current.Max, point.Weight)
+		current.Average = (current.Average*float64(current.Entries) + point.Weight) / float64(current.Entries+1)
+		current.Entries++
+		current.Trend = point.Trend
+	}
+
+	for i := 1; i < len(periods); i++ {
+		weeks := periods[i].End.Sub(periods[i-1].End).Hours() / (24 * 7)
+		rate := (periods[i].Trend - periods[i-1].Trend) / weeks
+		periods[i].WeeklyRate = &rate
+	}
+
+	return periods
+}
+
+func periodBounds(t time.Time, period string) (time.Time, time.Time) {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	if period == "month" {
+		start := midnight.AddDate(0, 0, 1-t.Day())
+		return start, start.AddDate(0, 1, 0)
+	}
+
+	// time.Weekday starts on sunday
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	start := midnight.AddDate(0, 0, -daysSinceMonday)
+
+	return start, start.AddDate(0, 0, 7)
+}
+
+// inUnits renders the weights and rates of the trend in the given unit system
+func (t Trend) inUnits(unitSystem string) Trend {
+	for i, point := range t.Points {
+		t.Points[i].Weight = fromKilograms(point.Weight, unitSystem)
+		t.Points[i].Trend = fromKilograms(point.Trend, unitSystem)
+		t.Points[i].MovingAverage7Days = fromKilograms(point.MovingAverage7Days, unitSystem)
+		t.Points[i].MovingAverage30Days = fromKilograms(point.MovingAverage30Days, unitSystem)
+	}
+
+	for i, period := range t.Periods {
+		t.Periods[i].Min = fromKilograms(period.Min, unitSystem)
+		t.Periods[i].Max = fromKilograms(period.Max, unitSystem)
+		t.Periods[i].Average = fromKilograms(period.Average, unitSystem)
+		t.Periods[i].Trend = fromKilograms(period.Trend, unitSystem)
+
+		if period.WeeklyRate != nil {
+			rate := fromKilograms(*period.WeeklyRate, unitSystem)
+			t.Periods[i].WeeklyRate = &rate
+		}
+	}
+
+	if t.WeeklyRate != nil {
+		rate := fromKilograms(*t.WeeklyRate, unitSystem)
+		t.WeeklyRate = &rate
+	}
+
+	return t
+}
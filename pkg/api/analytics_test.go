@@ -0,0 +1,110 @@
+package api_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+	"weight-tracker/pkg/api"
+)
+
+func TestWeightTrend(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2022, time.May, d, 8, 0, 0, 0, time.UTC)
+	}
+
+	// may 2nd 2022 is a monday
+	firstWeek := []api.Weight{
+		{ID: 1, UserID: 1, Weight: 80, MeasuredAt: day(2)},
+		{ID: 2, UserID: 1, Weight: 81, MeasuredAt: day(3)},
+		{ID: 3, UserID: 1, Weight: 79, MeasuredAt: day(4)},
+		{ID: 4, UserID: 1, Weight: 80, MeasuredAt: day(5)},
+	}
+
+	twoWeeks := []api.Weight{
+		{ID: 1, UserID: 1, Weight: 80, MeasuredAt: day(2)},
+		{ID: 2, UserID: 1, Weight: 79, MeasuredAt: day(9)},
+	}
+
+	rate := func(r float64) *float64 { return &r }
+	from := day(9)
+
+	tests := []struct {
+		name         string
+		weights      []api.Weight
+		request      api.TrendRequest
+		want_points  []api.TrendPoint
+		want_periods []api.TrendPeriod
+		want_error   error
+	}{
+		{
+			name:    "should smooth the weights and summarise the week",
+			weights: firstWeek,
+			request: api.TrendRequest{UserID: 1},
+			want_points: []api.TrendPoint{
+				{MeasuredAt: day(2), Weight: 80, Trend: 80, MovingAverage7Days: 80, MovingAverage30Days: 80},
+				{MeasuredAt: day(3), Weight: 81, Trend: 80.1, MovingAverage7Days: 80.5, MovingAverage30Days: 80.5},
+				{MeasuredAt: day(4), Weight: 79, Trend: 79.99, MovingAverage7Days: 80, MovingAverage30Days: 80},
+				{MeasuredAt: day(5), Weight: 80, Trend: 79.99, MovingAverage7Days: 80, MovingAverage30Days: 80},
+			},
+			want_periods: []api.TrendPeriod{
+				{Start: day(2).Add(-8 * time.Hour), End: day(9).Add(-8 * time.Hour), Entries: 4, Min: 79, Max: 81, Average: 80, Trend: 79.99},
+			},
+		}, {
+			name:    "should calculate the weekly rate of change between periods",
+			weights: twoWeeks,
+			request: api.TrendRequest{UserID: 1, Period: "week"},
+			want_points: []api.TrendPoint{
+				{MeasuredAt: day(2), Weight: 80, Trend: 80, MovingAverage7Days: 80, MovingAverage30Days: 80},
+				{MeasuredAt: day(9), Weight: 79, Trend: 79.9, MovingAverage7Days: 79, MovingAverage30Days: 79.5},
+			},
+			want_periods: []api.TrendPeriod{
+				{Start: day(2).Add(-8 * time.Hour), End: day(9).Add(-8 * time.Hour), Entries: 1, Min: 80, Max: 80, Average: 80, Trend: 80},
+				{Start: day(9).Add(-8 * time.Hour), End: day(16).Add(-8 * time.Hour), Entries: 1, Min: 79, Max: 79, Average: 79, Trend: 79.9, WeeklyRate: rate(-0.1)},
+			},
+		}, {
+			name:    "should use entries before from to warm up the trend",
+			weights: twoWeeks,
+			request: api.TrendRequest{UserID: 1, From: &from},
+			want_points: []api.TrendPoint{
+				{MeasuredAt: day(9), Weight: 79, Trend: 79.9, MovingAverage7Days: 79, MovingAverage30Days: 79.5},
+			},
+			want_periods: []api.TrendPeriod{
+				{Start: day(9).Add(-8 * time.Hour), End: day(16).Add(-8 * time.Hour), Entries: 1, Min: 79, Max: 79, Average: 79, Trend: 79.9},
+			},
+		}, {
+			name:       "should return an error for an unknown period",
+			request:    api.TrendRequest{UserID: 1, Period: "fortnight"},
+			want_error: api.ValidationError("period", "analytics service - period must be week or month"),
+		}, {
+			name:       "should return an error when the user does not exist",
+			request:    api.TrendRequest{UserID: 2},
+			want_error: api.NotFoundError("storage - user does not exist"),
+		},
+	}
+
+	for _, test := range tests {
+		mockRepo := mockWeightRepo{weights: test.weights}
+		mockAnalyticsService := api.NewAnalyticsService(&mockRepo)
+
+		t.Run(test.name, func(t *testing.T) {
+			trend, err := mockAnalyticsService.Trend(context.Background(), test.request)
+
+			if !reflect.DeepEqual(err, test.want_error) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want_error)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(trend.Points, test.want_points) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, trend.Points, test.want_points)
+			}
+
+			if !reflect.DeepEqual(trend.Periods, test.want_periods) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, trend.Periods, test.want_periods)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AuthService contains the methods of the auth service
+type AuthService interface {
+	Login(ctx context.Context, request LoginRequest) (token string, err error)
+	IssueToken(userID int) (token string, err error)
+	Authenticate(ctx context.Context, token string) (User, error)
+}
+
+// AuthRepository is what lets the auth service look up users and their credentials
+type AuthRepository interface {
+	GetUser(ctx context.Context, userID int) (User, error)
+	GetUserByEmail(ctx context.Context, userEmail string) (User, error)
+	GetPasswordHash(ctx context.Context, userID int) (string, error)
+}
+
+type authService struct {
+	storage  AuthRepository
+	secret   []byte
+	tokenTTL time.Duration
+}
+
+// NewAuthService signs tokens with the given secret, tokens stay valid for tokenTTL
+func NewAuthService(authRepo AuthRepository, secret []byte, tokenTTL time.Duration) AuthService {
+	return &authService{
+		storage:  authRepo,
+		secret:   secret,
+		tokenTTL: tokenTTL,
+	}
+}
+
+var errInvalidCredentials = UnauthorizedError("auth service - invalid email or password")
+
+// compared against when there is no user with the given email, so a login
+// for an unknown email takes as long as one with a wrong password
+var dummyPasswordHash = []byte("$2a$10$GAMPjwX2UROafqzNlKjHw.T/8/aMssl9JwEqiADXfyW7jja9L/5o2")
+
+func (a *authService) Login(ctx context.Context, request LoginRequest) (string, error) {
+	user, err := a.storage.GetUserByEmail(ctx, strings.TrimSpace(request.Email))
+
+	if err != nil {
+		return "", err
+	}
+
+	hash := dummyPasswordHash
+
+	if user.ID != 0 {
+		storedHash, err := a.storage.GetPasswordHash(ctx, user.ID)
+
+		if err != nil {
+			return "", err
+		}
+
+		hash = []byte(storedHash)
+	}
+
+	err = bcrypt.CompareHashAndPassword(hash, []byte(request.Password))
+
+	if err != nil || user.ID == 0 {
+		return "", errInvalidCredentials
+	}
+
+	return a.IssueToken(user.ID)
+}
+
+func (a *authService) IssueToken(userID int) (string, error) {
+	now := time.Now()
+
+	return signToken(a.secret, userID, now, now.Add(a.tokenTTL))
+}
+
+// Authenticate returns the user a valid token was issued for
+func (a *authService) Authenticate(ctx context.Context, token string) (User, error) {
+	userID, err := verifyToken(a.secret, token, time.Now())
+
+	if err != nil {
+		return User{}, err
+	}
+
+	user, err := a.storage.GetUser(ctx, userID)
+
+	if err != nil {
+		return User{}, errInvalidToken
+	}
+
+	return user, nil
+}
+
+// hashPassword hashes a password with bcrypt, its length is checked by the
+// validation of the request
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
@@ -0,0 +1,150 @@
+package api_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"weight-tracker/pkg/api"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// the auth repo reuses the users of the user repo mock
+type mockAuthRepo struct {
+	mockUserRepo
+	passwordHashes map[int]string
+}
+
+func (m mockAuthRepo) GetUser(ctx context.Context, userID int) (api.User, error) {
+	user, present := m.users[userID]
+
+	if !present {
+		return api.User{}, api.NotFoundError("storage - user does not exist")
+	}
+
+	return user, nil
+}
+
+func (m mockAuthRepo) GetPasswordHash(ctx context.Context, userID int) (string, error) {
+	return m.passwordHashes[userID], nil
+}
+
+func newMockAuthRepo(t *testing.T) mockAuthRepo {
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+
+	if err != nil {
+		t.Fatalf("could not hash password: %v", err)
+	}
+
+	return mockAuthRepo{
+		mockUserRepo:   mockUserRepo{users: copyUserMap(users)},
+		passwordHashes: map[int]string{1: string(hash)},
+	}
+}
+
+func TestLogin(t *testing.T) {
+	mockRepo := newMockAuthRepo(t)
+	mockAuthService := api.NewAuthService(&mockRepo, []byte("secret"), time.Hour)
+
+	tests := []struct {
+		name       string
+		request    api.LoginRequest
+		want_error error
+	}{
+		{
+			name:    "should log in with the right password",
+			request: api.LoginRequest{Email: "some_email@email.com", Password: "correct horse"},
+		}, {
+			name:       "should return an error for a wrong password",
+			request:    api.LoginRequest{Email: "some_email@email.com", Password: "battery staple"},
+			want_error: api.UnauthorizedError("auth service - invalid email or password"),
+		}, {
+			name:       "should return an error for a user without a password",
+			request:    api.LoginRequest{Email: taken_email, Password: ""},
+			want_error: api.UnauthorizedError("auth service - invalid email or password"),
+		}, {
+			name:       "should return an error for an unknown email",
+			request:    api.LoginRequest{Email: "unknown@email.com", Password: "correct horse"},
+			want_error: api.UnauthorizedError("auth service - invalid email or password"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token, err := mockAuthService.Login(context.Background(), test.request)
+
+			if !reflect.DeepEqual(err, test.want_error) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want_error)
+			}
+
+			if (token != "") != (test.want_error == nil) {
+				t.Errorf("test: %v failed. got token: %q", test.name, token)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	mockRepo := newMockAuthRepo(t)
+	mockAuthService := api.NewAuthService(&mockRepo, []byte("secret"), time.Hour)
+	otherAuthService := api.NewAuthService(&mockRepo, []byte("other secret"), time.Hour)
+	expiredAuthService := api.NewAuthService(&mockRepo, []byte("secret"), -time.Hour)
+
+	token, _ := mockAuthService.IssueToken(1)
+	otherToken, _ := otherAuthService.IssueToken(1)
+	expiredToken, _ := expiredAuthService.IssueToken(1)
+	deletedUserToken, _ := mockAuthService.IssueToken(25)
+
+	parts := strings.Split(token, ".")
+	forgedClaims, _ := otherAuthService.IssueToken(2)
+	forgedToken := parts[0] + "." + strings.Split(forgedClaims, ".")[1] + "." + parts[2]
+
+	tests := []struct {
+		name       string
+		token      string
+		want_user  api.User
+		want_error error
+	}{
+		{
+			name:      "should return the user the token was issued for",
+			token:     token,
+			want_user: users[1],
+		}, {
+			name:       "should return an error for a token signed with another secret",
+			token:      otherToken,
+			want_error: api.UnauthorizedError("auth service - invalid token"),
+		}, {
+			name:       "should return an error for a token with altered claims",
+			token:      forgedToken,
+			want_error: api.UnauthorizedError("auth service - invalid token"),
+		}, {
+			name:       "should return an error for an expired token",
+			token:      expiredToken,
+			want_error: api.UnauthorizedError("auth service - token expired"),
+		}, {
+			name:       "should return an error when the user no longer exists",
+			token:      deletedUserToken,
+			want_error: api.UnauthorizedError("auth service - invalid token"),
+		}, {
+			name:       "should return an error for garbage",
+			token:      "not a token",
+			want_error: api.UnauthorizedError("auth service - invalid token"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			user, err := mockAuthService.Authenticate(context.Background(), test.token)
+
+			if !reflect.DeepEqual(err, test.want_error) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want_error)
+			}
+
+			if !reflect.DeepEqual(user, test.want_user) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, user, test.want_user)
+			}
+		})
+	}
+}
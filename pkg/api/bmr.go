@@ -0,0 +1,109 @@
+package api
+
+// names of the supported BMR formulas, as stored on users and weight entries
+const (
+	MifflinStJeor  = "mifflin_st_jeor"
+	HarrisBenedict = "harris_benedict"
+	KatchMcArdle   = "katch_mcardle"
+)
+
+// BMRInput holds everything a formula may need. Height is in centimeters and
+// weight in kilograms. BodyFatPercentage is only required by Katch-McArdle.
+type BMRInput struct {
+	Height            float64
+	Weight            float64
+	Age               int
+	Sex               string
+	BodyFatPercentage *float64
+}
+
+// BMRFormula calculates the basal metabolic rate in kcal per day
+type BMRFormula interface {
+	Name() string
+	Calculate(input BMRInput) (int, error)
+}
+
+// BMRFormulaByName returns the formula with the given name. An empty name
+// returns the default formula, Mifflin-St Jeor.
+func BMRFormulaByName(name string) (BMRFormula, error) {
+	switch name {
+	case "", MifflinStJeor:
+		return mifflinStJeor{}, nil
+	case HarrisBenedict:
+		return harrisBenedict{}, nil
+	case KatchMcArdle:
+		return katchMcArdle{}, nil
+	default:
+		return nil, ValidationError("bmr_formula", "invalid bmr formula - must be mifflin_st_jeor, harris_benedict or katch_mcardle")
+	}
+}
+
+type mifflinStJeor struct{}
+
+func (mifflinStJeor) Name() string {
+	return MifflinStJeor
+}
+
+func (mifflinStJeor) Calculate(input BMRInput) (int, error) {
+	var sexModifier float64
+
+	switch input.Sex {
+	case "male":
+		sexModifier = -5
+	case "female":
+		sexModifier = 161
+	default:
+		return 0, ValidationError("sex", "invalid variable sex provided to CalculateBMR. needs to be either male or female")
+	}
+
+	return int((10 * input.Weight) + (input.Height * 6.25) - float64(5*input.Age) - sexModifier), nil
+}
+
+// harrisBenedict is the Harris-Benedict equation as revised by Roza and Shizgal in 1984
+type harrisBenedict struct{}
+
+func (harrisBenedict) Name() string {
+	return HarrisBenedict
+}
+
+func (harrisBenedict) Calculate(input BMRInput) (int, error) {
+	age := float64(input.Age)
+
+	switch input.Sex {
+	case "male":
+		return int(88.362 + (13.397 * input.Weight) + (4.799 * input.Height) - (5.677 * age)), nil
+	case "female":
+		return int(447.593 + (9.247 * input.Weight) + (3.098 * input.Height) - (4.330 * age)), nil
+	default:
+		return 0, ValidationError("sex", "invalid variable sex provided to CalculateBMR. needs to be either male or female")
+	}
+}
+
+// katchMcArdle works from the lean body mass, so it ignores sex, age and height
+type katchMcArdle struct{}
+
+func (katchMcArdle) Name() string {
+	return KatchMcArdle
+}
+
+func (katchMcArdle) Calculate(input BMRInput) (int, error) {
+	if input.BodyFatPercentage == nil {
+		return 0, ValidationError("body_fat_percentage", "katch-mcardle requires a body fat percentage")
+	}
+
+	if err := validateBodyFatPercentage(*input.BodyFatPercentage); err != nil {
+		return 0, err
+	}
+
+	leanBodyMass := input.Weight * (1 - *input.BodyFatPercentage/100)
+
+	return int(370 + (21.6 * leanBodyMass)), nil
+}
+
+func validateBodyFatPercentage(bodyFatPercentage float64) error {
+	if bodyFatPercentage <= 0 || bodyFatPercentage >= 100 {
+		return ValidationError("body_fat_percentage", "body fat percentage must be between 0 and 100")
+	}
+
+	return nil
+}
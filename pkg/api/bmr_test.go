@@ -0,0 +1,81 @@
+package api_test
+
+import (
+	"reflect"
+	"testing"
+	"weight-tracker/pkg/api"
+)
+
+func TestBMRFormulas(t *testing.T) {
+	bodyFat := 20.0
+	invalidBodyFat := 120.0
+
+	tests := []struct {
+		name    string
+		formula string
+		input   api.BMRInput
+		want    int
+		err     error
+	}{
+		{
+			name:    "should default to mifflin-st jeor",
+			formula: "",
+			input:   api.BMRInput{Height: 170, Age: 22, Weight: 65, Sex: "female"},
+			want:    1441,
+		}, {
+			name:    "should calculate harris-benedict for a male",
+			formula: "harris_benedict",
+			input:   api.BMRInput{Height: 170, Age: 22, Weight: 65, Sex: "male"},
+			want:    1650,
+		}, {
+			name:    "should calculate harris-benedict for a female",
+			formula: "harris_benedict",
+			input:   api.BMRInput{Height: 170, Age: 22, Weight: 65, Sex: "female"},
+			want:    1480,
+		}, {
+			name:    "should calculate katch-mcardle from the lean body mass",
+			formula: "katch_mcardle",
+			input:   api.BMRInput{Height: 170, Age: 22, Weight: 65, Sex: "female", BodyFatPercentage: &bodyFat},
+			want:    1493,
+		}, {
+			name:    "should return an error when katch-mcardle has no body fat percentage",
+			formula: "katch_mcardle",
+			input:   api.BMRInput{Height: 170, Age: 22, Weight: 65, Sex: "female"},
+			err:     api.ValidationError("body_fat_percentage", "katch-mcardle requires a body fat percentage"),
+		}, {
+			name:    "should return an error for an impossible body fat percentage",
+			formula: "katch_mcardle",
+			input:   api.BMRInput{Weight: 65, BodyFatPercentage: &invalidBodyFat},
+			err:     api.ValidationError("body_fat_percentage", "body fat percentage must be between 0 and 100"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			formula, err := api.BMRFormulaByName(test.formula)
+
+			if err != nil {
+				t.Fatalf("test: %v failed. unexpected error: %v", test.name, err)
+			}
+
+			BMR, err := formula.Calculate(test.input)
+
+			if !reflect.DeepEqual(err, test.err) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.err)
+			}
+
+			if BMR != test.want {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, BMR, test.want)
+			}
+		})
+	}
+}
+
+func TestBMRFormulaByName(t *testing.T) {
+	_, err := api.BMRFormulaByName("guesswork")
+	want := api.ValidationError("bmr_formula", "invalid bmr formula - must be mifflin_st_jeor, harris_benedict or katch_mcardle")
+
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("got: %v, wanted: %v", err, want)
+	}
+}
@@ -1,49 +1,370 @@
 package api
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
+// HeightUnit is cm or in and defaults to the unit of the chosen UnitSystem.
+// Password is never stored, the user service sets PasswordHash from it.
+// WeeklyRate is the targeted change in weight per week, in kg or lb depending
+// on the UnitSystem. When set it takes precedence over the WeightGoal preset.
+// Age is deprecated, without a DateOfBirth the date of birth is approximated from it.
 type NewUserRequest struct {
-	Name          string `json:"name"`
-	Age           int    `json:"age"`
-	Height        int    `json:"height"`
-	Sex           string `json:"sex"`
-	ActivityLevel int    `json:"activity_level"`
-	WeightGoal    string `json:"weight_goal"`
-	Email         string `json:"email"`
+	Name          string   `json:"name"`
+	DateOfBirth   Date     `json:"date_of_birth"`
+	Age           int      `json:"age,omitempty"`
+	Height        float64  `json:"height"`
+	HeightUnit    string   `json:"height_unit"`
+	Sex           string   `json:"sex"`
+	ActivityLevel int      `json:"activity_level"`
+	WeightGoal    string   `json:"weight_goal"`
+	Email         string   `json:"email"`
+	UnitSystem    string   `json:"unit_system"`
+	BMRFormula    string   `json:"bmr_formula"`
+	WeeklyRate    *float64 `json:"weekly_rate,omitempty"`
+	Password      string   `json:"password"`
+	PasswordHash  string   `json:"-"`
 }
 
+// Version is the version of the user the update was made to, see User. Age is
+// deprecated, as for NewUserRequest.
 type UpdateUserRequest struct {
-	ID            int    `json:"id"`
-	Name          string `json:"name"`
-	Age           int    `json:"age"`
-	Height        int    `json:"height"`
-	Sex           string `json:"sex"`
-	ActivityLevel int    `json:"activity_level"`
-	WeightGoal    string `json:"weight_goal"`
-	Email         string `json:"email"`
+	ID            int      `json:"id"`
+	Name          string   `json:"name"`
+	DateOfBirth   Date     `json:"date_of_birth"`
+	Age           int      `json:"age,omitempty"`
+	Height        float64  `json:"height"`
+	HeightUnit    string   `json:"height_unit"`
+	Sex           string   `json:"sex"`
+	ActivityLevel int      `json:"activity_level"`
+	WeightGoal    string   `json:"weight_goal"`
+	Email         string   `json:"email"`
+	UnitSystem    string   `json:"unit_system"`
+	BMRFormula    string   `json:"bmr_formula"`
+	WeeklyRate    *float64 `json:"weekly_rate,omitempty"`
+	Version       int      `json:"-"`
 }
 
+// AnyVersion lets an update or delete apply to whatever version of a user is stored
+const AnyVersion = 0
+
+// UserPatch is a JSON merge patch (RFC 7396) of a user. Members left out of the
+// patch keep their value, members set to null are removed.
+type UserPatch map[string]json.RawMessage
+
+// UserChanges maps the columns of a user to their new values, in storage units
+type UserChanges map[string]interface{}
+
+// Height is stored in centimeters and WeeklyRate in kg per week. HeightUnit is
+// only set on users rendered for a response, in which case Height is given in
+// that unit and WeeklyRate in the weight unit of the UnitSystem. Version goes up
+// with every change and is sent as the ETag of the user rather than in the body.
+// Age is not stored, it is derived from DateOfBirth for a response.
 type User struct {
 	ID            int       `json:"id"`
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 	Name          string    `json:"name"`
+	DateOfBirth   Date      `json:"date_of_birth"`
 	Age           int       `json:"age"`
-	Height        int       `json:"height"`
+	Height        float64   `json:"height"`
+	HeightUnit    string    `json:"height_unit,omitempty"`
 	Sex           string    `json:"sex"`
 	ActivityLevel int       `json:"activity_level"`
 	WeightGoal    string    `json:"weight_goal"`
 	Email         string    `json:"email"`
+	UnitSystem    string    `json:"unit_system"`
+	BMRFormula    string    `json:"bmr_formula"`
+	WeeklyRate    *float64  `json:"weekly_rate,omitempty"`
+	Role          string    `json:"role"`
+	Version       int       `json:"-"`
 }
 
+// Weight is stored in kilograms. Unit is only set on entries rendered
+// for a response, in which case Weight is given in that unit. Warnings and
+// Metrics are never stored, Warnings explain adjustments made to the daily
+// caloric intake and Metrics are derived whenever an entry is rendered.
 type Weight struct {
-	Weight             int `json:"weight"`
-	UserID             int `json:"user_id"`
-	BMR                int `json:"bmr"`
-	DailyCaloricIntake int `json:"daily_caloric_intake"`
+	ID                 int            `json:"id"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          *time.Time     `json:"updated_at,omitempty"`
+	MeasuredAt         time.Time      `json:"measured_at"`
+	Weight             float64        `json:"weight"`
+	Unit               string         `json:"unit,omitempty"`
+	UserID             int            `json:"user_id"`
+	BMR                int            `json:"bmr"`
+	BMRFormula         string         `json:"bmr_formula"`
+	DailyCaloricIntake int            `json:"daily_caloric_intake"`
+	BodyFatPercentage  *float64       `json:"body_fat_percentage,omitempty"`
+	Warnings           []string       `json:"warnings,omitempty"`
+	Metrics            *HealthMetrics `json:"metrics,omitempty"`
+}
+
+// HealthMetrics are derived from a weight entry and the current profile of its
+// owner. TDEE is the total daily energy expenditure, the calories needed to keep
+// the weight, before the deficit or surplus of the user's goal is applied.
+type HealthMetrics struct {
+	BMI         float64     `json:"bmi"`
+	BMICategory string      `json:"bmi_category"`
+	TDEE        int         `json:"tdee"`
+	IdealWeight IdealWeight `json:"ideal_weight"`
+}
+
+// IdealWeight is the weight the Devine and Robinson formulas suggest for the
+// height of a user, along with the range of weights with a normal BMI
+type IdealWeight struct {
+	Devine     float64 `json:"devine"`
+	Robinson   float64 `json:"robinson"`
+	HealthyMin float64 `json:"healthy_min"`
+	HealthyMax float64 `json:"healthy_max"`
+	Unit       string  `json:"unit,omitempty"`
 }
 
+// MetricsSummary holds the health metrics of a user at their latest weight entry.
+// LatestWeight is left out when the user has no entries yet, the ideal weight
+// only depends on the profile.
+type MetricsSummary struct {
+	UserID       int         `json:"user_id"`
+	LatestWeight *Weight     `json:"latest_weight,omitempty"`
+	IdealWeight  IdealWeight `json:"ideal_weight"`
+}
+
+// MeasuredAt is optional and defaults to the time of the request. It allows
+// logging readings from earlier days. Unit is kg or lb and defaults to the
+// unit of the user's unit system. BodyFatPercentage is optional, but needed
+// for the Katch-McArdle formula.
 type NewWeightRequest struct {
-	Weight int `json:"weight"`
-	UserID int `json:"user_id"`
+	Weight            float64    `json:"weight"`
+	Unit              string     `json:"unit"`
+	UserID            int        `json:"user_id"`
+	MeasuredAt        *time.Time `json:"measured_at,omitempty"`
+	BodyFatPercentage *float64   `json:"body_fat_percentage,omitempty"`
+}
+
+// MeasuredAt and BodyFatPercentage are only changed when given
+type UpdateWeightRequest struct {
+	ID                int        `json:"id"`
+	Weight            float64    `json:"weight"`
+	Unit              string     `json:"unit"`
+	MeasuredAt        *time.Time `json:"measured_at,omitempty"`
+	BodyFatPercentage *float64   `json:"body_fat_percentage,omitempty"`
+}
+
+// WeightHistoryRequest holds the query used to page through a user's weight entries.
+// From and To are inclusive, Cursor is the NextCursor of a previous page.
+type WeightHistoryRequest struct {
+	UserID int
+	From   *time.Time
+	To     *time.Time
+	Order  string
+	Limit  int
+	Cursor string
+}
+
+type WeightHistory struct {
+	Weights    []Weight `json:"weights"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+// WeightCursor marks the last entry of a page; the next page starts right after it.
+type WeightCursor struct {
+	MeasuredAt time.Time
+	ID         int
+}
+
+// WeightFilter is what the storage uses to query a user's weight entries
+type WeightFilter struct {
+	UserID     int
+	From       *time.Time
+	To         *time.Time
+	Descending bool
+	After      *WeightCursor
+	Limit      int
+}
+
+// StartWeight and TargetWeight are stored in kilograms. Unit is only set on
+// goals rendered for a response, in which case the weights are given in that unit.
+type Goal struct {
+	ID           int        `json:"id"`
+	UserID       int        `json:"user_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	StartWeight  float64    `json:"start_weight"`
+	TargetWeight float64    `json:"target_weight"`
+	TargetDate   *time.Time `json:"target_date,omitempty"`
+	Unit         string     `json:"unit,omitempty"`
+}
+
+// Measurement is a reading of the body composition of a user, each of its values
+// is optional. Lengths are stored in centimeters and MuscleMass in kilograms.
+// LengthUnit and WeightUnit are only set on measurements rendered for a response,
+// in which case the values are given in those units. Derived is never stored, it
+// is calculated whenever a measurement is rendered.
+type Measurement struct {
+	ID                int             `json:"id"`
+	UserID            int             `json:"user_id"`
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         *time.Time      `json:"updated_at,omitempty"`
+	MeasuredAt        time.Time       `json:"measured_at"`
+	BodyFatPercentage *float64        `json:"body_fat_percentage,omitempty"`
+	MuscleMass        *float64        `json:"muscle_mass,omitempty"`
+	WaterPercentage   *float64        `json:"water_percentage,omitempty"`
+	Waist             *float64        `json:"waist,omitempty"`
+	Hip               *float64        `json:"hip,omitempty"`
+	Neck              *float64        `json:"neck,omitempty"`
+	Chest             *float64        `json:"chest,omitempty"`
+	LengthUnit        string          `json:"length_unit,omitempty"`
+	WeightUnit        string          `json:"weight_unit,omitempty"`
+	Derived           BodyComposition `json:"derived"`
+}
+
+// BodyComposition is what is derived from a measurement, values are left out when
+// the measurement lacks what they are derived from. LeanBodyMass is based on the
+// latest weight entry measured before the measurement, and on the measured body
+// fat percentage or else the US Navy estimate.
+type BodyComposition struct {
+	LeanBodyMass          *float64 `json:"lean_body_mass,omitempty"`
+	WaistToHipRatio       *float64 `json:"waist_to_hip_ratio,omitempty"`
+	NavyBodyFatPercentage *float64 `json:"navy_body_fat_percentage,omitempty"`
+}
+
+// MeasurementValues are the values a measurement is created or updated with, at
+// least one is needed. LengthUnit is cm or in and WeightUnit is kg or lb, both
+// default to the units of the user's unit system.
+type MeasurementValues struct {
+	BodyFatPercentage *float64 `json:"body_fat_percentage,omitempty"`
+	MuscleMass        *float64 `json:"muscle_mass,omitempty"`
+	WaterPercentage   *float64 `json:"water_percentage,omitempty"`
+	Waist             *float64 `json:"waist,omitempty"`
+	Hip               *float64 `json:"hip,omitempty"`
+	Neck              *float64 `json:"neck,omitempty"`
+	Chest             *float64 `json:"chest,omitempty"`
+	LengthUnit        string   `json:"length_unit"`
+	WeightUnit        string   `json:"weight_unit"`
+}
+
+// MeasuredAt is optional and defaults to the time of the request
+type NewMeasurementRequest struct {
+	UserID     int        `json:"user_id"`
+	MeasuredAt *time.Time `json:"measured_at,omitempty"`
+	MeasurementValues
+}
+
+// UpdateMeasurementRequest replaces the values of a measurement, values left out
+// are removed. MeasuredAt is only changed when given.
+type UpdateMeasurementRequest struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	MeasuredAt *time.Time `json:"measured_at,omitempty"`
+	MeasurementValues
+}
+
+// MeasurementHistoryRequest asks for the measurements of a user, From and To are
+// inclusive. Measurements are sorted newest first unless Order is asc.
+type MeasurementHistoryRequest struct {
+	UserID int
+	From   *time.Time
+	To     *time.Time
+	Order  string
+	Limit  int
+}
+
+type MeasurementHistory struct {
+	Measurements []Measurement `json:"measurements"`
+}
+
+// MeasurementFilter is what the storage uses to query a user's measurements
+type MeasurementFilter struct {
+	UserID     int
+	From       *time.Time
+	To         *time.Time
+	Descending bool
+	Limit      int
+}
+
+// StartWeight defaults to the latest weight entry of the user. Unit is kg or lb
+// and defaults to the unit of the user's unit system.
+type NewGoalRequest struct {
+	UserID       int        `json:"user_id"`
+	StartWeight  *float64   `json:"start_weight,omitempty"`
+	TargetWeight float64    `json:"target_weight"`
+	TargetDate   *time.Time `json:"target_date,omitempty"`
+	Unit         string     `json:"unit"`
+}
+
+// StartWeight is only changed when given, while a missing TargetDate removes it
+type UpdateGoalRequest struct {
+	UserID       int        `json:"user_id"`
+	StartWeight  *float64   `json:"start_weight,omitempty"`
+	TargetWeight float64    `json:"target_weight"`
+	TargetDate   *time.Time `json:"target_date,omitempty"`
+	Unit         string     `json:"unit"`
+}
+
+// GoalProgress is a goal along with how far the user got. Weights and rates are in
+// the unit of the goal, rates per week. A negative rate means losing weight.
+type GoalProgress struct {
+	Goal                Goal       `json:"goal"`
+	CurrentWeight       float64    `json:"current_weight"`
+	ProgressPercentage  float64    `json:"progress_percentage"`
+	Remaining           float64    `json:"remaining"`
+	RequiredWeeklyRate  *float64   `json:"required_weekly_rate,omitempty"`
+	TrendWeeklyRate     *float64   `json:"trend_weekly_rate,omitempty"`
+	ProjectedCompletion *time.Time `json:"projected_completion,omitempty"`
+}
+
+// TrendRequest asks for the trend of a user's weight between From and To, both
+// optional. Period groups the statistics per week or month and defaults to week.
+type TrendRequest struct {
+	UserID int
+	From   *time.Time
+	To     *time.Time
+	Period string
+}
+
+// Trend is the smoothed progression of a user's weight. Weights and rates are in
+// Unit, rates per week.
+type Trend struct {
+	Unit       string        `json:"unit"`
+	Points     []TrendPoint  `json:"points"`
+	WeeklyRate *float64      `json:"weekly_rate,omitempty"`
+	Periods    []TrendPeriod `json:"periods"`
+}
+
+// TrendPoint is a weight entry along with its smoothed values
+type TrendPoint struct {
+	MeasuredAt          time.Time `json:"measured_at"`
+	Weight              float64   `json:"weight"`
+	Trend               float64   `json:"trend"`
+	MovingAverage7Days  float64   `json:"moving_average_7_days"`
+	MovingAverage30Days float64   `json:"moving_average_30_days"`
+}
+
+// TrendPeriod summarises the entries measured in [Start, End). WeeklyRate is the
+// change of the trend since the previous period and is not set for the first one.
+type TrendPeriod struct {
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Entries    int       `json:"entries"`
+	Min        float64   `json:"min"`
+	Max        float64   `json:"max"`
+	Average    float64   `json:"average"`
+	Trend      float64   `json:"trend"`
+	WeeklyRate *float64  `json:"weekly_rate,omitempty"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type UpdateRoleRequest struct {
+	UserID int    `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+type ClientRequest struct {
+	CoachID  int `json:"coach_id"`
+	ClientID int `json:"client_id"`
 }
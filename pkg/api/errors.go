@@ -0,0 +1,110 @@
+package api
+
+import (
+	"sort"
+	"strings"
+)
+
+// ErrorCode says what kind of problem an error is, so handlers can map it to a
+// status and clients can react to it without parsing messages
+type ErrorCode string
+
+const (
+	CodeValidation           ErrorCode = "validation_failed"
+	CodeNotFound             ErrorCode = "not_found"
+	CodeConflict             ErrorCode = "conflict"
+	CodeUnauthorized         ErrorCode = "unauthorized"
+	CodeForbidden            ErrorCode = "forbidden"
+	CodePreconditionFailed   ErrorCode = "precondition_failed"
+	CodePreconditionRequired ErrorCode = "precondition_required"
+)
+
+// Error is returned by the services for problems the caller can do something about.
+// Any other error is unexpected, e.g. the database being unreachable.
+type Error struct {
+	Code    ErrorCode
+	Message string
+	// Fields maps the request fields that are invalid to what is wrong with them
+	Fields map[string]string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is lets errors.Is(err, ErrNotFound) match every error with the same code
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+
+	return ok && t.Message == "" && t.Code == e.Code
+}
+
+// sentinels to compare errors against with errors.Is
+var (
+	ErrValidation           = &Error{Code: CodeValidation}
+	ErrNotFound             = &Error{Code: CodeNotFound}
+	ErrConflict             = &Error{Code: CodeConflict}
+	ErrUnauthorized         = &Error{Code: CodeUnauthorized}
+	ErrForbidden            = &Error{Code: CodeForbidden}
+	ErrPreconditionFailed   = &Error{Code: CodePreconditionFailed}
+	ErrPreconditionRequired = &Error{Code: CodePreconditionRequired}
+)
+
+// ValidationError is a problem with a single field of a request
+func ValidationError(field, message string) *Error {
+	return &Error{
+		Code:    CodeValidation,
+		Message: message,
+		Fields:  map[string]string{field: message},
+	}
+}
+
+// ValidationErrors is a problem with one or more fields of a request. With a
+// single field it is the same as ValidationError.
+func ValidationErrors(fields map[string]string) *Error {
+	if len(fields) == 1 {
+		for field, message := range fields {
+			return ValidationError(field, message)
+		}
+	}
+
+	names := make([]string, 0, len(fields))
+
+	for field := range fields {
+		names = append(names, field)
+	}
+
+	sort.Strings(names)
+
+	return &Error{
+		Code:    CodeValidation,
+		Message: "invalid fields: " + strings.Join(names, ", "),
+		Fields:  fields,
+	}
+}
+
+func NotFoundError(message string) *Error {
+	return &Error{Code: CodeNotFound, Message: message}
+}
+
+func ConflictError(message string) *Error {
+	return &Error{Code: CodeConflict, Message: message}
+}
+
+func UnauthorizedError(message string) *Error {
+	return &Error{Code: CodeUnauthorized, Message: message}
+}
+
+func ForbiddenError(message string) *Error {
+	return &Error{Code: CodeForbidden, Message: message}
+}
+
+// PreconditionFailedError means the resource changed since the client read it
+func PreconditionFailedError(message string) *Error {
+	return &Error{Code: CodePreconditionFailed, Message: message}
+}
+
+// PreconditionRequiredError means the client did not say which version of the resource it changes
+func PreconditionRequiredError(message string) *Error {
+	return &Error{Code: CodePreconditionRequired, Message: message}
+}
@@ -0,0 +1,71 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"weight-tracker/pkg/api"
+)
+
+func TestErrorIs(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		target error
+		want   bool
+	}{
+		{
+			name:   "should match the sentinel with the same code",
+			err:    api.NotFoundError("storage - user does not exist"),
+			target: api.ErrNotFound,
+			want:   true,
+		}, {
+			name:   "should match a wrapped error",
+			err:    fmt.Errorf("loading user: %w", api.ConflictError("user service - user with email already exists")),
+			target: api.ErrConflict,
+			want:   true,
+		}, {
+			name:   "should not match the sentinel of another code",
+			err:    api.ValidationError("email", "user service - email required"),
+			target: api.ErrNotFound,
+			want:   false,
+		}, {
+			name:   "should not match an error with another message",
+			err:    api.NotFoundError("storage - user does not exist"),
+			target: api.NotFoundError("storage - weight does not exist"),
+			want:   false,
+		}, {
+			name:   "should not match errors that are not api errors",
+			err:    errors.New("connection refused"),
+			target: api.ErrNotFound,
+			want:   false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := errors.Is(test.err, test.target); got != test.want {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, got, test.want)
+			}
+		})
+	}
+}
+
+func TestServiceErrorCodes(t *testing.T) {
+	mockRepo := mockUserRepo{users: copyUserMap(users)}
+	mockUserService := api.NewUserService(&mockRepo)
+
+	_, err := mockUserService.New(context.Background(), api.NewUserRequest{Name: "mole", WeightGoal: "maintain", Password: "correct horse"})
+
+	var apiErr *api.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != api.CodeValidation || apiErr.Fields["email"] == "" {
+		t.Errorf("a missing email should be a validation error on the email field, got: %#v", err)
+	}
+
+	_, err = mockUserService.Delete(context.Background(), 25, api.AnyVersion)
+
+	if !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("deleting an unknown user should be a not found error, got: %#v", err)
+	}
+}
@@ -0,0 +1,303 @@
+package api
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// GoalService contains the methods of the goal service
+type GoalService interface {
+	New(ctx context.Context, request NewGoalRequest) (GoalProgress, error)
+	Update(ctx context.Context, request UpdateGoalRequest) (GoalProgress, error)
+	Progress(ctx context.Context, userID int) (GoalProgress, error)
+}
+
+// GoalRepository is what lets the goal service do db operations. GetGoal returns
+// an empty goal when the user has none.
+type GoalRepository interface {
+	Transactor
+	CreateGoal(ctx context.Context, goal Goal) (Goal, error)
+	GetGoal(ctx context.Context, userID int) (Goal, error)
+	UpdateGoal(ctx context.Context, goal Goal) (Goal, error)
+	GetUser(ctx context.Context, userID int) (User, error)
+	GetWeights(ctx context.Context, filter WeightFilter) ([]Weight, error)
+}
+
+type goalService struct {
+	storage GoalRepository
+}
+
+func NewGoalService(goalRepo GoalRepository) GoalService {
+	return &goalService{
+		storage: goalRepo,
+	}
+}
+
+// the window of weight entries the trend is calculated from
+const trendWindow = 30 * 24 * time.Hour
+
+func (g *goalService) New(ctx context.Context, request NewGoalRequest) (progress GoalProgress, err error) {
+	err = g.storage.WithTx(ctx, func(ctx context.Context) error {
+		progress, err = g.create(ctx, request)
+		return err
+	})
+
+	return
+}
+
+func (g *goalService) create(ctx context.Context, request NewGoalRequest) (GoalProgress, error) {
+	if request.UserID == 0 {
+		return GoalProgress{}, ValidationError("user_id", "goal service - user ID cannot be 0")
+	}
+
+	user, err := g.storage.GetUser(ctx, request.UserID)
+
+	if err != nil {
+		return GoalProgress{}, err
+	}
+
+	existing, err := g.storage.GetGoal(ctx, user.ID)
+
+	if err != nil {
+		return GoalProgress{}, err
+	} else if existing.ID != 0 {
+		return GoalProgress{}, ConflictError("goal service - user already has a goal")
+	}
+
+	goal := Goal{UserID: user.ID}
+
+	err = g.applyGoalRequest(ctx, &goal, user, request.StartWeight, request.TargetWeight, request.TargetDate, request.Unit)
+
+	if err != nil {
+		return GoalProgress{}, err
+	}
+
+	goal, err = g.storage.CreateGoal(ctx, goal)
+
+	if err != nil {
+		return GoalProgress{}, err
+	}
+
+	return g.progress(ctx, user, goal)
+}
+
+func (g *goalService) Update(ctx context.Context, request UpdateGoalRequest) (progress GoalProgress, err error) {
+	err = g.storage.WithTx(ctx, func(ctx context.Context) error {
+		progress, err = g.update(ctx, request)
+		return err
+	})
+
+	return
+}
+
+func (g *goalService) update(ctx context.Context, request UpdateGoalRequest) (GoalProgress, error) {
+	if request.UserID == 0 {
+		return GoalProgress{}, ValidationError("user_id", "goal service - user ID cannot be 0")
+	}
+
+	user, err := g.storage.GetUser(ctx, request.UserID)
+
+	if err != nil {
+		return GoalProgress{}, err
+	}
+
+	goal, err := g.storage.GetGoal(ctx, user.ID)
+
+	if err != nil {
+		return GoalProgress{}, err
+	} else if goal.ID == 0 {
+		return GoalProgress{}, NotFoundError("goal service - user has no goal")
+	}
+
+	err = g.applyGoalRequest(ctx, &goal, user, request.StartWeight, request.TargetWeight, request.TargetDate, request.Unit)
+
+	if err != nil {
+		return GoalProgress{}, err
+	}
+
+	goal, err = g.storage.UpdateGoal(ctx, goal)
+
+	if err != nil {
+		return GoalProgress{}, err
+	}
+
+	return g.progress(ctx, user, goal)
+}
+
+func (g *goalService) Progress(ctx context.Context, userID int) (GoalProgress, error) {
+	user, err := g.storage.GetUser(ctx, userID)
+
+	if err != nil {
+		return GoalProgress{}, err
+	}
+
+	goal, err := g.storage.GetGoal(ctx, user.ID)
+
+	if err != nil {
+		return GoalProgress{}, err
+	} else if goal.ID == 0 {
+		return GoalProgress{}, NotFoundError("goal service - user has no goal")
+	}
+
+	return g.progress(ctx, user, goal)
+}
+
+// applyGoalRequest validates the submitted values and sets them on the goal in kilograms.
+// The start weight is only changed when given, or when the goal does not have one yet.
+func (g *goalService) applyGoalRequest(ctx context.Context, goal *Goal, user User, startWeight *float64, targetWeight float64, targetDate *time.Time, unit string) error {
+	if err := validateGoal(startWeight, targetWeight, targetDate, unit, user.UnitSystem); err != nil {
+		return err
+	}
+
+	target, err := weightInKilograms(targetWeight, unit, user)
+
+	if err != nil {
+		return err
+	}
+
+	goal.TargetWeight = target
+	goal.TargetDate = targetDate
+
+	if startWeight != nil {
+		goal.StartWeight, err = weightInKilograms(*startWeight, unit, user)
+
+		if err != nil {
+			return err
+		}
+	} else if goal.StartWeight == 0 {
+		latest, err := g.latestWeight(ctx, user.ID)
+
+		if err != nil {
+			return err
+		} else if latest == nil {
+			return ValidationError("start_weight", "goal service - start weight required when there are no weight entries")
+		}
+
+		goal.StartWeight = latest.Weight
+	}
+
+	return nil
+}
+
+func (g *goalService) latestWeight(ctx context.Context, userID int) (*Weight, error) {
+	weights, err := g.storage.GetWeights(ctx, WeightFilter{UserID: userID, Descending: true, Limit: 1})
+
+	if err != nil || len(weights) == 0 {
+		return nil, err
+	}
+
+	return &weights[0], nil
+}
+
+// progress compares the goal to the latest weight entry and projects when the
+// goal will be reached, based on the trend of the recent entries
+func (g *goalService) progress(ctx context.Context, user User, goal Goal) (GoalProgress, error) {
+	now := time.Now()
+	from := now.Add(-trendWindow)
+
+	recent, err := g.storage.GetWeights(ctx, WeightFilter{UserID: user.ID, From: &from})
+
+	if err != nil {
+		return GoalProgress{}, err
+	}
+
+	current := goal.StartWeight
+
+	latest, err := g.latestWeight(ctx, user.ID)
+
+	if err != nil {
+		return GoalProgress{}, err
+	} else if latest != nil {
+		current = latest.Weight
+	}
+
+	progress := GoalProgress{
+		CurrentWeight:      current,
+		Remaining:          goal.TargetWeight - current,
+		ProgressPercentage: progressPercentage(goal.StartWeight, goal.TargetWeight, current),
+	}
+
+	if goal.TargetDate != nil && goal.TargetDate.After(now) {
+		weeks := goal.TargetDate.Sub(now).Hours() / (24 * 7)
+		required := progress.Remaining / weeks
+		progress.RequiredWeeklyRate = &required
+	}
+
+	if trend, ok := weeklyTrend(recent); ok {
+		progress.TrendWeeklyRate = &trend
+
+		// only project when the trend moves towards the target
+		if progress.Remaining == 0 {
+			progress.ProjectedCompletion = &now
+		} else if trend != 0 && math.Signbit(trend) == math.Signbit(progress.Remaining) {
+			weeks := progress.Remaining / trend
+			completion := now.Add(time.Duration(weeks * 7 * 24 * float64(time.Hour)))
+			progress.ProjectedCompletion = &completion
+		}
+	}
+
+	return progress.inUnits(user.UnitSystem, goal), nil
+}
+
+// progressPercentage is how much of the way from start to target was covered, between 0 and 100
+func progressPercentage(start, target, current float64) float64 {
+	if start == target {
+		return 100
+	}
+
+	percentage := (start - current) / (start - target) * 100
+
+	return round(math.Max(0, math.Min(100, percentage)))
+}
+
+// weeklyTrend fits a line through the weight entries with least squares and
+// returns its slope in kg per week. At least two entries on different moments are needed.
+func weeklyTrend(weights []Weight) (float64, bool) {
+	if len(weights) < 2 {
+		return 0, false
+	}
+
+	origin := weights[0].MeasuredAt
+	var sumX, sumY, sumXY, sumXX float64
+
+	for _, weight := range weights {
+		x := weight.MeasuredAt.Sub(origin).Hours() / (24 * 7)
+		sumX += x
+		sumY += weight.Weight
+		sumXY += x * weight.Weight
+		sumXX += x * x
+	}
+
+	n := float64(len(weights))
+	denominator := n*sumXX - sumX*sumX
+
+	if denominator == 0 {
+		return 0, false
+	}
+
+	return (n*sumXY - sumX*sumY) / denominator, true
+}
+
+// inUnits renders the weights and rates of the progress in the given unit system
+func (p GoalProgress) inUnits(unitSystem string, goal Goal) GoalProgress {
+	p.Goal = goal
+	p.Goal.StartWeight = fromKilograms(goal.StartWeight, unitSystem)
+	p.Goal.TargetWeight = fromKilograms(goal.TargetWeight, unitSystem)
+	p.Goal.Unit = weightUnit(unitSystem)
+
+	p.CurrentWeight = fromKilograms(p.CurrentWeight, unitSystem)
+	p.Remaining = fromKilograms(p.Remaining, unitSystem)
+
+	if p.RequiredWeeklyRate != nil {
+		rate := fromKilograms(*p.RequiredWeeklyRate, unitSystem)
+		p.RequiredWeeklyRate = &rate
+	}
+
+	if p.TrendWeeklyRate != nil {
+		rate := fromKilograms(*p.TrendWeeklyRate, unitSystem)
+		p.TrendWeeklyRate = &rate
+	}
+
+	return p
+}
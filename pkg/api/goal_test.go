@@ -0,0 +1,237 @@
+package api_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+	"weight-tracker/pkg/api"
+)
+
+// the goal repo reuses the users and weights of the weight repo mock
+type mockGoalRepo struct {
+	mockWeightRepo
+	goals map[int]api.Goal
+}
+
+func (m mockGoalRepo) CreateGoal(ctx context.Context, goal api.Goal) (api.Goal, error) {
+	goal.ID = len(m.goals) + 1
+	m.goals[goal.UserID] = goal
+
+	return goal, nil
+}
+
+func (m mockGoalRepo) GetGoal(ctx context.Context, userID int) (api.Goal, error) {
+	return m.goals[userID], nil
+}
+
+func (m mockGoalRepo) UpdateGoal(ctx context.Context, goal api.Goal) (api.Goal, error) {
+	m.goals[goal.UserID] = goal
+
+	return goal, nil
+}
+
+// txGoalRepo records which repository calls were made within a transaction
+type txGoalRepo struct {
+	mockGoalRepo
+	inTx map[string]bool
+}
+
+func (m txGoalRepo) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(context.WithValue(ctx, mockTxKey{}, true))
+}
+
+func (m txGoalRepo) GetGoal(ctx context.Context, userID int) (api.Goal, error) {
+	m.inTx["GetGoal"] = ctx.Value(mockTxKey{}) != nil
+	return m.mockGoalRepo.GetGoal(ctx, userID)
+}
+
+func (m txGoalRepo) CreateGoal(ctx context.Context, goal api.Goal) (api.Goal, error) {
+	m.inTx["CreateGoal"] = ctx.Value(mockTxKey{}) != nil
+	return m.mockGoalRepo.CreateGoal(ctx, goal)
+}
+
+func (m txGoalRepo) UpdateGoal(ctx context.Context, goal api.Goal) (api.Goal, error) {
+	m.inTx["UpdateGoal"] = ctx.Value(mockTxKey{}) != nil
+	return m.mockGoalRepo.UpdateGoal(ctx, goal)
+}
+
+func TestChangeGoalInTransaction(t *testing.T) {
+	startWeight := 80.0
+	mockRepo := txGoalRepo{mockGoalRepo{mockWeightRepo{}, map[int]api.Goal{}}, map[string]bool{}}
+	mockGoalService := api.NewGoalService(&mockRepo)
+
+	_, err := mockGoalService.New(context.Background(), api.NewGoalRequest{UserID: 1, StartWeight: &startWeight, TargetWeight: 72})
+
+	if err != nil {
+		t.Fatalf("creating the goal failed: %v", err)
+	}
+
+	_, err = mockGoalService.Update(context.Background(), api.UpdateGoalRequest{UserID: 1, TargetWeight: 70})
+
+	if err != nil {
+		t.Fatalf("updating the goal failed: %v", err)
+	}
+
+	want := map[string]bool{"GetGoal": true, "CreateGoal": true, "UpdateGoal": true}
+
+	if !reflect.DeepEqual(mockRepo.inTx, want) {
+		t.Errorf("the goal should be read and written in one transaction. got: %v, wanted: %v", mockRepo.inTx, want)
+	}
+}
+
+func TestCreateGoal(t *testing.T) {
+	startWeight := 80.0
+	heavyStartWeight := 5000.0
+	yesterday := time.Now().Add(-24 * time.Hour)
+
+	tests := []struct {
+		name       string
+		weights    []api.Weight
+		goals      map[int]api.Goal
+		request    api.NewGoalRequest
+		want_goal  api.Goal
+		want_error error
+	}{
+		{
+			name:    "should create a goal with the given start weight",
+			goals:   map[int]api.Goal{},
+			request: api.NewGoalRequest{UserID: 1, StartWeight: &startWeight, TargetWeight: 72},
+			want_goal: api.Goal{
+				ID: 1, UserID: 1, StartWeight: 80, TargetWeight: 72, Unit: "kg",
+			},
+		}, {
+			name:    "should use the latest weight entry as start weight",
+			weights: []api.Weight{{ID: 1, UserID: 1, Weight: 78.5, MeasuredAt: yesterday}},
+			goals:   map[int]api.Goal{},
+			request: api.NewGoalRequest{UserID: 1, TargetWeight: 72},
+			want_goal: api.Goal{
+				ID: 1, UserID: 1, StartWeight: 78.5, TargetWeight: 72, Unit: "kg",
+			},
+		}, {
+			name:       "should return an error without start weight or weight entries",
+			goals:      map[int]api.Goal{},
+			request:    api.NewGoalRequest{UserID: 1, TargetWeight: 72},
+			want_error: api.ValidationError("start_weight", "goal service - start weight required when there are no weight entries"),
+		}, {
+			name:       "should return an error when the user already has a goal",
+			goals:      map[int]api.Goal{1: {ID: 1, UserID: 1, StartWeight: 80, TargetWeight: 75}},
+			request:    api.NewGoalRequest{UserID: 1, StartWeight: &startWeight, TargetWeight: 72},
+			want_error: api.ConflictError("goal service - user already has a goal"),
+		}, {
+			name:       "should return an error when the target date has passed",
+			goals:      map[int]api.Goal{},
+			request:    api.NewGoalRequest{UserID: 1, StartWeight: &startWeight, TargetWeight: 72, TargetDate: &yesterday},
+			want_error: api.ValidationError("target_date", "goal service - target date must be in the future"),
+		}, {
+			name:       "should return an error for an implausible target weight",
+			goals:      map[int]api.Goal{},
+			request:    api.NewGoalRequest{UserID: 1, StartWeight: &startWeight, TargetWeight: 10},
+			want_error: api.ValidationError("target_weight", "goal service - target weight must be between 20 and 650 kg"),
+		}, {
+			name:       "should report an implausible start weight in the unit of the request",
+			goals:      map[int]api.Goal{},
+			request:    api.NewGoalRequest{UserID: 1, StartWeight: &heavyStartWeight, TargetWeight: 160, Unit: "lb"},
+			want_error: api.ValidationError("start_weight", "goal service - start weight must be between 44.09 and 1433 lb"),
+		}, {
+			name:       "should return an error when the user does not exist",
+			goals:      map[int]api.Goal{},
+			request:    api.NewGoalRequest{UserID: 2, StartWeight: &startWeight, TargetWeight: 72},
+			want_error: api.NotFoundError("storage - user does not exist"),
+		},
+	}
+
+	for _, test := range tests {
+		mockRepo := mockGoalRepo{mockWeightRepo{weights: test.weights}, test.goals}
+		mockGoalService := api.NewGoalService(&mockRepo)
+
+		t.Run(test.name, func(t *testing.T) {
+			progress, err := mockGoalService.New(context.Background(), test.request)
+
+			if !reflect.DeepEqual(err, test.want_error) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want_error)
+			}
+
+			if !reflect.DeepEqual(progress.Goal, test.want_goal) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, progress.Goal, test.want_goal)
+			}
+		})
+	}
+}
+
+func TestUpdateGoal(t *testing.T) {
+	targetDate := time.Now().Add(10 * 7 * 24 * time.Hour)
+
+	mockRepo := mockGoalRepo{mockWeightRepo{}, map[int]api.Goal{}}
+	mockGoalService := api.NewGoalService(&mockRepo)
+
+	_, err := mockGoalService.Update(context.Background(), api.UpdateGoalRequest{UserID: 1, TargetWeight: 70})
+	want := api.NotFoundError("goal service - user has no goal")
+
+	if !reflect.DeepEqual(err, want) {
+		t.Fatalf("got: %v, wanted: %v", err, want)
+	}
+
+	mockRepo.goals[1] = api.Goal{ID: 1, UserID: 1, StartWeight: 80, TargetWeight: 75}
+
+	progress, err := mockGoalService.Update(context.Background(), api.UpdateGoalRequest{UserID: 1, TargetWeight: 70, TargetDate: &targetDate})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the start weight is kept, and 10 kg have to be lost in 10 weeks
+	if progress.Goal.StartWeight != 80 || progress.Goal.TargetWeight != 70 {
+		t.Errorf("got goal: %v", progress.Goal)
+	}
+
+	if progress.RequiredWeeklyRate == nil || *progress.RequiredWeeklyRate != -1 {
+		t.Errorf("got required weekly rate: %v, wanted: -1", progress.RequiredWeeklyRate)
+	}
+}
+
+func TestGoalProgress(t *testing.T) {
+	now := time.Now()
+	daysAgo := func(days int) time.Time {
+		return now.Add(-time.Duration(days) * 24 * time.Hour)
+	}
+
+	// losing a kilogram a week
+	weights := []api.Weight{
+		{ID: 1, UserID: 1, Weight: 80, MeasuredAt: daysAgo(21)},
+		{ID: 2, UserID: 1, Weight: 79, MeasuredAt: daysAgo(14)},
+		{ID: 3, UserID: 1, Weight: 78, MeasuredAt: daysAgo(7)},
+		{ID: 4, UserID: 1, Weight: 77, MeasuredAt: daysAgo(0)},
+	}
+
+	mockRepo := mockGoalRepo{
+		mockWeightRepo{weights: weights},
+		map[int]api.Goal{1: {ID: 1, UserID: 1, StartWeight: 80, TargetWeight: 72}},
+	}
+	mockGoalService := api.NewGoalService(&mockRepo)
+
+	progress, err := mockGoalService.Progress(context.Background(), 1)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if progress.CurrentWeight != 77 || progress.Remaining != -5 || progress.ProgressPercentage != 37.5 {
+		t.Errorf("got current: %v, remaining: %v, progress: %v", progress.CurrentWeight, progress.Remaining, progress.ProgressPercentage)
+	}
+
+	if progress.TrendWeeklyRate == nil || *progress.TrendWeeklyRate != -1 {
+		t.Errorf("got trend: %v, wanted: -1", progress.TrendWeeklyRate)
+	}
+
+	// five kilograms to go at a kilogram a week
+	want := now.Add(5 * 7 * 24 * time.Hour)
+
+	if progress.ProjectedCompletion == nil || progress.ProjectedCompletion.Sub(want) > time.Minute || want.Sub(*progress.ProjectedCompletion) > time.Minute {
+		t.Errorf("got projected completion: %v, wanted: %v", progress.ProjectedCompletion, want)
+	}
+
+	if progress.RequiredWeeklyRate != nil {
+		t.Errorf("got required weekly rate: %v, wanted none without a target date", *progress.RequiredWeeklyRate)
+	}
+}
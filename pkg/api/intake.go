@@ -0,0 +1,132 @@
+package api
+
+import (
+	"fmt"
+	"math"
+)
+
+// roughly the energy stored in a kilogram of body fat
+const kcalPerKilogram = 7700
+
+// the weekly rates of change we are willing to plan for, in kg per week
+const (
+	maxWeeklyLoss = -1.0
+	maxWeeklyGain = 0.5
+)
+
+// minimum daily intakes, below which a diet should be medically supervised
+const (
+	minimumIntakeFemale = 1200
+	minimumIntakeMale   = 1500
+)
+
+// CaloricTarget is the daily caloric intake planned for a user. Delta is the
+// deficit (negative) or surplus (positive) applied to the maintenance calories.
+// Warnings explain any adjustment made to keep the plan safe.
+type CaloricTarget struct {
+	Maintenance        int      `json:"maintenance"`
+	Delta              int      `json:"delta"`
+	DailyCaloricIntake int      `json:"daily_caloric_intake"`
+	Warnings           []string `json:"warnings,omitempty"`
+}
+
+// CaloricTarget converts the weekly rate of change of a user, in kg per week, into
+// a daily caloric intake. Users without a weekly rate get the deficit or surplus
+// of their weight goal preset instead. Rates outside of what is considered safe
+// are clamped and the intake never drops below the minimum for the user's sex.
+// Warnings give rates in the weight unit of the unit system.
+func (w *weightService) CaloricTarget(BMR, activityLevel int, sex, weightGoal string, weeklyRate *float64, unitSystem string) (CaloricTarget, error) {
+	maintenance, err := maintenanceCalories(BMR, activityLevel)
+
+	if err != nil {
+		return CaloricTarget{}, err
+	}
+
+	minimum, err := minimumIntake(sex)
+
+	if err != nil {
+		return CaloricTarget{}, err
+	}
+
+	target := CaloricTarget{Maintenance: maintenance}
+
+	if weeklyRate == nil {
+		target.Delta, err = goalPresetDelta(weightGoal)
+
+		if err != nil {
+			return CaloricTarget{}, err
+		}
+	} else {
+		rate := math.Max(maxWeeklyLoss, math.Min(maxWeeklyGain, *weeklyRate))
+
+		if rate != *weeklyRate {
+			unit := weightUnit(unitSystem)
+			target.Warnings = append(target.Warnings, fmt.Sprintf(
+				"the requested rate of %.2f %s/week was clamped to %.2f %s/week",
+				fromKilograms(*weeklyRate, unitSystem), unit, fromKilograms(rate, unitSystem), unit,
+			))
+		}
+
+		target.Delta = weeklyRateDelta(rate)
+	}
+
+	target.DailyCaloricIntake = maintenance + target.Delta
+
+	if target.DailyCaloricIntake < minimum {
+		target.Warnings = append(target.Warnings, fmt.Sprintf(
+			"the daily intake was raised to the minimum of %d kcal", minimum,
+		))
+		target.DailyCaloricIntake = minimum
+		target.Delta = minimum - maintenance
+	}
+
+	return target, nil
+}
+
+// maintenanceCalories is the BMR scaled by the activity level, the calories needed to keep the current weight
+func maintenanceCalories(BMR, activityLevel int) (int, error) {
+	switch activityLevel {
+	case 1:
+		return int(float64(BMR) * veryLowActivity), nil
+	case 2:
+		return int(float64(BMR) * lightActivity), nil
+	case 3:
+		return int(float64(BMR) * moderateActivity), nil
+	case 4:
+		return int(float64(BMR) * highActivity), nil
+	case 5:
+		return int(float64(BMR) * veryHighActivity), nil
+	default:
+		return 0, ValidationError("activity_level", "invalid variable activityLevel - needs to be 1, 2, 3, 4 or 5")
+	}
+}
+
+// goalPresetDelta is the fixed daily deficit or surplus of a weight goal
+func goalPresetDelta(weightGoal string) (int, error) {
+	switch weightGoal {
+	case "gain":
+		return 500, nil
+	case "loose":
+		return -500, nil
+	case "maintain":
+		return 0, nil
+	default:
+		return 0, ValidationError("weight_goal", "invalid weight goal provided - must be gain, loose or maintain")
+	}
+}
+
+// weeklyRateDelta converts a rate of change in kg per week into a daily deficit or surplus
+func weeklyRateDelta(weeklyRate float64) int {
+	return int(math.Round(weeklyRate * kcalPerKilogram / 7))
+}
+
+func minimumIntake(sex string) (int, error) {
+	switch sex {
+	case "male":
+		return minimumIntakeMale, nil
+	case "female":
+		return minimumIntakeFemale, nil
+	default:
+		return 0, ValidationError("sex", "invalid variable sex - needs to be either male or female")
+	}
+}
@@ -0,0 +1,414 @@
+package api
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+)
+
+// MeasurementService contains the methods of the measurement service. Measurements
+// are always looked up through the user they belong to.
+type MeasurementService interface {
+	New(ctx context.Context, request NewMeasurementRequest) (Measurement, error)
+	Get(ctx context.Context, userID, measurementID int) (Measurement, error)
+	Update(ctx context.Context, request UpdateMeasurementRequest) (Measurement, error)
+	Delete(ctx context.Context, userID, measurementID int) (deletedMeasurementID int, err error)
+	History(ctx context.Context, request MeasurementHistoryRequest) (MeasurementHistory, error)
+}
+
+// MeasurementRepository is what lets the measurement service do db operations
+type MeasurementRepository interface {
+	Transactor
+	CreateMeasurement(ctx context.Context, m Measurement) (Measurement, error)
+	GetMeasurement(ctx context.Context, measurementID int) (Measurement, error)
+	UpdateMeasurement(ctx context.Context, m Measurement) (Measurement, error)
+	DeleteMeasurement(ctx context.Context, measurementID int) (deletedMeasurementID int, err error)
+	GetMeasurements(ctx context.Context, filter MeasurementFilter) ([]Measurement, error)
+	GetUser(ctx context.Context, userID int) (User, error)
+	GetWeights(ctx context.Context, filter WeightFilter) ([]Weight, error)
+}
+
+type measurementService struct {
+	storage MeasurementRepository
+}
+
+func NewMeasurementService(measurementRepo MeasurementRepository) MeasurementService {
+	return &measurementService{
+		storage: measurementRepo,
+	}
+}
+
+var errMeasurementNotFound = NotFoundError("measurement service - measurement does not exist")
+
+// New stores a measurement of a user. It is returned in the user's units, along
+// with the values derived from it.
+func (m *measurementService) New(ctx context.Context, request NewMeasurementRequest) (created Measurement, err error) {
+	err = m.storage.WithTx(ctx, func(ctx context.Context) error {
+		created, err = m.create(ctx, request)
+		return err
+	})
+
+	return
+}
+
+func (m *measurementService) create(ctx context.Context, request NewMeasurementRequest) (Measurement, error) {
+	var user User
+	var err error
+
+	// the values are checked in the units of the user
+	if request.UserID != 0 {
+		user, err = m.storage.GetUser(ctx, request.UserID)
+
+		if err != nil {
+			return Measurement{}, err
+		}
+	}
+
+	if err = validateNewMeasurement(request, user.UnitSystem); err != nil {
+		return Measurement{}, err
+	}
+
+	measurement := Measurement{UserID: user.ID}
+	measurement.MeasuredAt, err = measuredAtOrNow(request.MeasuredAt)
+
+	if err != nil {
+		return Measurement{}, err
+	}
+
+	if err = setMeasurementValues(&measurement, request.MeasurementValues, user); err != nil {
+		return Measurement{}, err
+	}
+
+	created, err := m.storage.CreateMeasurement(ctx, measurement)
+
+	if err != nil {
+		return Measurement{}, err
+	}
+
+	return m.render(ctx, created, user)
+}
+
+func (m *measurementService) Get(ctx context.Context, userID, measurementID int) (Measurement, error) {
+	measurement, user, err := m.find(ctx, userID, measurementID)
+
+	if err != nil {
+		return Measurement{}, err
+	}
+
+	return m.render(ctx, measurement, user)
+}
+
+// Update replaces the values of a measurement, reading and writing it in one transaction
+func (m *measurementService) Update(ctx context.Context, request UpdateMeasurementRequest) (updated Measurement, err error) {
+	err = m.storage.WithTx(ctx, func(ctx context.Context) error {
+		updated, err = m.update(ctx, request)
+		return err
+	})
+
+	return
+}
+
+func (m *measurementService) update(ctx context.Context, request UpdateMeasurementRequest) (Measurement, error) {
+	measurement, user, err := m.find(ctx, request.UserID, request.ID)
+
+	if err != nil {
+		return Measurement{}, err
+	}
+
+	if err = validateUpdateMeasurement(request, user.UnitSystem); err != nil {
+		return Measurement{}, err
+	}
+
+	if request.MeasuredAt != nil {
+		measurement.MeasuredAt, err = measuredAtOrNow(request.MeasuredAt)
+
+		if err != nil {
+			return Measurement{}, err
+		}
+	}
+
+	if err = setMeasurementValues(&measurement, request.MeasurementValues, user); err != nil {
+		return Measurement{}, err
+	}
+
+	updated, err := m.storage.UpdateMeasurement(ctx, measurement)
+
+	if err != nil {
+		return Measurement{}, err
+	}
+
+	return m.render(ctx, updated, user)
+}
+
+func (m *measurementService) Delete(ctx context.Context, userID, measurementID int) (deletedMeasurementID int, err error) {
+	err = m.storage.WithTx(ctx, func(ctx context.Context) error {
+		if _, _, err := m.find(ctx, userID, measurementID); err != nil {
+			return err
+		}
+
+		deletedMeasurementID, err = m.storage.DeleteMeasurement(ctx, measurementID)
+
+		if err == nil && deletedMeasurementID == 0 {
+			err = errMeasurementNotFound
+		}
+
+		return err
+	})
+
+	return
+}
+
+// History returns the measurements of a user, filtered by the requested dates
+func (m *measurementService) History(ctx context.Context, request MeasurementHistoryRequest) (MeasurementHistory, error) {
+	if request.UserID == 0 {
+		return MeasurementHistory{}, ValidationError("user_id", "measurement service - user ID cannot be 0")
+	}
+
+	if request.From != nil && request.To != nil && request.From.After(*request.To) {
+		return MeasurementHistory{}, ValidationError("from", "measurement service - from must not be after to")
+	}
+
+	filter := MeasurementFilter{
+		UserID: request.UserID,
+		From:   request.From,
+		To:     request.To,
+		Limit:  request.Limit,
+	}
+
+	switch request.Order {
+	case "", "desc":
+		filter.Descending = true
+	case "asc":
+		filter.Descending = false
+	default:
+		return MeasurementHistory{}, ValidationError("order", "measurement service - order must be asc or desc")
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultHistoryLimit
+	} else if filter.Limit > maxHistoryLimit {
+		filter.Limit = maxHistoryLimit
+	}
+
+	user, err := m.storage.GetUser(ctx, request.UserID)
+
+	if err != nil {
+		return MeasurementHistory{}, err
+	}
+
+	measurements, err := m.storage.GetMeasurements(ctx, filter)
+
+	if err != nil {
+		return MeasurementHistory{}, err
+	}
+
+	history := MeasurementHistory{Measurements: []Measurement{}}
+
+	if len(measurements) == 0 {
+		return history, nil
+	}
+
+	weights, err := m.weightsDuring(ctx, user.ID, measurements)
+
+	if err != nil {
+		return MeasurementHistory{}, err
+	}
+
+	for _, measurement := range measurements {
+		history.Measurements = append(history.Measurements, withBodyComposition(measurement, user, latestWeightAt(weights, measurement.MeasuredAt)))
+	}
+
+	return history, nil
+}
+
+// weightsDuring returns the weights of a user from the latest one at or before
+// the first of the measurements up to the last of them, oldest first. That is
+// every weight the body composition of the measurements can be based on.
+func (m *measurementService) weightsDuring(ctx context.Context, userID int, measurements []Measurement) ([]Weight, error) {
+	first, last := measurements[0].MeasuredAt, measurements[0].MeasuredAt
+
+	for _, measurement := range measurements[1:] {
+		if measurement.MeasuredAt.Before(first) {
+			first = measurement.MeasuredAt
+		} else if measurement.MeasuredAt.After(last) {
+			last = measurement.MeasuredAt
+		}
+	}
+
+	before, err := m.storage.GetWeights(ctx, WeightFilter{UserID: userID, To: &first, Descending: true, Limit: 1})
+
+	if err != nil {
+		return nil, err
+	}
+
+	during, err := m.storage.GetWeights(ctx, WeightFilter{UserID: userID, From: &first, To: &last})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(before, during...), nil
+}
+
+// latestWeightAt picks the weight in kilograms measured last at or before the
+// given time, from weights ordered oldest first. It is nil when there is none.
+func latestWeightAt(weights []Weight, at time.Time) *float64 {
+	after := sort.Search(len(weights), func(i int) bool {
+		return weights[i].MeasuredAt.After(at)
+	})
+
+	if after == 0 {
+		return nil
+	}
+
+	return &weights[after-1].Weight
+}
+
+// find returns a measurement along with its owner. Measurements of other users
+// are reported as missing, so their IDs give nothing away.
+func (m *measurementService) find(ctx context.Context, userID, measurementID int) (Measurement, User, error) {
+	measurement, err := m.storage.GetMeasurement(ctx, measurementID)
+
+	if err != nil {
+		return Measurement{}, User{}, err
+	} else if measurement.UserID != userID {
+		return Measurement{}, User{}, errMeasurementNotFound
+	}
+
+	user, err := m.storage.GetUser(ctx, userID)
+
+	if err != nil {
+		return Measurement{}, User{}, err
+	}
+
+	return measurement, user, nil
+}
+
+// render derives the body composition of a measurement from the latest weight at
+// or before it and converts it to the units of the user
+func (m *measurementService) render(ctx context.Context, measurement Measurement, user User) (Measurement, error) {
+	weights, err := m.storage.GetWeights(ctx, WeightFilter{UserID: user.ID, To: &measurement.MeasuredAt, Descending: true, Limit: 1})
+
+	if err != nil {
+		return Measurement{}, err
+	}
+
+	return withBodyComposition(measurement, user, latestWeightAt(weights, measurement.MeasuredAt)), nil
+}
+
+// withBodyComposition derives the body composition of a measurement from the
+// weight in kilograms, when known, and converts it to the units of the user
+func withBodyComposition(measurement Measurement, user User, weight *float64) Measurement {
+	measurement.Derived = bodyComposition(measurement, user, weight)
+
+	return measurement.inUnits(user.UnitSystem)
+}
+
+// setMeasurementValues replaces the values of the measurement with the ones of
+// the request, converted to centimeters and kilograms
+func setMeasurementValues(measurement *Measurement, values MeasurementValues, user User) error {
+	lengthUnit, massUnit := values.LengthUnit, values.WeightUnit
+
+	if lengthUnit == "" {
+		lengthUnit = heightUnit(user.UnitSystem)
+	}
+
+	if massUnit == "" {
+		massUnit = weightUnit(user.UnitSystem)
+	}
+
+	var err error
+
+	measurement.BodyFatPercentage = values.BodyFatPercentage
+	measurement.WaterPercentage = values.WaterPercentage
+
+	if measurement.MuscleMass, err = optionalIn(values.MuscleMass, massUnit, toKilograms); err != nil {
+		return err
+	}
+
+	for _, length := range []struct {
+		value  *float64
+		stored **float64
+	}{
+		{values.Waist, &measurement.Waist},
+		{values.Hip, &measurement.Hip},
+		{values.Neck, &measurement.Neck},
+		{values.Chest, &measurement.Chest},
+	} {
+		if *length.stored, err = optionalIn(length.value, lengthUnit, toCentimeters); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// optionalIn converts a value that may be missing from unit with convert
+func optionalIn(value *float64, unit string, convert func(float64, string) (float64, error)) (*float64, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	converted, err := convert(*value, unit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &converted, nil
+}
+
+// bodyComposition derives what it can from a measurement in metric units, the
+// height of the user and, when known, the weight of the user in kilograms
+func bodyComposition(measurement Measurement, user User, weight *float64) BodyComposition {
+	var composition BodyComposition
+
+	if measurement.Waist != nil && measurement.Hip != nil {
+		ratio := round(*measurement.Waist / *measurement.Hip)
+		composition.WaistToHipRatio = &ratio
+	}
+
+	if measurement.Waist != nil && measurement.Neck != nil {
+		if estimate, ok := navyBodyFatPercentage(user.Sex, user.Height, *measurement.Waist, *measurement.Neck, measurement.Hip); ok {
+			composition.NavyBodyFatPercentage = &estimate
+		}
+	}
+
+	bodyFat := measurement.BodyFatPercentage
+
+	if bodyFat == nil {
+		bodyFat = composition.NavyBodyFatPercentage
+	}
+
+	if bodyFat != nil && weight != nil {
+		leanBodyMass := round(*weight * (1 - *bodyFat/100))
+		composition.LeanBodyMass = &leanBodyMass
+	}
+
+	return composition
+}
+
+// navyBodyFatPercentage estimates the body fat percentage with the circumference
+// method of the US Navy, from lengths in centimeters. Women need a hip
+// measurement as well. Measurements the formula cannot make sense of give no estimate.
+func navyBodyFatPercentage(sex string, height, waist, neck float64, hip *float64) (float64, bool) {
+	var density float64
+
+	switch {
+	case sex == "male" && waist > neck:
+		density = 1.0324 - 0.19077*math.Log10(waist-neck) + 0.15456*math.Log10(height)
+	case sex == "female" && hip != nil && waist+*hip > neck:
+		density = 1.29579 - 0.35004*math.Log10(waist+*hip-neck) + 0.22100*math.Log10(height)
+	default:
+		return 0, false
+	}
+
+	percentage := round(495/density - 450)
+
+	if percentage <= 0 || percentage >= 100 {
+		return 0, false
+	}
+
+	return percentage, true
+}
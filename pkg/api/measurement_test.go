@@ -0,0 +1,221 @@
+package api_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+	"weight-tracker/pkg/api"
+)
+
+// the measurement repo reuses the users and weights of the weight repo mock
+type mockMeasurementRepo struct {
+	mockWeightRepo
+	measurements map[int]api.Measurement
+}
+
+func (m mockMeasurementRepo) CreateMeasurement(ctx context.Context, measurement api.Measurement) (api.Measurement, error) {
+	measurement.ID = len(m.measurements) + 1
+	m.measurements[measurement.ID] = measurement
+
+	return measurement, nil
+}
+
+func (m mockMeasurementRepo) GetMeasurement(ctx context.Context, measurementID int) (api.Measurement, error) {
+	measurement, ok := m.measurements[measurementID]
+
+	if !ok {
+		return api.Measurement{}, api.NotFoundError("storage - measurement does not exist")
+	}
+
+	return measurement, nil
+}
+
+func (m mockMeasurementRepo) UpdateMeasurement(ctx context.Context, measurement api.Measurement) (api.Measurement, error) {
+	m.measurements[measurement.ID] = measurement
+
+	return measurement, nil
+}
+
+func (m mockMeasurementRepo) DeleteMeasurement(ctx context.Context, measurementID int) (int, error) {
+	if _, ok := m.measurements[measurementID]; !ok {
+		return 0, nil
+	}
+
+	delete(m.measurements, measurementID)
+
+	return measurementID, nil
+}
+
+func (m mockMeasurementRepo) GetMeasurements(ctx context.Context, filter api.MeasurementFilter) (measurements []api.Measurement, err error) {
+	for _, measurement := range m.measurements {
+		if measurement.UserID == filter.UserID {
+			measurements = append(measurements, measurement)
+		}
+	}
+
+	return
+}
+
+func TestCreateMeasurement(t *testing.T) {
+	measuredAt := time.Date(2022, 3, 1, 8, 0, 0, 0, time.UTC)
+	value := func(v float64) *float64 { return &v }
+
+	// the mocked user is a woman of 185 cm
+	weights := []api.Weight{
+		{ID: 1, UserID: 1, Weight: 90, MeasuredAt: measuredAt.Add(24 * time.Hour)},
+		{ID: 2, UserID: 1, Weight: 80, MeasuredAt: measuredAt.Add(-24 * time.Hour)},
+	}
+
+	tests := []struct {
+		name             string
+		request          api.NewMeasurementRequest
+		want_measurement api.Measurement
+		want_error       error
+	}{
+		{
+			name: "should derive the body composition from the tape measurements",
+			request: api.NewMeasurementRequest{UserID: 1, MeasuredAt: &measuredAt, MeasurementValues: api.MeasurementValues{
+				Waist: value(80), Hip: value(100), Neck: value(34),
+			}},
+			want_measurement: api.Measurement{
+				ID: 1, UserID: 1, MeasuredAt: measuredAt, Waist: value(80), Hip: value(100), Neck: value(34),
+				LengthUnit: "cm", WeightUnit: "kg",
+				Derived: api.BodyComposition{LeanBodyMass: value(58.94), WaistToHipRatio: value(0.8), NavyBodyFatPercentage: value(26.32)},
+			},
+		}, {
+			name: "should base the lean body mass on the measured body fat percentage",
+			request: api.NewMeasurementRequest{UserID: 1, MeasuredAt: &measuredAt, MeasurementValues: api.MeasurementValues{
+				BodyFatPercentage: value(25), MuscleMass: value(66.14), WeightUnit: "lb",
+			}},
+			want_measurement: api.Measurement{
+				ID: 1, UserID: 1, MeasuredAt: measuredAt, BodyFatPercentage: value(25), MuscleMass: value(30),
+				LengthUnit: "cm", WeightUnit: "kg",
+				Derived: api.BodyComposition{LeanBodyMass: value(60)},
+			},
+		}, {
+			name: "should convert lengths sent in inches",
+			request: api.NewMeasurementRequest{UserID: 1, MeasuredAt: &measuredAt, MeasurementValues: api.MeasurementValues{
+				Chest: value(40), LengthUnit: "in",
+			}},
+			want_measurement: api.Measurement{
+				ID: 1, UserID: 1, MeasuredAt: measuredAt, Chest: value(101.6), LengthUnit: "cm", WeightUnit: "kg",
+			},
+		}, {
+			name:       "should return an error without any value",
+			request:    api.NewMeasurementRequest{UserID: 1, MeasuredAt: &measuredAt},
+			want_error: api.ValidationError("body", "measurement service - a measurement needs at least one value"),
+		}, {
+			name: "should return an error when a length is out of range",
+			request: api.NewMeasurementRequest{UserID: 1, MeasuredAt: &measuredAt, MeasurementValues: api.MeasurementValues{
+				Neck: value(5), LengthUnit: "in",
+			}},
+			want_error: api.ValidationError("neck", "measurement service - neck must be between 7.87 and 31.5 in"),
+		}, {
+			name: "should return an error when the user does not exist",
+			request: api.NewMeasurementRequest{UserID: 2, MeasuredAt: &measuredAt, MeasurementValues: api.MeasurementValues{
+				Waist: value(80),
+			}},
+			want_error: api.NotFoundError("storage - user does not exist"),
+		},
+	}
+
+	for _, test := range tests {
+		mockRepo := mockMeasurementRepo{mockWeightRepo{weights: weights}, map[int]api.Measurement{}}
+		mockMeasurementService := api.NewMeasurementService(&mockRepo)
+
+		t.Run(test.name, func(t *testing.T) {
+			measurement, err := mockMeasurementService.New(context.Background(), test.request)
+
+			if !reflect.DeepEqual(err, test.want_error) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want_error)
+			}
+
+			if !reflect.DeepEqual(measurement, test.want_measurement) {
+				t.Errorf("test: %v failed. got: %+v, wanted: %+v", test.name, measurement, test.want_measurement)
+			}
+		})
+	}
+}
+
+func TestMeasurementOfAnotherUser(t *testing.T) {
+	waist := 80.0
+
+	mockRepo := mockMeasurementRepo{mockWeightRepo{}, map[int]api.Measurement{
+		1: {ID: 1, UserID: 2, MeasuredAt: time.Now(), Waist: &waist},
+	}}
+	mockMeasurementService := api.NewMeasurementService(&mockRepo)
+	want := api.NotFoundError("measurement service - measurement does not exist")
+
+	// the measurement exists, but does not belong to user 1
+	if _, err := mockMeasurementService.Get(context.Background(), 1, 1); !reflect.DeepEqual(err, want) {
+		t.Errorf("got: %v, wanted: %v", err, want)
+	}
+
+	if _, err := mockMeasurementService.Delete(context.Background(), 1, 1); !reflect.DeepEqual(err, want) {
+		t.Errorf("got: %v, wanted: %v", err, want)
+	}
+
+	if _, ok := mockRepo.measurements[1]; !ok {
+		t.Errorf("the measurement of another user was deleted")
+	}
+}
+
+// countingMeasurementRepo counts how often the weights are loaded
+type countingMeasurementRepo struct {
+	mockMeasurementRepo
+	weightQueries *int
+}
+
+func (c countingMeasurementRepo) GetWeights(ctx context.Context, filter api.WeightFilter) ([]api.Weight, error) {
+	*c.weightQueries++
+
+	return c.mockMeasurementRepo.GetWeights(ctx, filter)
+}
+
+func TestMeasurementHistory(t *testing.T) {
+	start := time.Date(2022, 3, 1, 8, 0, 0, 0, time.UTC)
+	bodyFat := 25.0
+	value := func(v float64) *float64 { return &v }
+
+	weights := []api.Weight{
+		{ID: 1, UserID: 1, Weight: 80, MeasuredAt: start.AddDate(0, 0, -1)},
+		{ID: 2, UserID: 1, Weight: 100, MeasuredAt: start.AddDate(0, 0, 5)},
+		{ID: 3, UserID: 1, Weight: 120, MeasuredAt: start.AddDate(0, 0, 20)},
+		{ID: 4, UserID: 2, Weight: 50, MeasuredAt: start.AddDate(0, 0, -3)},
+	}
+
+	measurements := map[int]api.Measurement{}
+
+	// the lean body mass is three quarters of the latest weight at or before each measurement
+	wantLeanBodyMass := map[int]*float64{1: nil, 2: value(60), 3: value(75), 4: value(75)}
+
+	for id, day := range map[int]int{1: -2, 2: 0, 3: 5, 4: 10} {
+		measurements[id] = api.Measurement{ID: id, UserID: 1, MeasuredAt: start.AddDate(0, 0, day), BodyFatPercentage: &bodyFat}
+	}
+
+	weightQueries := 0
+	mockRepo := countingMeasurementRepo{mockMeasurementRepo{mockWeightRepo{weights: weights}, measurements}, &weightQueries}
+	mockMeasurementService := api.NewMeasurementService(&mockRepo)
+
+	history, err := mockMeasurementService.History(context.Background(), api.MeasurementHistoryRequest{UserID: 1})
+
+	if err != nil {
+		t.Fatalf("got: %v, wanted: %v", err, nil)
+	}
+
+	if len(history.Measurements) != len(measurements) {
+		t.Fatalf("got: %v measurements, wanted: %v", len(history.Measurements), len(measurements))
+	}
+
+	for _, measurement := range history.Measurements {
+		if got, want := measurement.Derived.LeanBodyMass, wantLeanBodyMass[measurement.ID]; !reflect.DeepEqual(got, want) {
+			t.Errorf("measurement %v: got: %v, wanted: %v", measurement.ID, got, want)
+		}
+	}
+
+	// the weights of the whole page are loaded at once, not once per measurement
+	if weightQueries > 2 {
+		t.Errorf("got: %v weight queries, wanted: at most %v", weightQueries, 2)
+	}
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"context"
+	"math"
+)
+
+// the WHO classification of the BMI of adults
+const (
+	bmiUnderweight   = "underweight"
+	bmiNormal        = "normal"
+	bmiOverweight    = "overweight"
+	bmiObeseClassI   = "obese_class_1"
+	bmiObeseClassII  = "obese_class_2"
+	bmiObeseClassIII = "obese_class_3"
+)
+
+// the lowest BMI of each category above underweight
+const (
+	minNormalBMI        = 18.5
+	minOverweightBMI    = 25
+	minObeseClassIBMI   = 30
+	minObeseClassIIBMI  = 35
+	minObeseClassIIIBMI = 40
+)
+
+// the Devine and Robinson formulas start from a base weight at five feet and add
+// a fixed weight for every inch above it, all in kilograms
+const (
+	idealWeightBaseHeight = 60 * centimetersPerInch
+
+	devineBaseMale        = 50.0
+	devineBaseFemale      = 45.5
+	devinePerInch         = 2.3
+	robinsonBaseMale      = 52.0
+	robinsonBaseFemale    = 49.0
+	robinsonPerInchMale   = 1.9
+	robinsonPerInchFemale = 1.7
+)
+
+// Metrics returns the health metrics of a user at their latest weight entry, in
+// the units of the user
+func (a *analyticsService) Metrics(ctx context.Context, userID int) (MetricsSummary, error) {
+	if userID == 0 {
+		return MetricsSummary{}, ValidationError("user_id", "analytics service - user ID cannot be 0")
+	}
+
+	user, err := a.storage.GetUser(ctx, userID)
+
+	if err != nil {
+		return MetricsSummary{}, err
+	}
+
+	ideal, err := idealWeight(user)
+
+	if err != nil {
+		return MetricsSummary{}, err
+	}
+
+	summary := MetricsSummary{UserID: user.ID, IdealWeight: ideal.inUnits(user.UnitSystem)}
+
+	latest, err := a.storage.GetWeights(ctx, WeightFilter{UserID: user.ID, Descending: true, Limit: 1})
+
+	if err != nil {
+		return MetricsSummary{}, err
+	}
+
+	if len(latest) > 0 {
+		entry, err := renderWeight(latest[0], user)
+
+		if err != nil {
+			return MetricsSummary{}, err
+		}
+
+		summary.LatestWeight = &entry
+	}
+
+	return summary, nil
+}
+
+// healthMetrics derives the metrics of a weight entry in kilograms, with the BMR
+// stored with it, from the current profile of its owner
+func healthMetrics(user User, weight float64, BMR int) (HealthMetrics, error) {
+	tdee, err := maintenanceCalories(BMR, user.ActivityLevel)
+
+	if err != nil {
+		return HealthMetrics{}, err
+	}
+
+	ideal, err := idealWeight(user)
+
+	if err != nil {
+		return HealthMetrics{}, err
+	}
+
+	bmi := round(weight / math.Pow(user.Height/100, 2))
+
+	return HealthMetrics{
+		BMI:         bmi,
+		BMICategory: bmiCategory(bmi),
+		TDEE:        tdee,
+		IdealWeight: ideal,
+	}, nil
+}
+
+func bmiCategory(bmi float64) string {
+	switch {
+	case bmi < minNormalBMI:
+		return bmiUnderweight
+	case bmi < minOverweightBMI:
+		return bmiNormal
+	case bmi < minObeseClassIBMI:
+		return bmiOverweight
+	case bmi < minObeseClassIIBMI:
+		return bmiObeseClassI
+	case bmi < minObeseClassIIIBMI:
+		return bmiObeseClassII
+	default:
+		return bmiObeseClassIII
+	}
+}
+
+// idealWeight calculates the ideal weight of a user in kilograms. The formulas are
+// meant for adults of at least five feet, shorter users get the base weight.
+// The healthy range covers the weights with a normal BMI.
+func idealWeight(user User) (IdealWeight, error) {
+	inchesOverBase := math.Max(0, user.Height-idealWeightBaseHeight) / centimetersPerInch
+	squaredHeight := math.Pow(user.Height/100, 2)
+
+	ideal := IdealWeight{
+		HealthyMin: round(minNormalBMI * squaredHeight),
+		// the upper bound is just below the overweight BMI, as the BMI is rounded to two decimals
+		HealthyMax: round((minOverweightBMI - 0.01) * squaredHeight),
+	}
+
+	switch user.Sex {
+	case "male":
+		ideal.Devine = round(devineBaseMale + devinePerInch*inchesOverBase)
+		ideal.Robinson = round(robinsonBaseMale + robinsonPerInchMale*inchesOverBase)
+	case "female":
+		ideal.Devine = round(devineBaseFemale + devinePerInch*inchesOverBase)
+		ideal.Robinson = round(robinsonBaseFemale + robinsonPerInchFemale*inchesOverBase)
+	default:
+		return IdealWeight{}, ValidationError("sex", "invalid variable sex - needs to be either male or female")
+	}
+
+	return ideal, nil
+}
@@ -0,0 +1,85 @@
+package api_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+	"weight-tracker/pkg/api"
+)
+
+func TestMetrics(t *testing.T) {
+	now := time.Now()
+
+	// the mocked user is a woman of 185 cm, whose ideal weight does not depend on the entries
+	ideal := api.IdealWeight{Devine: 75.02, Robinson: 70.82, HealthyMin: 63.32, HealthyMax: 85.53, Unit: "kg"}
+
+	tests := []struct {
+		name          string
+		weights       []api.Weight
+		want_bmi      float64
+		want_category string
+		want_tdee     int
+	}{
+		{
+			name: "should use the latest entry",
+			weights: []api.Weight{
+				{ID: 1, UserID: 1, Weight: 140, BMR: 1600, MeasuredAt: now.Add(-24 * time.Hour)},
+				{ID: 2, UserID: 1, Weight: 60, BMR: 1500, MeasuredAt: now},
+			},
+			want_bmi:      17.53,
+			want_category: "underweight",
+			want_tdee:     2850,
+		}, {
+			name:          "should classify an overweight bmi",
+			weights:       []api.Weight{{ID: 1, UserID: 1, Weight: 90, BMR: 1700, MeasuredAt: now}},
+			want_bmi:      26.3,
+			want_category: "overweight",
+			want_tdee:     3230,
+		}, {
+			name:          "should classify a bmi of 40 and above as the highest obesity class",
+			weights:       []api.Weight{{ID: 1, UserID: 1, Weight: 140, BMR: 2200, MeasuredAt: now}},
+			want_bmi:      40.91,
+			want_category: "obese_class_3",
+			want_tdee:     4180,
+		},
+	}
+
+	for _, test := range tests {
+		mockAnalyticsService := api.NewAnalyticsService(&mockWeightRepo{weights: test.weights})
+
+		t.Run(test.name, func(t *testing.T) {
+			summary, err := mockAnalyticsService.Metrics(context.Background(), 1)
+
+			if err != nil || summary.LatestWeight == nil || summary.LatestWeight.Metrics == nil {
+				t.Fatalf("test: %v failed. got: %+v, %v, wanted the metrics of the latest entry", test.name, summary, err)
+			}
+
+			want := api.HealthMetrics{BMI: test.want_bmi, BMICategory: test.want_category, TDEE: test.want_tdee, IdealWeight: ideal}
+
+			if !reflect.DeepEqual(*summary.LatestWeight.Metrics, want) {
+				t.Errorf("test: %v failed. got: %+v, wanted: %+v", test.name, *summary.LatestWeight.Metrics, want)
+			}
+		})
+	}
+}
+
+func TestMetricsWithoutWeights(t *testing.T) {
+	mockAnalyticsService := api.NewAnalyticsService(&mockWeightRepo{})
+
+	summary, err := mockAnalyticsService.Metrics(context.Background(), 1)
+	want := api.MetricsSummary{
+		UserID:      1,
+		IdealWeight: api.IdealWeight{Devine: 75.02, Robinson: 70.82, HealthyMin: 63.32, HealthyMax: 85.53, Unit: "kg"},
+	}
+
+	if err != nil || !reflect.DeepEqual(summary, want) {
+		t.Errorf("got: %+v, %v, wanted: %+v", summary, err, want)
+	}
+
+	wantErr := api.NotFoundError("storage - user does not exist")
+
+	if _, err = mockAnalyticsService.Metrics(context.Background(), 2); !reflect.DeepEqual(err, wantErr) {
+		t.Errorf("got: %v, wanted: %v", err, wantErr)
+	}
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tokens are JWTs signed with HMAC-SHA256. Only the claims we need are supported.
+type tokenClaims struct {
+	Subject   string `json:"sub"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+var (
+	errInvalidToken = UnauthorizedError("auth service - invalid token")
+	errExpiredToken = UnauthorizedError("auth service - token expired")
+)
+
+// signToken issues a token for the user that is valid until expiresAt
+func signToken(secret []byte, userID int, issuedAt, expiresAt time.Time) (string, error) {
+	claims, err := json.Marshal(tokenClaims{
+		Subject:   strconv.Itoa(userID),
+		IssuedAt:  issuedAt.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	return unsigned + "." + tokenSignature(secret, unsigned), nil
+}
+
+// verifyToken checks the signature and expiry of a token and returns the id of the user it was issued for
+func verifyToken(secret []byte, token string, now time.Time) (int, error) {
+	parts := strings.Split(token, ".")
+
+	if len(parts) != 3 || parts[0] != tokenHeader {
+		return 0, errInvalidToken
+	}
+
+	expected := tokenSignature(secret, parts[0]+"."+parts[1])
+
+	if !hmac.Equal([]byte(parts[2]), []byte(expected)) {
+		return 0, errInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+
+	if err != nil {
+		return 0, errInvalidToken
+	}
+
+	var claims tokenClaims
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return 0, errInvalidToken
+	}
+
+	if now.Unix() >= claims.ExpiresAt {
+		return 0, errExpiredToken
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+
+	if err != nil {
+		return 0, errInvalidToken
+	}
+
+	return userID, nil
+}
+
+func tokenSignature(secret []byte, unsigned string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,12 @@
+package api
+
+import "context"
+
+// Transactor lets a service make a sequence of repository calls atomic, e.g.
+// checking a user before writing to it. The repository calls made with the ctx
+// passed to fn are part of one transaction, which is rolled back when fn fails
+// and committed otherwise. Users read within the transaction cannot be changed
+// by others until it ends, so the checks made on them still hold when writing.
+type Transactor interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"math"
+	"time"
+)
+
+// everything is stored in metric units. Requests can be sent in either unit,
+// responses are rendered in the unit system the user prefers.
+const (
+	Metric   = "metric"
+	Imperial = "imperial"
+
+	Kilograms   = "kg"
+	Pounds      = "lb"
+	Centimeters = "cm"
+	Inches      = "in"
+)
+
+const (
+	kilogramsPerPound  = 0.45359237
+	centimetersPerInch = 2.54
+)
+
+// unitSystemOrDefault falls back to the metric system when none was chosen
+func unitSystemOrDefault(unitSystem string) (string, error) {
+	switch unitSystem {
+	case "":
+		return Metric, nil
+	case Metric, Imperial:
+		return unitSystem, nil
+	default:
+		return "", ValidationError("unit_system", "invalid unit system - must be metric or imperial")
+	}
+}
+
+// weightUnit is the unit weights are rendered in for the given unit system
+func weightUnit(unitSystem string) string {
+	if unitSystem == Imperial {
+		return Pounds
+	}
+
+	return Kilograms
+}
+
+// heightUnit is the unit heights are rendered in for the given unit system
+func heightUnit(unitSystem string) string {
+	if unitSystem == Imperial {
+		return Inches
+	}
+
+	return Centimeters
+}
+
+// toKilograms converts a weight in the given unit to kilograms
+func toKilograms(weight float64, unit string) (float64, error) {
+	switch unit {
+	case Kilograms:
+		return round(weight), nil
+	case Pounds:
+		return round(weight * kilogramsPerPound), nil
+	default:
+		return 0, ValidationError("unit", "invalid weight unit - must be kg or lb")
+	}
+}
+
+// toCentimeters converts a height in the given unit to centimeters
+func toCentimeters(height float64, unit string) (float64, error) {
+	switch unit {
+	case Centimeters:
+		return round(height), nil
+	case Inches:
+		return round(height * centimetersPerInch), nil
+	default:
+		return 0, ValidationError("height_unit", "invalid height unit - must be cm or in")
+	}
+}
+
+// fromKilograms converts a weight in kilograms to the weight unit of the unit system
+func fromKilograms(weight float64, unitSystem string) float64 {
+	if unitSystem == Imperial {
+		return round(weight / kilogramsPerPound)
+	}
+
+	return round(weight)
+}
+
+// fromCentimeters converts a height in centimeters to the height unit of the unit system
+func fromCentimeters(height float64, unitSystem string) float64 {
+	if unitSystem == Imperial {
+		return round(height / centimetersPerInch)
+	}
+
+	return round(height)
+}
+
+// rateInKilograms converts an optional weekly rate given in the weight unit of the unit system to kilograms
+func rateInKilograms(rate *float64, unitSystem string) (*float64, error) {
+	if rate == nil {
+		return nil, nil
+	}
+
+	kilograms, err := toKilograms(*rate, weightUnit(unitSystem))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &kilograms, nil
+}
+
+// values are kept with a precision of two decimals, same as the database columns
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// inPreferredUnits renders the user's height and weekly rate in the unit system
+// the user prefers, along with the user's age today
+func (u User) inPreferredUnits() User {
+	u.Age = ageAt(u.DateOfBirth, time.Now())
+	u.Height = fromCentimeters(u.Height, u.UnitSystem)
+	u.HeightUnit = heightUnit(u.UnitSystem)
+
+	if u.WeeklyRate != nil {
+		rate := fromKilograms(*u.WeeklyRate, u.UnitSystem)
+		u.WeeklyRate = &rate
+	}
+
+	return u
+}
+
+// inUnits renders the weight of the entry in the given unit system
+func (w Weight) inUnits(unitSystem string) Weight {
+	w.Weight = fromKilograms(w.Weight, unitSystem)
+	w.Unit = weightUnit(unitSystem)
+
+	if w.Metrics != nil {
+		metrics := *w.Metrics
+		metrics.IdealWeight = metrics.IdealWeight.inUnits(unitSystem)
+		w.Metrics = &metrics
+	}
+
+	return w
+}
+
+// inUnits renders the ideal weights in the weight unit of the unit system
+func (i IdealWeight) inUnits(unitSystem string) IdealWeight {
+	i.Devine = fromKilograms(i.Devine, unitSystem)
+	i.Robinson = fromKilograms(i.Robinson, unitSystem)
+	i.HealthyMin = fromKilograms(i.HealthyMin, unitSystem)
+	i.HealthyMax = fromKilograms(i.HealthyMax, unitSystem)
+	i.Unit = weightUnit(unitSystem)
+
+	return i
+}
+
+// inUnits renders the lengths and masses of the measurement in the given unit system
+func (m Measurement) inUnits(unitSystem string) Measurement {
+	inLengthUnit := func(length float64) float64 { return fromCentimeters(length, unitSystem) }
+	inWeightUnit := func(weight float64) float64 { return fromKilograms(weight, unitSystem) }
+
+	m.Waist = convertOptional(m.Waist, inLengthUnit)
+	m.Hip = convertOptional(m.Hip, inLengthUnit)
+	m.Neck = convertOptional(m.Neck, inLengthUnit)
+	m.Chest = convertOptional(m.Chest, inLengthUnit)
+	m.MuscleMass = convertOptional(m.MuscleMass, inWeightUnit)
+	m.Derived.LeanBodyMass = convertOptional(m.Derived.LeanBodyMass, inWeightUnit)
+	m.LengthUnit = heightUnit(unitSystem)
+	m.WeightUnit = weightUnit(unitSystem)
+
+	return m
+}
+
+// convertOptional converts a value that may be missing
+func convertOptional(value *float64, convert func(float64) float64) *float64 {
+	if value == nil {
+		return nil
+	}
+
+	converted := convert(*value)
+
+	return &converted
+}
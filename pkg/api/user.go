@@ -1,28 +1,40 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 )
 
 // UserService contains the methods of the user service
 type UserService interface {
-	New(user NewUserRequest) (createdUserID int, err error)
-	Delete(userID int) (deletedUserID int, err error)
-	Update(user UpdateUserRequest) (User, error)
-	GetUser(id int) (user User, err error)
-	All() (users []User, err error)
+	New(ctx context.Context, user NewUserRequest) (createdUserID int, err error)
+	Delete(ctx context.Context, userID, version int) (deletedUserID int, err error)
+	Update(ctx context.Context, user UpdateUserRequest) (User, error)
+	Patch(ctx context.Context, userID, version int, patch UserPatch) (User, error)
+	Restore(ctx context.Context, userID int) (User, error)
+	Purge(ctx context.Context, retention time.Duration) (purged int, err error)
+	GetUser(ctx context.Context, id int) (user User, err error)
+	All(ctx context.Context) (users []User, err error)
 }
 
-// UserRepository is what lets our service do db operations without knowing anything about the implementation
+// UserRepository is what lets our service do db operations without knowing anything about the implementation.
+// Updates and deletes only apply to the given version of a user, or fail with ErrPreconditionFailed.
 type UserRepository interface {
-	CreateUser(NewUserRequest) (userID int, err error)
-	DeleteUser(userID int) (deletedUserID int, err error)
-	UpdateUser(UpdateUserRequest) (User, error)
-	GetUser(userID int) (User, error)
-	GetUserByEmail(userEmail string) (user User, err error)
-	GetUsers() ([]User, error)
+	Transactor
+	CreateUser(ctx context.Context, request NewUserRequest) (userID int, err error)
+	DeleteUser(ctx context.Context, userID, version int) (deletedUserID int, err error)
+	UpdateUser(ctx context.Context, request UpdateUserRequest) (User, error)
+	PatchUser(ctx context.Context, userID, version int, changes UserChanges) (User, error)
+	RestoreUser(ctx context.Context, userID int) (User, error)
+	PurgeUsers(ctx context.Context, deletedBefore time.Time) (purged int, err error)
+	GetUser(ctx context.Context, userID int) (User, error)
+	GetUserByEmail(ctx context.Context, userEmail string) (user User, err error)
+	GetUsers(ctx context.Context) ([]User, error)
 }
 
 type userService struct {
@@ -35,85 +47,326 @@ func NewUserService(userRepo UserRepository) UserService {
 	}
 }
 
-func (u *userService) Update(user UpdateUserRequest) (updatedUser User, err error) {
+// Update overwrites every field of a user. The email is checked and the user
+// written in one transaction, so no one else can take the email in between.
+func (u *userService) Update(ctx context.Context, user UpdateUserRequest) (updatedUser User, err error) {
+	if err = validateUpdateUser(user); err != nil {
+		return
+	}
+
 	user.Name = strings.ToLower(user.Name)
 	user.Email = strings.TrimSpace(user.Email)
 
-	var exists bool
-	var changed bool
-
-	changed, err = emailChanged(u.storage.GetUser, user.ID, user.Email)
-	exists, err = emailExists(u.storage.GetUserByEmail, user.Email)
+	user.UnitSystem, user.Height, err = heightInCentimeters(user.UnitSystem, user.Height, user.HeightUnit)
 
 	if err != nil {
 		return
-	} else if changed && exists {
-		err = errors.New("user service - user with email already exists")
-		fmt.Printf("user.go:46 - email \n  exists: %v \n  email: %v \n  error: %v \n\n", exists, user.Email, err)
+	}
+
+	user.BMRFormula, err = bmrFormulaName(user.BMRFormula)
+
+	if err != nil {
 		return
 	}
 
-	updatedUser, err = u.storage.UpdateUser(user)
+	user.WeeklyRate, err = rateInKilograms(user.WeeklyRate, user.UnitSystem)
 
 	if err != nil {
 		return
 	}
 
+	err = u.storage.WithTx(ctx, func(ctx context.Context) error {
+		current, err := u.storage.GetUser(ctx, user.ID)
+
+		if err != nil {
+			return err
+		}
+
+		user.DateOfBirth = dateOfBirthOrAge(user.DateOfBirth, user.Age, current.DateOfBirth)
+
+		// keeping the current email is not a conflict
+		if user.Email != current.Email {
+			if err = u.emailAvailable(ctx, user.Email); err != nil {
+				return err
+			}
+		}
+
+		updatedUser, err = u.storage.UpdateUser(ctx, user)
+
+		return err
+	})
+
+	if err != nil {
+		return User{}, err
+	}
+
+	updatedUser = updatedUser.inPreferredUnits()
+
 	return
 }
 
-func (u *userService) GetUser(userID int) (User, error) {
-	user, err := u.storage.GetUser(userID)
+// patchableUserMembers maps the members of a user merge patch to the column they change
+var patchableUserMembers = map[string]string{
+	"name":           "name",
+	"age":            "date_of_birth",
+	"date_of_birth":  "date_of_birth",
+	"height":         "height",
+	"height_unit":    "height",
+	"sex":            "sex",
+	"activity_level": "activity_level",
+	"weight_goal":    "weight_goal",
+	"email":          "email",
+	"unit_system":    "unit_system",
+	"bmr_formula":    "bmr_formula",
+	"weekly_rate":    "weekly_rate",
+}
+
+// Patch applies a merge patch to a user. The patched user is validated as a
+// whole, but only the columns of the members in the patch are written. A height
+// or weekly rate in the patch is read in the resulting unit system. The user is
+// read and written in one transaction, so the patch applies to what was read.
+func (u *userService) Patch(ctx context.Context, userID, version int, patch UserPatch) (patched User, err error) {
+	err = u.storage.WithTx(ctx, func(ctx context.Context) error {
+		patched, err = u.patch(ctx, userID, version, patch)
+		return err
+	})
+
+	if err != nil {
+		return User{}, err
+	}
+
+	return patched.inPreferredUnits(), nil
+}
+
+// patch returns the patched user in storage units
+func (u *userService) patch(ctx context.Context, userID, version int, patch UserPatch) (User, error) {
+	v := validator{}
+
+	v.check(userID != 0, "id", "user service - user ID cannot be 0")
+
+	for member := range patch {
+		_, ok := patchableUserMembers[member]
+		v.check(ok, member, "user service - field cannot be changed")
+	}
+
+	if err := v.err(); err != nil {
+		return User{}, err
+	}
+
+	current, err := u.storage.GetUser(ctx, userID)
+
+	if err != nil {
+		return User{}, err
+	}
+
+	if err = versionMatches(current, version); err != nil {
+		return User{}, err
+	}
+
+	if len(patch) == 0 {
+		return current, nil
+	}
+
+	user, err := mergeUserPatch(current, patch)
+
+	if err != nil {
+		return User{}, err
+	}
+
+	if err = validateUpdateUser(user); err != nil {
+		return User{}, err
+	}
+
+	user.DateOfBirth = dateOfBirthOrAge(user.DateOfBirth, user.Age, current.DateOfBirth)
+	user.Name = strings.ToLower(user.Name)
+	user.Email = strings.TrimSpace(user.Email)
+
+	if user.Email != current.Email {
+		if err = u.emailAvailable(ctx, user.Email); err != nil {
+			return User{}, err
+		}
+	}
+
+	user.UnitSystem, user.Height, err = heightInCentimeters(user.UnitSystem, user.Height, user.HeightUnit)
+
+	if err != nil {
+		return User{}, err
+	}
+
+	user.BMRFormula, err = bmrFormulaName(user.BMRFormula)
 
 	if err != nil {
 		return User{}, err
 	}
 
-	return user, nil
+	if _, ok := patch["weekly_rate"]; ok {
+		user.WeeklyRate, err = rateInKilograms(user.WeeklyRate, user.UnitSystem)
+
+		if err != nil {
+			return User{}, err
+		}
+	}
+
+	columns := map[string]interface{}{
+		"name":           user.Name,
+		"date_of_birth":  user.DateOfBirth.Time,
+		"height":         user.Height,
+		"sex":            user.Sex,
+		"activity_level": user.ActivityLevel,
+		"weight_goal":    user.WeightGoal,
+		"email":          user.Email,
+		"unit_system":    user.UnitSystem,
+		"bmr_formula":    user.BMRFormula,
+		"weekly_rate":    user.WeeklyRate,
+	}
+
+	changes := UserChanges{}
+
+	for member := range patch {
+		column := patchableUserMembers[member]
+		changes[column] = columns[column]
+	}
+
+	return u.storage.PatchUser(ctx, userID, version, changes)
 }
 
-func (u *userService) All() ([]User, error) {
-	users, err := u.storage.GetUsers()
+// mergeUserPatch applies the patch to the stored user, giving the request the
+// patched user would have been sent with. The stored weekly rate is left out,
+// it is in kilograms while a submitted rate is in the unit of the unit system.
+// An age in the patch replaces the stored date of birth.
+func mergeUserPatch(user User, patch UserPatch) (UpdateUserRequest, error) {
+	stored, err := json.Marshal(UpdateUserRequest{
+		ID: user.ID, Name: user.Name, DateOfBirth: user.DateOfBirth,
+		Height: user.Height, HeightUnit: Centimeters, Sex: user.Sex,
+		ActivityLevel: user.ActivityLevel, WeightGoal: user.WeightGoal, Email: user.Email,
+		UnitSystem: user.UnitSystem, BMRFormula: user.BMRFormula,
+	})
+
+	if err != nil {
+		return UpdateUserRequest{}, err
+	}
+
+	document := map[string]json.RawMessage{}
+
+	if err = json.Unmarshal(stored, &document); err != nil {
+		return UpdateUserRequest{}, err
+	}
+
+	_, hasHeight := patch["height"]
+	_, hasHeightUnit := patch["height_unit"]
+
+	// a height unit only says how to read the height next to it
+	if hasHeight && !hasHeightUnit {
+		delete(document, "height_unit")
+	}
+
+	_, hasAge := patch["age"]
+	_, hasDateOfBirth := patch["date_of_birth"]
+
+	if hasAge && !hasDateOfBirth {
+		delete(document, "date_of_birth")
+	}
+
+	for member, value := range patch {
+		if member == "height_unit" && !hasHeight {
+			continue
+		}
+
+		if string(bytes.TrimSpace(value)) == "null" {
+			delete(document, member)
+		} else {
+			document[member] = value
+		}
+	}
+
+	merged, err := json.Marshal(document)
+
+	if err != nil {
+		return UpdateUserRequest{}, err
+	}
+
+	var request UpdateUserRequest
+
+	if err = json.Unmarshal(merged, &request); err != nil {
+		var typeErr *json.UnmarshalTypeError
+
+		if errors.As(err, &typeErr) {
+			return UpdateUserRequest{}, ValidationError(typeErr.Field, fmt.Sprintf("user service - %s has the wrong type", typeErr.Field))
+		} else if errors.Is(err, errInvalidDate) {
+			return UpdateUserRequest{}, ValidationError("date_of_birth", "user service - date of birth must be formatted as "+dateLayout)
+		}
+
+		return UpdateUserRequest{}, err
+	}
+
+	return request, nil
+}
+
+func (u *userService) GetUser(ctx context.Context, userID int) (User, error) {
+	user, err := u.storage.GetUser(ctx, userID)
+
+	if err != nil {
+		return User{}, err
+	}
+
+	return user.inPreferredUnits(), nil
+}
+
+func (u *userService) All(ctx context.Context) ([]User, error) {
+	users, err := u.storage.GetUsers(ctx)
 
 	if err != nil {
 		return []User{}, err
 	}
 
+	for i := range users {
+		users[i] = users[i].inPreferredUnits()
+	}
+
 	return users, nil
 }
 
-func (u *userService) New(user NewUserRequest) (createdUserID int, err error) {
-	// do some basic validations
-	if user.Email == "" {
-		err = errors.New("user service - email required")
+func (u *userService) New(ctx context.Context, user NewUserRequest) (createdUserID int, err error) {
+	// every invalid field is reported at once
+	if err = validateNewUser(user); err != nil {
 		return
 	}
 
-	if user.Name == "" {
-		err = errors.New("user service - name required")
+	user.DateOfBirth = dateOfBirthOrAge(user.DateOfBirth, user.Age, Date{})
+
+	user.PasswordHash, err = hashPassword(user.Password)
+
+	if err != nil {
 		return
 	}
 
-	if user.WeightGoal == "" {
-		err = errors.New("user service - weight goal required")
+	if err = u.emailAvailable(ctx, user.Email); err != nil {
 		return
 	}
 
-	var exists bool
-	exists, err = emailExists(u.storage.GetUserByEmail, user.Email)
+	// do some basic normalisation
+	user.Name = strings.ToLower(user.Name)
+	user.Email = strings.TrimSpace(user.Email)
+
+	user.UnitSystem, user.Height, err = heightInCentimeters(user.UnitSystem, user.Height, user.HeightUnit)
 
 	if err != nil {
 		return
-	} else if exists {
-		err = errors.New("user service - user with email already exists")
+	}
+
+	user.BMRFormula, err = bmrFormulaName(user.BMRFormula)
+
+	if err != nil {
 		return
 	}
 
-	// do some basic normalisation
-	user.Name = strings.ToLower(user.Name)
-	user.Email = strings.TrimSpace(user.Email)
+	user.WeeklyRate, err = rateInKilograms(user.WeeklyRate, user.UnitSystem)
+
+	if err != nil {
+		return
+	}
 
-	createdUserID, err = u.storage.CreateUser(user)
+	createdUserID, err = u.storage.CreateUser(ctx, user)
 
 	if err != nil {
 		return
@@ -122,48 +375,107 @@ func (u *userService) New(user NewUserRequest) (createdUserID int, err error) {
 	return
 }
 
-func (u *userService) Delete(userID int) (deletedUserID int, err error) {
-	deletedUserID, err = u.storage.DeleteUser(userID)
+func (u *userService) Delete(ctx context.Context, userID, version int) (deletedUserID int, err error) {
+	deletedUserID, err = u.storage.DeleteUser(ctx, userID, version)
 
 	if err != nil {
 		return
 	} else if deletedUserID == 0 {
-		err = errors.New("user service - user with given id does not exist")
+		err = NotFoundError("user service - user with given id does not exist")
 		return
 	}
 
 	return
 }
 
-type userGetterByEmail func(email string) (user User, err error)
+// Restore brings back a deleted user, along with its weights
+func (u *userService) Restore(ctx context.Context, userID int) (User, error) {
+	user, err := u.storage.RestoreUser(ctx, userID)
 
-// checks if the email submitted is already used
-func emailExists(userGetter userGetterByEmail, email string) (exists bool, err error) {
-	var user User
-	user, err = userGetter(email)
+	if err != nil {
+		return User{}, err
+	}
+
+	return user.inPreferredUnits(), nil
+}
+
+// Purge removes the users that were deleted more than retention ago for good
+func (u *userService) Purge(ctx context.Context, retention time.Duration) (int, error) {
+	if retention <= 0 {
+		return 0, ValidationError("retention", "user service - retention must be positive")
+	}
+
+	return u.storage.PurgeUsers(ctx, time.Now().Add(-retention))
+}
+
+// heightInCentimeters defaults the unit system and converts the submitted height
+// to centimeters. Heights sent without a unit are taken to be in the unit of the unit system.
+func heightInCentimeters(unitSystem string, height float64, unit string) (string, float64, error) {
+	unitSystem, err := unitSystemOrDefault(unitSystem)
 
 	if err != nil {
-		return
+		return "", 0, err
 	}
 
-	// proceed with comparison
-	exists = user != User{}
+	if unit == "" {
+		unit = heightUnit(unitSystem)
+	}
 
-	return
+	height, err = toCentimeters(height, unit)
+
+	if err != nil {
+		return "", 0, err
+	}
+
+	return unitSystem, height, nil
 }
 
-type userGetter func(id int) (user User, err error)
+// bmrFormulaName checks the chosen formula, defaulting to Mifflin-St Jeor
+func bmrFormulaName(name string) (string, error) {
+	formula, err := BMRFormulaByName(name)
 
-// checks if the submitted email is not the same as the users current email
-func emailChanged(userGetter userGetter, requestID int, requestEmail string) (unchanged bool, err error) {
+	if err != nil {
+		return "", err
+	}
+
+	return formula.Name(), nil
+}
+
+// versionMatches fails when the user was changed after the client read the given version
+func versionMatches(user User, version int) error {
+	if version != AnyVersion && version != user.Version {
+		return PreconditionFailedError("user service - user was changed since it was read")
+	}
+
+	return nil
+}
+
+// emailAvailable fails with a conflict when another user has the email
+func (u *userService) emailAvailable(ctx context.Context, email string) error {
+	exists, err := emailExists(ctx, u.storage.GetUserByEmail, email)
+
+	if err != nil {
+		return err
+	} else if exists {
+		return ConflictError("user service - user with email already exists")
+	}
+
+	return nil
+}
+
+type userGetterByEmail func(ctx context.Context, email string) (user User, err error)
+
+// checks if the email submitted is already used
+func emailExists(ctx context.Context, userGetter userGetterByEmail, email string) (exists bool, err error) {
 	var user User
-	user, err = userGetter(requestID) // get user
+	user, err = userGetter(ctx, email)
 
 	if err != nil {
 		return
 	}
 
-	unchanged = requestEmail != user.Email
+	// proceed with comparison
+	exists = user != User{}
 
 	return
 }
@@ -1,50 +1,66 @@
 package api_test
 
 import (
-	"errors"
+	"context"
+	"encoding/json"
 	"reflect"
 	"testing"
+	"time"
 	"weight-tracker/pkg/api"
 )
 
 type mockUserRepo struct {
 	users map[int]api.User
+	// when the deleted users were deleted
+	deleted map[int]time.Time
 }
 
 var taken_email = "taken_email@email.com"
 
+// the stored users already have the age the user service derives from their date of birth
 var users = map[int]api.User{
 	1: {
 		ID:            1,
 		Name:          "Rabbit",
-		Age:           2,
+		DateOfBirth:   bornYearsAgo(20),
+		Age:           20,
 		Height:        3,
 		Sex:           "female",
 		ActivityLevel: 2,
 		WeightGoal:    "heavy",
 		Email:         "some_email@email.com",
+		UnitSystem:    "metric",
+		Version:       1,
 	},
 	2: {
 		ID:            2,
 		Name:          "Mole",
-		Age:           2,
+		DateOfBirth:   bornYearsAgo(20),
+		Age:           20,
 		Height:        3,
 		Sex:           "female",
 		ActivityLevel: 2,
 		WeightGoal:    "heavy",
 		Email:         taken_email,
+		UnitSystem:    "metric",
+		Version:       1,
 	},
 }
 
-func (m mockUserRepo) CreateUser(request api.NewUserRequest) (userID int, err error) {
+// the mocks have no transactions, fn runs against the mock as it is
+func (m mockUserRepo) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (m mockUserRepo) CreateUser(ctx context.Context, request api.NewUserRequest) (userID int, err error) {
 	return userID, nil
 }
 
-func (m mockUserRepo) GetUser(userID int) (api.User, error) {
+func (m mockUserRepo) GetUser(ctx context.Context, userID int) (api.User, error) {
 	return m.users[userID], nil
 }
 
-func (m mockUserRepo) GetUserByEmail(userEmail string) (api.User, error) {
+func (m mockUserRepo) GetUserByEmail(ctx context.Context, userEmail string) (api.User, error) {
 	// iterate over the items in m.users
 	// check email, and return email if theirs
 	for _, user := range m.users {
@@ -61,22 +77,96 @@ func (m mockUserRepo) GetUserByEmail(userEmail string) (api.User, error) {
 	*/
 }
 
-func (m mockUserRepo) UpdateUser(request api.UpdateUserRequest) (api.User, error) {
+// errStaleVersion is what the mock returns for changes to another version of a user
+var errStaleVersion = api.PreconditionFailedError("storage - user was changed since it was read")
+
+func (m mockUserRepo) staleVersion(userID, version int) bool {
+	user, present := m.users[userID]
+
+	return present && version != api.AnyVersion && version != user.Version
+}
+
+func (m mockUserRepo) UpdateUser(ctx context.Context, request api.UpdateUserRequest) (api.User, error) {
+	if m.staleVersion(request.ID, request.Version) {
+		return api.User{}, errStaleVersion
+	}
+
 	// assuming update has been validated
 	// create the new user struct and make it the value
 	// of the key identified by the user request key
 	user_update := api.User{
 		ID: request.ID, Name: request.Name,
-		Age: request.Age, Height: request.Height,
+		DateOfBirth: request.DateOfBirth, Height: request.Height,
 		Sex: request.Sex, ActivityLevel: request.ActivityLevel,
 		Email: request.Email, WeightGoal: request.WeightGoal,
+		UnitSystem: request.UnitSystem, BMRFormula: request.BMRFormula,
 	}
 	m.users[request.ID] = user_update
 
 	return m.users[request.ID], nil
 }
 
-func (m mockUserRepo) GetUsers() (users []api.User, err error) {
+func (m mockUserRepo) PatchUser(ctx context.Context, userID, version int, changes api.UserChanges) (api.User, error) {
+	if m.staleVersion(userID, version) {
+		return api.User{}, errStaleVersion
+	}
+
+	user := m.users[userID]
+
+	for column, value := range changes {
+		switch column {
+		case "name":
+			user.Name = value.(string)
+		case "date_of_birth":
+			user.DateOfBirth = api.DateOf(value.(time.Time))
+		case "height":
+			user.Height = value.(float64)
+		case "sex":
+			user.Sex = value.(string)
+		case "activity_level":
+			user.ActivityLevel = value.(int)
+		case "weight_goal":
+			user.WeightGoal = value.(string)
+		case "email":
+			user.Email = value.(string)
+		case "unit_system":
+			user.UnitSystem = value.(string)
+		case "bmr_formula":
+			user.BMRFormula = value.(string)
+		case "weekly_rate":
+			user.WeeklyRate = value.(*float64)
+		}
+	}
+
+	user.Version++
+	m.users[userID] = user
+
+	return user, nil
+}
+
+func (m mockUserRepo) RestoreUser(ctx context.Context, userID int) (api.User, error) {
+	if _, deleted := m.deleted[userID]; !deleted {
+		return api.User{}, api.NotFoundError("storage - deleted user does not exist")
+	}
+
+	delete(m.deleted, userID)
+
+	return m.users[userID], nil
+}
+
+func (m mockUserRepo) PurgeUsers(ctx context.Context, deletedBefore time.Time) (purged int, err error) {
+	for userID, deletedAt := range m.deleted {
+		if deletedAt.Before(deletedBefore) {
+			delete(m.deleted, userID)
+			delete(m.users, userID)
+			purged++
+		}
+	}
+
+	return
+}
+
+func (m mockUserRepo) GetUsers(ctx context.Context) (users []api.User, err error) {
 	// iterate over m.users map, and add all the values to the returned
 	// users slice
 
@@ -94,11 +184,13 @@ func (m mockUserRepo) GetUsers() (users []api.User, err error) {
 	return
 }
 
-func (m mockUserRepo) DeleteUser(userID int) (deletedUserID int, err error) {
+func (m mockUserRepo) DeleteUser(ctx context.Context, userID, version int) (deletedUserID int, err error) {
 	_, present := m.users[userID]
 
 	if !present {
 		return 0, nil
+	} else if m.staleVersion(userID, version) {
+		return 0, errStaleVersion
 	}
 
 	return userID, nil
@@ -117,52 +209,70 @@ func TestCreateNewUser(t *testing.T) {
 			request: api.NewUserRequest{
 				Name:          "test user",
 				WeightGoal:    "maintain",
-				Age:           20,
+				DateOfBirth:   bornYearsAgo(20),
 				Height:        180,
 				Sex:           "female",
 				ActivityLevel: 5,
 				Email:         "test_user@gmail.com",
+				Password:      "correct horse",
 			},
 			want_err: nil,
 			want_id:  0,
+		}, {
+			name: "should return an error because the password is too short",
+			request: api.NewUserRequest{
+				Name:          "test user",
+				WeightGoal:    "maintain",
+				DateOfBirth:   bornYearsAgo(20),
+				Height:        180,
+				Sex:           "female",
+				ActivityLevel: 5,
+				Email:         "test_user@gmail.com",
+				Password:      "short",
+			},
+			want_err: api.ValidationError("password", "user service - password must be at least 8 characters"),
+			want_id:  0,
 		}, {
 			name: "should return an error because of missing email",
 			request: api.NewUserRequest{
 				Name:          "test user",
-				Age:           20,
+				DateOfBirth:   bornYearsAgo(20),
 				WeightGoal:    "maintain",
 				Height:        180,
 				Sex:           "female",
 				ActivityLevel: 5,
 				Email:         "",
+				Password:      "correct horse",
 			},
-			want_err: errors.New("user service - email required"),
+			want_err: api.ValidationError("email", "user service - email required"),
 			want_id:  0,
 		}, {
 			name: "should return an error because of missing name",
 			request: api.NewUserRequest{
 				Name:          "",
-				Age:           20,
+				DateOfBirth:   bornYearsAgo(20),
 				WeightGoal:    "maintain",
 				Height:        180,
 				Sex:           "female",
 				ActivityLevel: 5,
 				Email:         "test_user@gmail.com",
+				Password:      "correct horse",
 			},
-			want_err: errors.New("user service - name required"),
+			want_err: api.ValidationError("name", "user service - name required"),
 			want_id:  0,
 		}, {
 			name: "should return error because user with email already exists",
 			request: api.NewUserRequest{
 				Name:          "test user with email exists",
-				Age:           20,
+				DateOfBirth:   bornYearsAgo(20),
 				Height:        180,
 				WeightGoal:    "maintain",
 				Sex:           "female",
 				ActivityLevel: 5,
 				Email:         "taken_email@email.com",
+				Password:      "correct horse",
 			},
-			want_err: errors.New("user service - user with email already exists"),
+			want_err: api.ConflictError("user service - user with email already exists"),
 			want_id:  0,
 		},
 	}
@@ -173,7 +283,7 @@ func TestCreateNewUser(t *testing.T) {
 		mockUserService := api.NewUserService(&mockRepo)
 
 		t.Run(test.name, func(t *testing.T) {
-			userID, err := mockUserService.New(test.request)
+			userID, err := mockUserService.New(context.Background(), test.request)
 
 			if !reflect.DeepEqual(err, test.want_err) {
 				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want_err)
@@ -201,8 +311,8 @@ func TestGetAllUser(t *testing.T) {
 		}, {
 			name: "should return users when there are users",
 			want_users: []api.User{
-				users[1],
-				users[2],
+				withHeightUnit(users[1], "cm"),
+				withHeightUnit(users[2], "cm"),
 			},
 			want_error: nil,
 		},
@@ -221,7 +331,7 @@ func TestGetAllUser(t *testing.T) {
 				mockRepo.users = test_users // use the predefined users
 			}
 
-			queried_users, err := mockUserService.All()
+			queried_users, err := mockUserService.All(context.Background())
 
 			if !reflect.DeepEqual(err, test.want_error) {
 				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want_error)
@@ -247,7 +357,7 @@ func TestUpdateUser(t *testing.T) {
 			request: api.UpdateUserRequest{
 				ID:            1,
 				Name:          "rabbit",
-				Age:           20,
+				DateOfBirth:   bornYearsAgo(20),
 				Height:        250,
 				Sex:           "male",
 				WeightGoal:    "maintain",
@@ -257,12 +367,16 @@ func TestUpdateUser(t *testing.T) {
 			want_user: api.User{
 				ID:            1,
 				Name:          "rabbit",
+				DateOfBirth:   bornYearsAgo(20),
 				Age:           20,
 				Height:        250,
+				HeightUnit:    "cm",
 				Sex:           "male",
 				WeightGoal:    "maintain",
 				ActivityLevel: 2,
 				Email:         "some_email@email.com",
+				UnitSystem:    "metric",
+				BMRFormula:    "mifflin_st_jeor",
 			},
 			want_error: nil,
 		},
@@ -272,7 +386,7 @@ func TestUpdateUser(t *testing.T) {
 			request: api.UpdateUserRequest{
 				ID:            1,
 				Name:          "rabbit",
-				Age:           20,
+				DateOfBirth:   bornYearsAgo(20),
 				Height:        250,
 				Sex:           "male",
 				WeightGoal:    "maintain",
@@ -282,12 +396,16 @@ func TestUpdateUser(t *testing.T) {
 			want_user: api.User{
 				ID:            1,
 				Name:          "rabbit",
+				DateOfBirth:   bornYearsAgo(20),
 				Age:           20,
 				Height:        250,
+				HeightUnit:    "cm",
 				Sex:           "male",
 				WeightGoal:    "maintain",
 				ActivityLevel: 2,
 				Email:         "non_conflicting@email.com",
+				UnitSystem:    "metric",
+				BMRFormula:    "mifflin_st_jeor",
 			},
 			want_error: nil,
 		},
@@ -296,7 +414,7 @@ func TestUpdateUser(t *testing.T) {
 			request: api.UpdateUserRequest{
 				ID:            1,
 				Name:          "rabbit",
-				Age:           20,
+				DateOfBirth:   bornYearsAgo(20),
 				Height:        250,
 				Sex:           "male",
 				WeightGoal:    "maintain",
@@ -306,12 +424,16 @@ func TestUpdateUser(t *testing.T) {
 			want_user: api.User{
 				ID:            1,
 				Name:          "rabbit",
+				DateOfBirth:   bornYearsAgo(20),
 				Age:           20,
 				Height:        250,
+				HeightUnit:    "cm",
 				Sex:           "male",
 				WeightGoal:    "maintain",
 				ActivityLevel: 2,
 				Email:         "unused@email.com",
+				UnitSystem:    "metric",
+				BMRFormula:    "mifflin_st_jeor",
 			},
 			want_error: nil,
 		},
@@ -320,7 +442,7 @@ func TestUpdateUser(t *testing.T) {
 			request: api.UpdateUserRequest{
 				ID:            1,
 				Name:          "rabbit",
-				Age:           20,
+				DateOfBirth:   bornYearsAgo(20),
 				Height:        250,
 				Sex:           "male",
 				WeightGoal:    "maintain",
@@ -328,7 +450,153 @@ func TestUpdateUser(t *testing.T) {
 				Email:         taken_email,
 			},
 			want_user:  api.User{},
-			want_error: errors.New("user service - user with email already exists"),
+			want_error: api.ConflictError("user service - user with email already exists"),
+		},
+		{
+			name: "should store an imperial height in centimeters and render it in inches",
+			request: api.UpdateUserRequest{
+				ID:            1,
+				Name:          "rabbit",
+				DateOfBirth:   bornYearsAgo(20),
+				Height:        70,
+				Sex:           "male",
+				WeightGoal:    "maintain",
+				ActivityLevel: 2,
+				Email:         "some_email@email.com",
+				UnitSystem:    "imperial",
+			},
+			want_user: api.User{
+				ID:            1,
+				Name:          "rabbit",
+				DateOfBirth:   bornYearsAgo(20),
+				Age:           20,
+				Height:        70,
+				HeightUnit:    "in",
+				Sex:           "male",
+				WeightGoal:    "maintain",
+				ActivityLevel: 2,
+				Email:         "some_email@email.com",
+				UnitSystem:    "imperial",
+				BMRFormula:    "mifflin_st_jeor",
+			},
+			want_error: nil,
+		},
+		{
+			name: "should return an error for an unknown unit system",
+			request: api.UpdateUserRequest{
+				ID:            1,
+				Name:          "rabbit",
+				DateOfBirth:   bornYearsAgo(20),
+				Height:        250,
+				Sex:           "male",
+				WeightGoal:    "maintain",
+				ActivityLevel: 2,
+				Email:         "some_email@email.com",
+				UnitSystem:    "nautical",
+			},
+			want_user:  api.User{},
+			want_error: api.ValidationError("unit_system", "invalid unit system - must be metric or imperial"),
+		},
+		{
+			name: "should store the chosen bmr formula",
+			request: api.UpdateUserRequest{
+				ID:            1,
+				Name:          "rabbit",
+				DateOfBirth:   bornYearsAgo(20),
+				Height:        250,
+				Sex:           "male",
+				WeightGoal:    "maintain",
+				ActivityLevel: 2,
+				Email:         "some_email@email.com",
+				BMRFormula:    "katch_mcardle",
+			},
+			want_user: api.User{
+				ID:            1,
+				Name:          "rabbit",
+				DateOfBirth:   bornYearsAgo(20),
+				Age:           20,
+				Height:        250,
+				HeightUnit:    "cm",
+				Sex:           "male",
+				WeightGoal:    "maintain",
+				ActivityLevel: 2,
+				Email:         "some_email@email.com",
+				UnitSystem:    "metric",
+				BMRFormula:    "katch_mcardle",
+			},
+			want_error: nil,
+		},
+		{
+			name: "should approximate the date of birth of clients that still send the age",
+			request: api.UpdateUserRequest{
+				ID:            1,
+				Name:          "rabbit",
+				Age:           31,
+				Height:        250,
+				Sex:           "male",
+				WeightGoal:    "maintain",
+				ActivityLevel: 2,
+				Email:         "some_email@email.com",
+			},
+			want_user: api.User{
+				ID:            1,
+				Name:          "rabbit",
+				DateOfBirth:   api.DateOf(time.Now().AddDate(-31, -6, 0)),
+				Age:           31,
+				Height:        250,
+				HeightUnit:    "cm",
+				Sex:           "male",
+				WeightGoal:    "maintain",
+				ActivityLevel: 2,
+				Email:         "some_email@email.com",
+				UnitSystem:    "metric",
+				BMRFormula:    "mifflin_st_jeor",
+			},
+			want_error: nil,
+		},
+		{
+			name: "should keep the stored date of birth when the age sent is the age it gives",
+			request: api.UpdateUserRequest{
+				ID:            1,
+				Name:          "rabbit",
+				Age:           20,
+				Height:        250,
+				Sex:           "male",
+				WeightGoal:    "maintain",
+				ActivityLevel: 2,
+				Email:         "some_email@email.com",
+			},
+			want_user: api.User{
+				ID:            1,
+				Name:          "rabbit",
+				DateOfBirth:   bornYearsAgo(20),
+				Age:           20,
+				Height:        250,
+				HeightUnit:    "cm",
+				Sex:           "male",
+				WeightGoal:    "maintain",
+				ActivityLevel: 2,
+				Email:         "some_email@email.com",
+				UnitSystem:    "metric",
+				BMRFormula:    "mifflin_st_jeor",
+			},
+			want_error: nil,
+		},
+		{
+			name: "should return an error for an unknown bmr formula",
+			request: api.UpdateUserRequest{
+				ID:            1,
+				Name:          "rabbit",
+				DateOfBirth:   bornYearsAgo(20),
+				Height:        250,
+				Sex:           "male",
+				WeightGoal:    "maintain",
+				ActivityLevel: 2,
+				Email:         "some_email@email.com",
+				BMRFormula:    "guesswork",
+			},
+			want_user:  api.User{},
+			want_error: api.ValidationError("bmr_formula", "invalid bmr formula - must be mifflin_st_jeor, harris_benedict or katch_mcardle"),
 		},
 	}
 
@@ -338,7 +606,7 @@ func TestUpdateUser(t *testing.T) {
 		mockUserService := api.NewUserService(&mockRepo)
 
 		t.Run(test.name, func(t *testing.T) {
-			user, err := mockUserService.Update(test.request)
+			user, err := mockUserService.Update(context.Background(), test.request)
 
 			if !reflect.DeepEqual(err, test.want_error) {
 				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want_error)
@@ -356,6 +624,7 @@ func TestDeleteUser(t *testing.T) {
 	tests := []struct {
 		name       string
 		request    int
+		version    int
 		want_error error
 		want_id    int
 	}{
@@ -368,7 +637,14 @@ func TestDeleteUser(t *testing.T) {
 		{
 			name:       "should return an error when user with submitted id does not exist",
 			request:    25,
-			want_error: errors.New("user service - user with given id does not exist"),
+			want_error: api.NotFoundError("user service - user with given id does not exist"),
+			want_id:    0,
+		},
+		{
+			name:       "should return an error when the user was changed since it was read",
+			request:    1,
+			version:    2,
+			want_error: api.PreconditionFailedError("storage - user was changed since it was read"),
 			want_id:    0,
 		},
 	}
@@ -379,7 +655,7 @@ func TestDeleteUser(t *testing.T) {
 		mockUserService := api.NewUserService(&mockRepo)
 
 		t.Run(test.name, func(t *testing.T) {
-			userID, err := mockUserService.Delete(test.request)
+			userID, err := mockUserService.Delete(context.Background(), test.request, test.version)
 
 			if !reflect.DeepEqual(err, test.want_error) {
 				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want_error)
@@ -392,6 +668,17 @@ func TestDeleteUser(t *testing.T) {
 	}
 }
 
+// bornYearsAgo is the date of birth of someone who turned years old yesterday
+func bornYearsAgo(years int) api.Date {
+	return api.DateOf(time.Now().AddDate(-years, 0, -1))
+}
+
+// users are rendered with the unit of their height
+func withHeightUnit(user api.User, unit string) api.User {
+	user.HeightUnit = unit
+	return user
+}
+
 // convenience function for copying user map therefore isolating changes to tests
 func copyUserMap(source_map map[int]api.User) (copied_map map[int]api.User) {
 	copied_map = make(map[int]api.User)
@@ -402,3 +689,202 @@ func copyUserMap(source_map map[int]api.User) (copied_map map[int]api.User) {
 
 	return
 }
+
+func TestPatchUser(t *testing.T) {
+	rate := 0.5
+	rateInPounds := 1.1
+	born := bornYearsAgo(30)
+	bornLater := bornYearsAgo(25)
+
+	tests := []struct {
+		name       string
+		patch      string
+		version    int
+		want_user  api.User
+		want_error error
+	}{
+		{
+			name:  "should only change the members in the patch",
+			patch: `{"date_of_birth": "` + bornLater.Format("2006-01-02") + `"}`,
+			want_user: api.User{
+				ID: 3, Name: "badger", DateOfBirth: bornLater, Age: 25, Height: 180, HeightUnit: "cm",
+				Sex: "male", ActivityLevel: 2, WeightGoal: "loose", Email: "badger@email.com",
+				UnitSystem: "metric", BMRFormula: "mifflin_st_jeor", WeeklyRate: &rate, Version: 2,
+			},
+		}, {
+			name:  "should approximate the date of birth from an age",
+			patch: `{"age": 31}`,
+			want_user: api.User{
+				ID: 3, Name: "badger", DateOfBirth: api.DateOf(time.Now().AddDate(-31, -6, 0)), Age: 31, Height: 180, HeightUnit: "cm",
+				Sex: "male", ActivityLevel: 2, WeightGoal: "loose", Email: "badger@email.com",
+				UnitSystem: "metric", BMRFormula: "mifflin_st_jeor", WeeklyRate: &rate, Version: 2,
+			},
+		}, {
+			name:  "should keep the stored date of birth when the age is unchanged",
+			patch: `{"age": 30, "name": "Honey Badger"}`,
+			want_user: api.User{
+				ID: 3, Name: "honey badger", DateOfBirth: born, Age: 30, Height: 180, HeightUnit: "cm",
+				Sex: "male", ActivityLevel: 2, WeightGoal: "loose", Email: "badger@email.com",
+				UnitSystem: "metric", BMRFormula: "mifflin_st_jeor", WeeklyRate: &rate, Version: 2,
+			},
+		}, {
+			name:  "should remove the weekly rate when it is null",
+			patch: `{"weekly_rate": null}`,
+			want_user: api.User{
+				ID: 3, Name: "badger", DateOfBirth: born, Age: 30, Height: 180, HeightUnit: "cm",
+				Sex: "male", ActivityLevel: 2, WeightGoal: "loose", Email: "badger@email.com",
+				UnitSystem: "metric", BMRFormula: "mifflin_st_jeor", Version: 2,
+			},
+		}, {
+			name:  "should keep the stored height when only the unit system changes",
+			patch: `{"unit_system": "imperial"}`,
+			want_user: api.User{
+				ID: 3, Name: "badger", DateOfBirth: born, Age: 30, Height: 70.87, HeightUnit: "in",
+				Sex: "male", ActivityLevel: 2, WeightGoal: "loose", Email: "badger@email.com",
+				UnitSystem: "imperial", BMRFormula: "mifflin_st_jeor", WeeklyRate: &rateInPounds, Version: 2,
+			},
+		}, {
+			name:  "should not conflict when the email stays the same",
+			patch: `{"email": "badger@email.com", "name": "Honey Badger"}`,
+			want_user: api.User{
+				ID: 3, Name: "honey badger", DateOfBirth: born, Age: 30, Height: 180, HeightUnit: "cm",
+				Sex: "male", ActivityLevel: 2, WeightGoal: "loose", Email: "badger@email.com",
+				UnitSystem: "metric", BMRFormula: "mifflin_st_jeor", WeeklyRate: &rate, Version: 2,
+			},
+		}, {
+			name:       "should return an error when the email belongs to another user",
+			patch:      `{"email": "taken_email@email.com"}`,
+			want_error: api.ConflictError("user service - user with email already exists"),
+		}, {
+			name:       "should validate the patched user",
+			patch:      `{"date_of_birth": null, "sex": "other"}`,
+			want_error: api.ValidationErrors(map[string]string{"date_of_birth": "user service - date of birth required", "sex": "user service - sex must be male or female"}),
+		}, {
+			name:       "should return an error for a date of birth that is not a date",
+			patch:      `{"date_of_birth": "17.05.1992"}`,
+			want_error: api.ValidationError("date_of_birth", "user service - date of birth must be formatted as 2006-01-02"),
+		}, {
+			name:       "should return an error for members that cannot be patched",
+			patch:      `{"role": "admin"}`,
+			want_error: api.ValidationError("role", "user service - field cannot be changed"),
+		}, {
+			name:       "should return an error for members of the wrong type",
+			patch:      `{"age": "thirty"}`,
+			want_error: api.ValidationError("age", "user service - age has the wrong type"),
+		}, {
+			name:       "should return an error when the user was changed since it was read",
+			patch:      `{"age": 31}`,
+			version:    2,
+			want_error: api.PreconditionFailedError("user service - user was changed since it was read"),
+		},
+	}
+
+	for _, test := range tests {
+		test_users := copyUserMap(users)
+		test_users[3] = api.User{
+			ID: 3, Name: "badger", DateOfBirth: born, Age: 30, Height: 180, Sex: "male",
+			ActivityLevel: 2, WeightGoal: "loose", Email: "badger@email.com",
+			UnitSystem: "metric", BMRFormula: "mifflin_st_jeor", WeeklyRate: &rate, Version: 1,
+		}
+		mockRepo := mockUserRepo{users: test_users}
+		mockUserService := api.NewUserService(&mockRepo)
+
+		t.Run(test.name, func(t *testing.T) {
+			var patch api.UserPatch
+
+			if err := json.Unmarshal([]byte(test.patch), &patch); err != nil {
+				t.Fatal(err)
+			}
+
+			user, err := mockUserService.Patch(context.Background(), 3, test.version, patch)
+
+			if !reflect.DeepEqual(err, test.want_error) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want_error)
+			}
+
+			if !reflect.DeepEqual(user, test.want_user) {
+				t.Errorf("test: %v failed. got: %+v, wanted: %+v", test.name, user, test.want_user)
+			}
+		})
+	}
+}
+
+func TestRestoreUser(t *testing.T) {
+	tests := []struct {
+		name       string
+		request    int
+		want_user  api.User
+		want_error error
+	}{
+		{
+			name:      "should restore a deleted user",
+			request:   2,
+			want_user: withHeightUnit(users[2], "cm"),
+		}, {
+			name:       "should return an error when the user is not deleted",
+			request:    1,
+			want_error: api.NotFoundError("storage - deleted user does not exist"),
+		},
+	}
+
+	for _, test := range tests {
+		mockRepo := mockUserRepo{users: copyUserMap(users), deleted: map[int]time.Time{2: time.Now()}}
+		mockUserService := api.NewUserService(&mockRepo)
+
+		t.Run(test.name, func(t *testing.T) {
+			user, err := mockUserService.Restore(context.Background(), test.request)
+
+			if !reflect.DeepEqual(err, test.want_error) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want_error)
+			}
+
+			if !reflect.DeepEqual(user, test.want_user) {
+				t.Errorf("test: %v failed. got: %+v, wanted: %+v", test.name, user, test.want_user)
+			}
+		})
+	}
+}
+
+func TestPurgeUsers(t *testing.T) {
+	tests := []struct {
+		name        string
+		retention   time.Duration
+		want_purged int
+		want_error  error
+	}{
+		{
+			name:        "should only purge users deleted longer than the retention ago",
+			retention:   24 * time.Hour,
+			want_purged: 1,
+		}, {
+			name:        "should purge nothing when no user was deleted long enough ago",
+			retention:   30 * 24 * time.Hour,
+			want_purged: 0,
+		}, {
+			name:       "should return an error for a retention that is not positive",
+			retention:  0,
+			want_error: api.ValidationError("retention", "user service - retention must be positive"),
+		},
+	}
+
+	for _, test := range tests {
+		deleted := map[int]time.Time{
+			1: time.Now().Add(-48 * time.Hour),
+			2: time.Now().Add(-time.Hour),
+		}
+		mockRepo := mockUserRepo{users: copyUserMap(users), deleted: deleted}
+		mockUserService := api.NewUserService(&mockRepo)
+
+		t.Run(test.name, func(t *testing.T) {
+			purged, err := mockUserService.Purge(context.Background(), test.retention)
+
+			if !reflect.DeepEqual(err, test.want_error) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want_error)
+			}
+
+			if purged != test.want_purged {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, purged, test.want_purged)
+			}
+		})
+	}
+}
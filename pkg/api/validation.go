@@ -0,0 +1,355 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// plausible ranges for the body measurements of a user. Anything outside of
+// them is most likely a typo, or a value in the wrong unit.
+const (
+	minAge       = 13
+	maxAge       = 120
+	minHeight    = 50.0  // cm
+	maxHeight    = 275.0 // cm
+	minWeight    = 20.0  // kg
+	maxWeight    = 650.0 // kg
+	minActivity  = 1
+	maxActivity  = 5
+	maxNameBytes = 255
+
+	minimumPasswordLength = 8
+)
+
+// the largest weekly rate the weekly_rate column holds, in kg per week
+const maxStoredWeeklyRate = 99.99
+
+// plausible ranges for the tape measurements of a measurement, in centimeters
+var lengthRanges = map[string][2]float64{
+	"waist": {40, 250},
+	"hip":   {50, 250},
+	"neck":  {20, 80},
+	"chest": {50, 250},
+}
+
+// validator collects the invalid fields of a request, so they can all be reported at once
+type validator map[string]string
+
+// check records the message for the field when ok is false. Only the first
+// problem found with a field is kept.
+func (v validator) check(ok bool, field, message string) {
+	if _, seen := v[field]; !ok && !seen {
+		v[field] = message
+	}
+}
+
+// add records the fields of a validation error returned by one of the helpers,
+// e.g. the unit conversions. Other errors are not expected here and are dropped.
+func (v validator) add(err error) {
+	var apiErr *Error
+
+	if errors.As(err, &apiErr) {
+		for field, message := range apiErr.Fields {
+			v.check(false, field, message)
+		}
+	}
+}
+
+func (v validator) err() error {
+	if len(v) == 0 {
+		return nil
+	}
+
+	return ValidationErrors(v)
+}
+
+// userProfile holds the fields new and updated users share
+type userProfile struct {
+	Name          string
+	Email         string
+	DateOfBirth   Date
+	Age           int
+	Height        float64
+	HeightUnit    string
+	UnitSystem    string
+	Sex           string
+	ActivityLevel int
+	WeightGoal    string
+	BMRFormula    string
+	WeeklyRate    *float64
+}
+
+func validateNewUser(request NewUserRequest) error {
+	v := validator{}
+
+	v.checkProfile(userProfile{
+		Name: request.Name, Email: request.Email, DateOfBirth: request.DateOfBirth, Age: request.Age,
+		Height: request.Height, HeightUnit: request.HeightUnit, UnitSystem: request.UnitSystem,
+		Sex: request.Sex, ActivityLevel: request.ActivityLevel, WeightGoal: request.WeightGoal,
+		BMRFormula: request.BMRFormula, WeeklyRate: request.WeeklyRate,
+	})
+	v.check(len(request.Password) >= minimumPasswordLength, "password", fmt.Sprintf("user service - password must be at least %d characters", minimumPasswordLength))
+
+	return v.err()
+}
+
+func validateUpdateUser(request UpdateUserRequest) error {
+	v := validator{}
+
+	v.check(request.ID != 0, "id", "user service - user ID cannot be 0")
+	v.checkProfile(userProfile{
+		Name: request.Name, Email: request.Email, DateOfBirth: request.DateOfBirth, Age: request.Age,
+		Height: request.Height, HeightUnit: request.HeightUnit, UnitSystem: request.UnitSystem,
+		Sex: request.Sex, ActivityLevel: request.ActivityLevel, WeightGoal: request.WeightGoal,
+		BMRFormula: request.BMRFormula, WeeklyRate: request.WeeklyRate,
+	})
+
+	return v.err()
+}
+
+func (v validator) checkProfile(user userProfile) {
+	v.check(user.Email != "", "email", "user service - email required")
+	v.check(validEmail(strings.TrimSpace(user.Email)), "email", "user service - email is not a valid address")
+
+	v.check(user.Name != "", "name", "user service - name required")
+	v.check(len(user.Name) <= maxNameBytes, "name", fmt.Sprintf("user service - name must be at most %d characters", maxNameBytes))
+
+	// the age is only checked when it is sent instead of a date of birth
+	if user.DateOfBirth.IsZero() {
+		v.check(user.Age != 0, "date_of_birth", "user service - date of birth required")
+		v.check(user.Age == 0 || (user.Age >= minAge && user.Age <= maxAge), "age", fmt.Sprintf("user service - age must be between %d and %d", minAge, maxAge))
+	} else {
+		age := ageAt(user.DateOfBirth, time.Now())
+		v.check(age >= minAge && age <= maxAge, "date_of_birth",
+			fmt.Sprintf("user service - date of birth must make the user between %d and %d years old", minAge, maxAge))
+	}
+
+	unitSystem, height, err := heightInCentimeters(user.UnitSystem, user.Height, user.HeightUnit)
+
+	if err != nil {
+		v.add(err)
+	} else {
+		unit := user.HeightUnit
+
+		if unit == "" {
+			unit = heightUnit(unitSystem)
+		}
+
+		v.check(height >= minHeight && height <= maxHeight, "height", fmt.Sprintf("user service - height must be between %g and %g %s",
+			fromCentimeters(minHeight, unitSystemOf(unit)), fromCentimeters(maxHeight, unitSystemOf(unit)), unit))
+
+		rate, err := rateInKilograms(user.WeeklyRate, unitSystem)
+
+		if err != nil {
+			v.add(err)
+		} else if rate != nil {
+			// unsafe rates are kept as asked for and clamped when planning the intake,
+			// only rates the weekly_rate column cannot hold are rejected
+			v.check(math.Abs(*rate) <= maxStoredWeeklyRate, "weekly_rate", fmt.Sprintf("user service - weekly rate must be between %g and %g %s",
+				fromKilograms(-maxStoredWeeklyRate, unitSystem), fromKilograms(maxStoredWeeklyRate, unitSystem), weightUnit(unitSystem)))
+		}
+	}
+
+	v.check(user.Sex == "male" || user.Sex == "female", "sex", "user service - sex must be male or female")
+
+	v.check(user.ActivityLevel >= minActivity && user.ActivityLevel <= maxActivity, "activity_level",
+		fmt.Sprintf("user service - activity level must be between %d and %d", minActivity, maxActivity))
+
+	// a weight goal is only a preset for the weekly rate, one of them is enough
+	if user.WeeklyRate == nil || user.WeightGoal != "" {
+		v.check(user.WeightGoal != "", "weight_goal", "user service - weight goal or weekly rate required")
+		_, err = goalPresetDelta(user.WeightGoal)
+		v.add(err)
+	}
+
+	_, err = BMRFormulaByName(user.BMRFormula)
+	v.add(err)
+}
+
+// checkWeight checks a weight sent in unit, or in the weight unit of the unit
+// system when unit is empty, and an optional body fat percentage
+func (v validator) checkWeight(weight float64, unit, unitSystem string, bodyFatPercentage *float64) {
+	if unit == "" {
+		unit = weightUnit(unitSystem)
+	}
+
+	v.check(weight > 0, "weight", "weight service - weight must be greater than 0")
+	v.checkWeightRange("weight", "weight service - weight", weight, unit)
+
+	if bodyFatPercentage != nil {
+		v.add(validateBodyFatPercentage(*bodyFatPercentage))
+	}
+}
+
+// checkWeightRange checks that a weight sent in unit is plausible. The range is
+// reported in that unit, the message starts with what the weight is.
+func (v validator) checkWeightRange(field, name string, weight float64, unit string) {
+	kilograms, err := toKilograms(weight, unit)
+
+	if err != nil {
+		v.add(err)
+		return
+	}
+
+	v.check(kilograms >= minWeight && kilograms <= maxWeight, field, fmt.Sprintf("%s must be between %g and %g %s",
+		name, fromKilograms(minWeight, unitSystemOf(unit)), fromKilograms(maxWeight, unitSystemOf(unit)), unit))
+}
+
+// validateGoal checks the weights of a goal sent in unit, or in the weight unit
+// of the unit system when unit is empty, and its target date
+func validateGoal(startWeight *float64, targetWeight float64, targetDate *time.Time, unit, unitSystem string) error {
+	v := validator{}
+
+	if unit == "" {
+		unit = weightUnit(unitSystem)
+	}
+
+	v.check(targetWeight > 0, "target_weight", "goal service - target weight must be greater than 0")
+	v.checkWeightRange("target_weight", "goal service - target weight", targetWeight, unit)
+
+	if startWeight != nil {
+		v.check(*startWeight > 0, "start_weight", "goal service - start weight must be greater than 0")
+		v.checkWeightRange("start_weight", "goal service - start weight", *startWeight, unit)
+	}
+
+	v.check(targetDate == nil || targetDate.After(time.Now()), "target_date", "goal service - target date must be in the future")
+
+	return v.err()
+}
+
+// validateNewWeight checks a new weight entry for a user with the given unit system
+func validateNewWeight(request NewWeightRequest, unitSystem string) error {
+	v := validator{}
+
+	v.check(request.UserID != 0, "user_id", "weight service - user ID cannot be 0")
+	v.checkWeight(request.Weight, request.Unit, unitSystem, request.BodyFatPercentage)
+
+	_, err := measuredAtOrNow(request.MeasuredAt)
+	v.add(err)
+
+	return v.err()
+}
+
+func validateUpdateWeight(request UpdateWeightRequest, unitSystem string) error {
+	v := validator{}
+
+	v.check(request.ID != 0, "id", "weight service - weight ID cannot be 0")
+	v.checkWeight(request.Weight, request.Unit, unitSystem, request.BodyFatPercentage)
+
+	_, err := measuredAtOrNow(request.MeasuredAt)
+	v.add(err)
+
+	return v.err()
+}
+
+func validateNewMeasurement(request NewMeasurementRequest, unitSystem string) error {
+	v := validator{}
+
+	v.check(request.UserID != 0, "user_id", "measurement service - user ID cannot be 0")
+	v.checkMeasurement(request.MeasurementValues, unitSystem)
+
+	_, err := measuredAtOrNow(request.MeasuredAt)
+	v.add(err)
+
+	return v.err()
+}
+
+func validateUpdateMeasurement(request UpdateMeasurementRequest, unitSystem string) error {
+	v := validator{}
+
+	v.check(request.ID != 0, "id", "measurement service - measurement ID cannot be 0")
+	v.checkMeasurement(request.MeasurementValues, unitSystem)
+
+	_, err := measuredAtOrNow(request.MeasuredAt)
+	v.add(err)
+
+	return v.err()
+}
+
+// checkMeasurement checks the values of a measurement sent in the given units,
+// or in the units of the unit system when they are empty
+func (v validator) checkMeasurement(values MeasurementValues, unitSystem string) {
+	lengths := map[string]*float64{"waist": values.Waist, "hip": values.Hip, "neck": values.Neck, "chest": values.Chest}
+	empty := values.BodyFatPercentage == nil && values.MuscleMass == nil && values.WaterPercentage == nil
+
+	for _, length := range lengths {
+		empty = empty && length == nil
+	}
+
+	v.check(!empty, "body", "measurement service - a measurement needs at least one value")
+
+	if values.BodyFatPercentage != nil {
+		v.add(validateBodyFatPercentage(*values.BodyFatPercentage))
+	}
+
+	if values.WaterPercentage != nil {
+		v.check(*values.WaterPercentage > 0 && *values.WaterPercentage < 100, "water_percentage",
+			"measurement service - water percentage must be between 0 and 100")
+	}
+
+	massUnit := values.WeightUnit
+
+	if massUnit == "" {
+		massUnit = weightUnit(unitSystem)
+	}
+
+	v.check(massUnit == Kilograms || massUnit == Pounds, "weight_unit", "measurement service - invalid weight unit - must be kg or lb")
+
+	if values.MuscleMass != nil && !v.invalid("weight_unit") {
+		kilograms, _ := toKilograms(*values.MuscleMass, massUnit)
+
+		v.check(kilograms > 0 && kilograms < maxWeight, "muscle_mass", fmt.Sprintf("measurement service - muscle mass must be between 0 and %g %s",
+			fromKilograms(maxWeight, unitSystemOf(massUnit)), massUnit))
+	}
+
+	lengthUnit := values.LengthUnit
+
+	if lengthUnit == "" {
+		lengthUnit = heightUnit(unitSystem)
+	}
+
+	v.check(lengthUnit == Centimeters || lengthUnit == Inches, "length_unit", "measurement service - invalid length unit - must be cm or in")
+
+	if v.invalid("length_unit") {
+		return
+	}
+
+	for field, length := range lengths {
+		if length == nil {
+			continue
+		}
+
+		centimeters, _ := toCentimeters(*length, lengthUnit)
+		limits := lengthRanges[field]
+
+		v.check(centimeters >= limits[0] && centimeters <= limits[1], field, fmt.Sprintf("measurement service - %s must be between %g and %g %s",
+			field, fromCentimeters(limits[0], unitSystemOf(lengthUnit)), fromCentimeters(limits[1], unitSystemOf(lengthUnit)), lengthUnit))
+	}
+}
+
+// invalid tells whether a problem with the field was recorded
+func (v validator) invalid(field string) bool {
+	_, seen := v[field]
+
+	return seen
+}
+
+// validEmail accepts plain addresses, without a display name
+func validEmail(email string) bool {
+	address, err := mail.ParseAddress(email)
+
+	return err == nil && address.Address == email
+}
+
+// unitSystemOf is the unit system a weight or height unit belongs to
+func unitSystemOf(unit string) string {
+	if unit == Pounds || unit == Inches {
+		return Imperial
+	}
+
+	return Metric
+}
@@ -0,0 +1,187 @@
+package api_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"weight-tracker/pkg/api"
+)
+
+func TestValidateNewUser(t *testing.T) {
+	valid := api.NewUserRequest{
+		Name:          "test user",
+		DateOfBirth:   bornYearsAgo(30),
+		Height:        180,
+		Sex:           "female",
+		ActivityLevel: 3,
+		WeightGoal:    "maintain",
+		Email:         "new_user@gmail.com",
+		Password:      "correct horse",
+	}
+
+	tests := []struct {
+		name   string
+		change func(request *api.NewUserRequest)
+		want   error
+	}{
+		{
+			name:   "should accept a plausible user",
+			change: func(request *api.NewUserRequest) {},
+			want:   nil,
+		}, {
+			name:   "should reject an email that is not an address",
+			change: func(request *api.NewUserRequest) { request.Email = "not an email" },
+			want:   api.ValidationError("email", "user service - email is not a valid address"),
+		}, {
+			name:   "should reject an email with a display name",
+			change: func(request *api.NewUserRequest) { request.Email = "Test <test@gmail.com>" },
+			want:   api.ValidationError("email", "user service - email is not a valid address"),
+		}, {
+			name:   "should reject an implausible date of birth",
+			change: func(request *api.NewUserRequest) { request.DateOfBirth = bornYearsAgo(7) },
+			want:   api.ValidationError("date_of_birth", "user service - date of birth must make the user between 13 and 120 years old"),
+		}, {
+			name: "should accept an age instead of a date of birth",
+			change: func(request *api.NewUserRequest) {
+				request.DateOfBirth = api.Date{}
+				request.Age = 30
+			},
+			want: nil,
+		}, {
+			name: "should reject an implausible age",
+			change: func(request *api.NewUserRequest) {
+				request.DateOfBirth = api.Date{}
+				request.Age = 7
+			},
+			want: api.ValidationError("age", "user service - age must be between 13 and 120"),
+		}, {
+			name:   "should reject an implausible height",
+			change: func(request *api.NewUserRequest) { request.Height = 1.8 },
+			want:   api.ValidationError("height", "user service - height must be between 50 and 275 cm"),
+		}, {
+			name: "should report the range of an imperial height in inches",
+			change: func(request *api.NewUserRequest) {
+				request.UnitSystem = api.Imperial
+				request.Height = 180
+			},
+			want: api.ValidationError("height", "user service - height must be between 19.69 and 108.27 in"),
+		}, {
+			name: "should keep a weekly rate that is not safe, it is clamped when planning the intake",
+			change: func(request *api.NewUserRequest) {
+				rate := -2.5
+				request.WeeklyRate = &rate
+			},
+			want: nil,
+		}, {
+			name: "should reject a weekly rate that cannot be stored",
+			change: func(request *api.NewUserRequest) {
+				rate := 150.0
+				request.WeeklyRate = &rate
+			},
+			want: api.ValidationError("weekly_rate", "user service - weekly rate must be between -99.99 and 99.99 kg"),
+		}, {
+			name: "should report the range of the weekly rate in pounds",
+			change: func(request *api.NewUserRequest) {
+				rate := 300.0
+				request.UnitSystem = api.Imperial
+				request.Height = 70
+				request.WeeklyRate = &rate
+			},
+			want: api.ValidationError("weekly_rate", "user service - weekly rate must be between -220.44 and 220.44 lb"),
+		}, {
+			name: "should accept a weekly rate instead of a weight goal",
+			change: func(request *api.NewUserRequest) {
+				rate := -0.5
+				request.WeightGoal = ""
+				request.WeeklyRate = &rate
+			},
+			want: nil,
+		}, {
+			name:   "should require a weight goal without a weekly rate",
+			change: func(request *api.NewUserRequest) { request.WeightGoal = "" },
+			want:   api.ValidationError("weight_goal", "user service - weight goal or weekly rate required"),
+		}, {
+			name:   "should reject an unknown sex",
+			change: func(request *api.NewUserRequest) { request.Sex = "other" },
+			want:   api.ValidationError("sex", "user service - sex must be male or female"),
+		}, {
+			name:   "should reject an unknown weight goal",
+			change: func(request *api.NewUserRequest) { request.WeightGoal = "lose" },
+			want:   api.ValidationError("weight_goal", "invalid weight goal provided - must be gain, loose or maintain"),
+		}, {
+			name:   "should reject an unknown activity level",
+			change: func(request *api.NewUserRequest) { request.ActivityLevel = 6 },
+			want:   api.ValidationError("activity_level", "user service - activity level must be between 1 and 5"),
+		}, {
+			name: "should report every invalid field at once",
+			change: func(request *api.NewUserRequest) {
+				request.Email = "not an email"
+				request.DateOfBirth = api.Date{}
+				request.Sex = ""
+				request.ActivityLevel = 0
+			},
+			want: api.ValidationErrors(map[string]string{
+				"email":          "user service - email is not a valid address",
+				"date_of_birth":  "user service - date of birth required",
+				"sex":            "user service - sex must be male or female",
+				"activity_level": "user service - activity level must be between 1 and 5",
+			}),
+		},
+	}
+
+	for _, test := range tests {
+		mockRepo := mockUserRepo{users: copyUserMap(users)}
+		mockUserService := api.NewUserService(&mockRepo)
+
+		t.Run(test.name, func(t *testing.T) {
+			request := valid
+			test.change(&request)
+
+			_, err := mockUserService.New(context.Background(), request)
+
+			if !reflect.DeepEqual(err, test.want) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateNewWeight(t *testing.T) {
+	mockRepo := mockWeightRepo{}
+	mockWeightService := api.NewWeightService(&mockRepo)
+
+	bodyFat := 120.0
+
+	tests := []struct {
+		name    string
+		request api.NewWeightRequest
+		want    error
+	}{
+		{
+			name:    "should reject an implausible weight",
+			request: api.NewWeightRequest{UserID: 1, Weight: 7},
+			want:    api.ValidationError("weight", "weight service - weight must be between 20 and 650 kg"),
+		}, {
+			name:    "should report the range in the unit the weight was sent in",
+			request: api.NewWeightRequest{UserID: 1, Weight: 2000, Unit: api.Pounds},
+			want:    api.ValidationError("weight", "weight service - weight must be between 44.09 and 1433 lb"),
+		}, {
+			name:    "should report every invalid field at once",
+			request: api.NewWeightRequest{UserID: 1, Weight: -1, BodyFatPercentage: &bodyFat},
+			want: api.ValidationErrors(map[string]string{
+				"weight":              "weight service - weight must be greater than 0",
+				"body_fat_percentage": "body fat percentage must be between 0 and 100",
+			}),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := mockWeightService.New(context.Background(), test.request)
+
+			if !reflect.DeepEqual(err, test.want) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want)
+			}
+		})
+	}
+}
@@ -1,16 +1,33 @@
 package api
 
-import "errors"
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type WeightService interface {
-	New(request NewWeightRequest) error
-	CalculateBMR(height, age, weight int, sex string) (int, error)
+	New(ctx context.Context, request NewWeightRequest) (Weight, error)
+	Get(ctx context.Context, weightID int) (Weight, error)
+	Update(ctx context.Context, request UpdateWeightRequest) (Weight, error)
+	Delete(ctx context.Context, weightID int) (deletedWeightID int, err error)
+	History(ctx context.Context, request WeightHistoryRequest) (WeightHistory, error)
+	CalculateBMR(height float64, age int, weight float64, sex string) (int, error)
 	DailyIntake(BMR, activityLevel int, weightGoal string) (int, error)
+	CaloricTarget(BMR, activityLevel int, sex, weightGoal string, weeklyRate *float64, unitSystem string) (CaloricTarget, error)
 }
 
 type WeightRepository interface {
-	CreateWeightEntry(w Weight) error
-	GetUser(userID int) (User, error)
+	Transactor
+	CreateWeightEntry(ctx context.Context, w Weight) (Weight, error)
+	GetWeight(ctx context.Context, weightID int) (Weight, error)
+	UpdateWeightEntry(ctx context.Context, w Weight) (Weight, error)
+	DeleteWeightEntry(ctx context.Context, weightID int) (deletedWeightID int, err error)
+	GetWeights(ctx context.Context, filter WeightFilter) ([]Weight, error)
+	GetUser(ctx context.Context, userID int) (User, error)
 }
 
 type weightService struct {
@@ -38,90 +55,422 @@ const (
 	veryHighActivity = 1.9
 )
 
-func (w *weightService) New(request NewWeightRequest) error {
-	if request.UserID == 0 {
-		return errors.New("weight service - user ID cannot be 0")
+// how far in the future a measured_at may be, to allow for clocks being slightly off
+const measuredAtTolerance = time.Minute
+
+// page sizes for the weight history
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+// New stores a weight entry, along with the BMR and daily caloric intake of the
+// user at that weight. The created entry is returned in the user's units. The
+// user is read and the entry written in one transaction, so the user cannot be
+// deleted or changed in between.
+func (w *weightService) New(ctx context.Context, request NewWeightRequest) (created Weight, err error) {
+	err = w.storage.WithTx(ctx, func(ctx context.Context) error {
+		created, err = w.create(ctx, request)
+		return err
+	})
+
+	return
+}
+
+func (w *weightService) create(ctx context.Context, request NewWeightRequest) (Weight, error) {
+	var user User
+	var err error
+
+	// the weight is checked in the units of the user
+	if request.UserID != 0 {
+		user, err = w.storage.GetUser(ctx, request.UserID)
+
+		if err != nil {
+			return Weight{}, err
+		}
+	}
+
+	if err = validateNewWeight(request, user.UnitSystem); err != nil {
+		return Weight{}, err
 	}
 
-	user, err := w.storage.GetUser(request.UserID)
+	measuredAt, err := measuredAtOrNow(request.MeasuredAt)
 
 	if err != nil {
-		return err
+		return Weight{}, err
 	}
 
-	bmr, err := w.CalculateBMR(user.Height, user.Age, request.Weight, user.Sex)
+	weight, err := weightInKilograms(request.Weight, request.Unit, user)
 
 	if err != nil {
-		return err
+		return Weight{}, err
 	}
 
-	dailyIntake, err := w.DailyIntake(bmr, user.ActivityLevel, user.WeightGoal)
+	bmr, formula, err := userBMR(user, weight, request.BodyFatPercentage, measuredAt)
 
 	if err != nil {
-		return err
+		return Weight{}, err
+	}
+
+	target, err := w.CaloricTarget(bmr, user.ActivityLevel, user.Sex, user.WeightGoal, user.WeeklyRate, user.UnitSystem)
+
+	if err != nil {
+		return Weight{}, err
 	}
 
 	newWeight := Weight{
-		Weight:             request.Weight,
+		MeasuredAt:         measuredAt,
+		Weight:             weight,
 		UserID:             user.ID,
 		BMR:                bmr,
-		DailyCaloricIntake: dailyIntake,
+		BMRFormula:         formula,
+		DailyCaloricIntake: target.DailyCaloricIntake,
+		BodyFatPercentage:  request.BodyFatPercentage,
 	}
 
-	err = w.storage.CreateWeightEntry(newWeight)
+	created, err := w.storage.CreateWeightEntry(ctx, newWeight)
 
 	if err != nil {
+		return Weight{}, err
+	}
+
+	created, err = renderWeight(created, user)
+
+	if err != nil {
+		return Weight{}, err
+	}
+
+	created.Warnings = target.Warnings
+
+	return created, nil
+}
+
+// Get returns a weight entry in the units of its owner
+func (w *weightService) Get(ctx context.Context, weightID int) (Weight, error) {
+	entry, err := w.storage.GetWeight(ctx, weightID)
+
+	if err != nil {
+		return Weight{}, err
+	}
+
+	user, err := w.storage.GetUser(ctx, entry.UserID)
+
+	if err != nil {
+		return Weight{}, err
+	}
+
+	return renderWeight(entry, user)
+}
+
+// Update changes the weight of an entry. BMR and daily caloric intake are
+// recalculated from the owner's current profile, since they depend on the weight.
+// Like New, it reads and writes in one transaction.
+func (w *weightService) Update(ctx context.Context, request UpdateWeightRequest) (updated Weight, err error) {
+	err = w.storage.WithTx(ctx, func(ctx context.Context) error {
+		updated, err = w.update(ctx, request)
 		return err
+	})
+
+	return
+}
+
+func (w *weightService) update(ctx context.Context, request UpdateWeightRequest) (Weight, error) {
+	var entry Weight
+	var user User
+	var err error
+
+	// the weight is checked in the units of the owner of the entry
+	if request.ID != 0 {
+		entry, err = w.storage.GetWeight(ctx, request.ID)
+
+		if err != nil {
+			return Weight{}, err
+		}
+
+		user, err = w.storage.GetUser(ctx, entry.UserID)
+
+		if err != nil {
+			return Weight{}, err
+		}
+	}
+
+	if err = validateUpdateWeight(request, user.UnitSystem); err != nil {
+		return Weight{}, err
+	}
+
+	if request.MeasuredAt != nil {
+		entry.MeasuredAt, err = measuredAtOrNow(request.MeasuredAt)
+
+		if err != nil {
+			return Weight{}, err
+		}
+	}
+
+	weight, err := weightInKilograms(request.Weight, request.Unit, user)
+
+	if err != nil {
+		return Weight{}, err
+	}
+
+	if request.BodyFatPercentage != nil {
+		entry.BodyFatPercentage = request.BodyFatPercentage
+	}
+
+	bmr, formula, err := userBMR(user, weight, entry.BodyFatPercentage, entry.MeasuredAt)
+
+	if err != nil {
+		return Weight{}, err
+	}
+
+	target, err := w.CaloricTarget(bmr, user.ActivityLevel, user.Sex, user.WeightGoal, user.WeeklyRate, user.UnitSystem)
+
+	if err != nil {
+		return Weight{}, err
 	}
 
-	return nil
+	entry.Weight = weight
+	entry.BMR = bmr
+	entry.BMRFormula = formula
+	entry.DailyCaloricIntake = target.DailyCaloricIntake
+
+	updated, err := w.storage.UpdateWeightEntry(ctx, entry)
+
+	if err != nil {
+		return Weight{}, err
+	}
+
+	updated, err = renderWeight(updated, user)
+
+	if err != nil {
+		return Weight{}, err
+	}
+
+	updated.Warnings = target.Warnings
+
+	return updated, nil
 }
 
-func (w *weightService) CalculateBMR(height, age, weight int, sex string) (int, error) {
-	var sexModifier int
+// weightInKilograms converts a submitted weight to kilograms. Weights sent
+// without a unit are taken to be in the unit of the user's unit system.
+func weightInKilograms(weight float64, unit string, user User) (float64, error) {
+	if unit == "" {
+		unit = weightUnit(user.UnitSystem)
+	}
 
-	switch sex {
-	case "male":
-		sexModifier = -5
-	case "female":
-		sexModifier = 161
-	default:
-		return 0, errors.New("invalid variable sex provided to CalculateBMR. needs to be either male or female")
+	return toKilograms(weight, unit)
+}
+
+// renderWeight adds the health metrics to an entry stored in kilograms and
+// converts it to the units of its owner
+func renderWeight(entry Weight, user User) (Weight, error) {
+	metrics, err := healthMetrics(user, entry.Weight, entry.BMR)
+
+	if err != nil {
+		return Weight{}, err
 	}
 
-	return (10 * weight) + int(float64(height)*6.25) - (5 * age) - sexModifier, nil
+	entry.Metrics = &metrics
+
+	return entry.inUnits(user.UnitSystem), nil
 }
 
+// measuredAtOrNow defaults a missing measured_at to now and rejects readings from the future
+func measuredAtOrNow(measuredAt *time.Time) (time.Time, error) {
+	now := time.Now()
+
+	if measuredAt == nil {
+		return now, nil
+	}
+
+	if measuredAt.After(now.Add(measuredAtTolerance)) {
+		return time.Time{}, ValidationError("measured_at", "weight service - measured_at cannot be in the future")
+	}
+
+	return *measuredAt, nil
+}
+
+func (w *weightService) Delete(ctx context.Context, weightID int) (deletedWeightID int, err error) {
+	deletedWeightID, err = w.storage.DeleteWeightEntry(ctx, weightID)
+
+	if err != nil {
+		return
+	} else if deletedWeightID == 0 {
+		err = NotFoundError("weight service - weight with given id does not exist")
+		return
+	}
+
+	return
+}
+
+// CalculateBMR expects the height in centimeters and the weight in kilograms.
+// It uses the default formula, Mifflin-St Jeor.
+func (w *weightService) CalculateBMR(height float64, age int, weight float64, sex string) (int, error) {
+	return mifflinStJeor{}.Calculate(BMRInput{Height: height, Age: age, Weight: weight, Sex: sex})
+}
+
+// userBMR calculates the BMR with the formula the user chose. Katch-McArdle needs a
+// body fat percentage, so entries without one fall back to the default formula.
+// The name of the formula that was actually used is returned along with the BMR.
+// The age is the age of the user when the weight was measured.
+func userBMR(user User, weight float64, bodyFatPercentage *float64, measuredAt time.Time) (int, string, error) {
+	formula, err := BMRFormulaByName(user.BMRFormula)
+
+	if err != nil {
+		return 0, "", err
+	}
+
+	if formula.Name() == KatchMcArdle && bodyFatPercentage == nil {
+		formula = mifflinStJeor{}
+	}
+
+	bmr, err := formula.Calculate(BMRInput{
+		Height:            user.Height,
+		Weight:            weight,
+		Age:               ageAt(user.DateOfBirth, measuredAt),
+		Sex:               user.Sex,
+		BodyFatPercentage: bodyFatPercentage,
+	})
+
+	if err != nil {
+		return 0, "", err
+	}
+
+	return bmr, formula.Name(), nil
+}
+
+// DailyIntake applies the fixed deficit or surplus of a weight goal preset to the
+// maintenance calories. See CaloricTarget for intakes based on a weekly rate.
 func (w *weightService) DailyIntake(BMR, activityLevel int, weightGoal string) (int, error) {
-	var maintenanceCalories int
-
-	switch activityLevel {
-	case 1:
-		maintenanceCalories = int(float64(BMR) * veryLowActivity)
-	case 2:
-		maintenanceCalories = int(float64(BMR) * lightActivity)
-	case 3:
-		maintenanceCalories = int(float64(BMR) * moderateActivity)
-	case 4:
-		maintenanceCalories = int(float64(BMR) * highActivity)
-	case 5:
-		maintenanceCalories = int(float64(BMR) * veryHighActivity)
-	default:
-		return 0, errors.New("invalid variable activityLevel - needs to be 1, 2, 3, 4 or 5")
+	maintenanceCalories, err := maintenanceCalories(BMR, activityLevel)
+
+	if err != nil {
+		return 0, err
 	}
 
-	var dailyCaloricIntake int
+	delta, err := goalPresetDelta(weightGoal)
+
+	if err != nil {
+		return 0, err
+	}
 
-	switch weightGoal {
-	case "gain":
-		dailyCaloricIntake = maintenanceCalories + 500
-	case "loose":
-		dailyCaloricIntake = maintenanceCalories - 500
-	case "maintain":
-		dailyCaloricIntake = maintenanceCalories
+	return maintenanceCalories + delta, nil
+}
+
+// History returns a page of the weight entries of a user, filtered by the
+// requested dates. Entries are sorted by the time they were measured, newest first
+// unless the order "asc" is requested.
+func (w *weightService) History(ctx context.Context, request WeightHistoryRequest) (WeightHistory, error) {
+	if request.UserID == 0 {
+		return WeightHistory{}, ValidationError("user_id", "weight service - user ID cannot be 0")
+	}
+
+	if request.From != nil && request.To != nil && request.From.After(*request.To) {
+		return WeightHistory{}, ValidationError("from", "weight service - from must not be after to")
+	}
+
+	filter := WeightFilter{
+		UserID: request.UserID,
+		From:   request.From,
+		To:     request.To,
+		Limit:  request.Limit,
+	}
+
+	switch request.Order {
+	case "", "desc":
+		filter.Descending = true
+	case "asc":
+		filter.Descending = false
 	default:
-		return 0, errors.New("invalid weight goal provided - must be gain, loose or maintain")
+		return WeightHistory{}, ValidationError("order", "weight service - order must be asc or desc")
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultHistoryLimit
+	} else if filter.Limit > maxHistoryLimit {
+		filter.Limit = maxHistoryLimit
+	}
+
+	if request.Cursor != "" {
+		cursor, err := decodeWeightCursor(request.Cursor)
+
+		if err != nil {
+			return WeightHistory{}, err
+		}
+
+		filter.After = &cursor
+	}
+
+	// make sure the user exists before looking at the entries, we also need
+	// the unit system to render the entries in
+	user, err := w.storage.GetUser(ctx, request.UserID)
+
+	if err != nil {
+		return WeightHistory{}, err
+	}
+
+	// ask for one more entry than needed, so we know if there is a next page
+	limit := filter.Limit
+	filter.Limit++
+
+	weights, err := w.storage.GetWeights(ctx, filter)
+
+	if err != nil {
+		return WeightHistory{}, err
+	}
+
+	for i := range weights {
+		if weights[i], err = renderWeight(weights[i], user); err != nil {
+			return WeightHistory{}, err
+		}
+	}
+
+	history := WeightHistory{Weights: weights}
+
+	if len(weights) > limit {
+		history.Weights = weights[:limit]
+		last := history.Weights[limit-1]
+		history.NextCursor = encodeWeightCursor(WeightCursor{MeasuredAt: last.MeasuredAt, ID: last.ID})
+	}
+
+	if history.Weights == nil {
+		history.Weights = []Weight{}
+	}
+
+	return history, nil
+}
+
+// cursors are handed to clients as opaque strings
+func encodeWeightCursor(cursor WeightCursor) string {
+	raw := fmt.Sprintf("%d:%d", cursor.MeasuredAt.UnixNano(), cursor.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeWeightCursor(encoded string) (WeightCursor, error) {
+	invalid := ValidationError("cursor", "weight service - invalid cursor")
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+
+	if err != nil {
+		return WeightCursor{}, invalid
+	}
+
+	parts := strings.Split(string(raw), ":")
+
+	if len(parts) != 2 {
+		return WeightCursor{}, invalid
+	}
+
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+
+	if err != nil {
+		return WeightCursor{}, invalid
+	}
+
+	ID, err := strconv.Atoi(parts[1])
+
+	if err != nil {
+		return WeightCursor{}, invalid
 	}
 
-	return dailyCaloricIntake, nil
+	return WeightCursor{MeasuredAt: time.Unix(0, nanos), ID: ID}, nil
 }
@@ -1,27 +1,100 @@
 package api_test
 
 import (
-	"errors"
+	"context"
 	"reflect"
+	"sort"
 	"testing"
+	"time"
 	"weight-tracker/pkg/api"
+	"weight-tracker/pkg/repository"
 )
 
-type mockWeightRepo struct{}
+type mockWeightRepo struct {
+	weights []api.Weight
+}
+
+// the mocks have no transactions, fn runs against the mock as it is
+func (m mockWeightRepo) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (m mockWeightRepo) CreateWeightEntry(ctx context.Context, w api.Weight) (api.Weight, error) {
+	return w, nil
+}
+
+func (m mockWeightRepo) GetWeight(ctx context.Context, weightID int) (api.Weight, error) {
+	for _, weight := range m.weights {
+		if weight.ID == weightID {
+			return weight, nil
+		}
+	}
+
+	return api.Weight{}, api.NotFoundError("storage - weight does not exist")
+}
+
+func (m mockWeightRepo) UpdateWeightEntry(ctx context.Context, w api.Weight) (api.Weight, error) {
+	return w, nil
+}
+
+func (m mockWeightRepo) DeleteWeightEntry(ctx context.Context, weightID int) (deletedWeightID int, err error) {
+	for _, weight := range m.weights {
+		if weight.ID == weightID {
+			return weightID, nil
+		}
+	}
 
-func (m mockWeightRepo) CreateWeightEntry(w api.Weight) error {
-	return nil
+	return 0, nil
 }
 
-func (m mockWeightRepo) GetUser(userID int) (api.User, error) {
+func (m mockWeightRepo) GetWeights(ctx context.Context, filter api.WeightFilter) (weights []api.Weight, err error) {
+	for _, weight := range m.weights {
+		if weight.UserID != filter.UserID {
+			continue
+		}
+
+		if filter.From != nil && weight.MeasuredAt.Before(*filter.From) {
+			continue
+		}
+
+		if filter.To != nil && weight.MeasuredAt.After(*filter.To) {
+			continue
+		}
+
+		if filter.After != nil {
+			if filter.Descending && !weight.MeasuredAt.Before(filter.After.MeasuredAt) {
+				continue
+			} else if !filter.Descending && !weight.MeasuredAt.After(filter.After.MeasuredAt) {
+				continue
+			}
+		}
+
+		weights = append(weights, weight)
+	}
+
+	sort.Slice(weights, func(i, j int) bool {
+		if filter.Descending {
+			return weights[i].MeasuredAt.After(weights[j].MeasuredAt)
+		}
+		return weights[i].MeasuredAt.Before(weights[j].MeasuredAt)
+	})
+
+	if filter.Limit > 0 && len(weights) > filter.Limit {
+		weights = weights[:filter.Limit]
+	}
+
+	return
+}
+
+func (m mockWeightRepo) GetUser(ctx context.Context, userID int) (api.User, error) {
 	if userID != 1 {
-		return api.User{}, errors.New("storage - user doesn't exists")
+		return api.User{}, api.NotFoundError("storage - user does not exist")
 	}
 
 	return api.User{
 		ID:            userID,
 		Name:          "Test user",
-		Age:           20,
+		DateOfBirth:   bornYearsAgo(20),
 		Height:        185,
 		WeightGoal:    "maintain",
 		Sex:           "female",
@@ -30,10 +103,88 @@ func (m mockWeightRepo) GetUser(userID int) (api.User, error) {
 	}, nil
 }
 
+// txWeightRepo records which repository calls were made within a transaction
+type txWeightRepo struct {
+	mockWeightRepo
+	inTx map[string]bool
+}
+
+type mockTxKey struct{}
+
+func (m txWeightRepo) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(context.WithValue(ctx, mockTxKey{}, true))
+}
+
+func (m txWeightRepo) GetUser(ctx context.Context, userID int) (api.User, error) {
+	m.inTx["GetUser"] = ctx.Value(mockTxKey{}) != nil
+	return m.mockWeightRepo.GetUser(ctx, userID)
+}
+
+func (m txWeightRepo) CreateWeightEntry(ctx context.Context, w api.Weight) (api.Weight, error) {
+	m.inTx["CreateWeightEntry"] = ctx.Value(mockTxKey{}) != nil
+	return m.mockWeightRepo.CreateWeightEntry(ctx, w)
+}
+
+func TestCreateWeightEntryInTransaction(t *testing.T) {
+	mockRepo := txWeightRepo{inTx: map[string]bool{}}
+	mockWeightService := api.NewWeightService(&mockRepo)
+
+	_, err := mockWeightService.New(context.Background(), api.NewWeightRequest{Weight: 70, UserID: 1})
+
+	if err != nil {
+		t.Fatalf("creating the entry failed: %v", err)
+	}
+
+	want := map[string]bool{"GetUser": true, "CreateWeightEntry": true}
+
+	if !reflect.DeepEqual(mockRepo.inTx, want) {
+		t.Errorf("the user should be read and the entry created in one transaction. got: %v, wanted: %v", mockRepo.inTx, want)
+	}
+}
+
+func TestCreateWeightEntryClampsUnsafeWeeklyRate(t *testing.T) {
+	storage := repository.NewMemoryStorage()
+	userService := api.NewUserService(storage)
+	weightService := api.NewWeightService(storage)
+	rate := -2.5
+
+	// the weekly rate stands in for the weight goal
+	userID, err := userService.New(context.Background(), api.NewUserRequest{
+		Name: "test user", DateOfBirth: bornYearsAgo(20), Height: 185, Sex: "female", ActivityLevel: 5,
+		Email: "test@mail.com", Password: "correct horse", WeeklyRate: &rate,
+	})
+
+	if err != nil {
+		t.Fatalf("saving the user failed: %v", err)
+	}
+
+	user, err := userService.GetUser(context.Background(), userID)
+
+	if err != nil || user.WeeklyRate == nil || *user.WeeklyRate != rate {
+		t.Fatalf("the weekly rate should be kept as given. got: %v %v, wanted: %v", user.WeeklyRate, err, rate)
+	}
+
+	weight, err := weightService.New(context.Background(), api.NewWeightRequest{Weight: 70, UserID: userID})
+
+	if err != nil {
+		t.Fatalf("creating the entry failed: %v", err)
+	}
+
+	// a maintenance of 3030 kcal, less 1100 kcal for losing 1 kg a week
+	want := []string{"the requested rate of -2.50 kg/week was clamped to -1.00 kg/week"}
+
+	if !reflect.DeepEqual(weight.Warnings, want) || weight.DailyCaloricIntake != 1930 {
+		t.Errorf("got: %v, %v kcal, wanted: %v, %v kcal", weight.Warnings, weight.DailyCaloricIntake, want, 1930)
+	}
+}
+
 func TestCreateWeightEntry(t *testing.T) {
 	mockRepo := mockWeightRepo{}
 	mockUserService := api.NewWeightService(&mockRepo)
 
+	yesterday := time.Now().Add(-24 * time.Hour)
+	tomorrow := time.Now().Add(24 * time.Hour)
+
 	tests := []struct {
 		name    string
 		request api.NewWeightRequest
@@ -52,13 +203,29 @@ func TestCreateWeightEntry(t *testing.T) {
 				Weight: 70,
 				UserID: 2,
 			},
-			want: errors.New("storage - user doesn't exists"),
+			want: api.NotFoundError("storage - user does not exist"),
+		}, {
+			name: "should create a backdated entry",
+			request: api.NewWeightRequest{
+				Weight:     70,
+				UserID:     1,
+				MeasuredAt: &yesterday,
+			},
+			want: nil,
+		}, {
+			name: "should return an error because the entry was measured in the future",
+			request: api.NewWeightRequest{
+				Weight:     70,
+				UserID:     1,
+				MeasuredAt: &tomorrow,
+			},
+			want: api.ValidationError("measured_at", "weight service - measured_at cannot be in the future"),
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			err := mockUserService.New(test.request)
+			_, err := mockUserService.New(context.Background(), test.request)
 			if !reflect.DeepEqual(err, test.want) {
 				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want)
 			}
@@ -66,15 +233,120 @@ func TestCreateWeightEntry(t *testing.T) {
 	}
 }
 
+func TestUpdateWeightEntry(t *testing.T) {
+	measuredAt := time.Now()
+	tenYearsAgo := measuredAt.AddDate(-10, 0, 0)
+
+	mockRepo := mockWeightRepo{weights: []api.Weight{
+		{ID: 1, Weight: 70, UserID: 1, BMR: 1595, DailyCaloricIntake: 3030, MeasuredAt: measuredAt},
+		{ID: 2, Weight: 70, UserID: 2, BMR: 1595, DailyCaloricIntake: 3030, MeasuredAt: measuredAt},
+		{ID: 3, Weight: 70, UserID: 1, BMR: 1645, DailyCaloricIntake: 3125, MeasuredAt: tenYearsAgo},
+	}}
+	mockWeightService := api.NewWeightService(&mockRepo)
+
+	// the mocked user is a very active woman of 185 cm, who turned 20 yesterday
+	ideal := api.IdealWeight{Devine: 75.02, Robinson: 70.82, HealthyMin: 63.32, HealthyMax: 85.53, Unit: "kg"}
+	metrics := &api.HealthMetrics{BMI: 23.37, BMICategory: "normal", TDEE: 3220, IdealWeight: ideal}
+	metricsAtTen := &api.HealthMetrics{BMI: 23.37, BMICategory: "normal", TDEE: 3315, IdealWeight: ideal}
+
+	tests := []struct {
+		name       string
+		request    api.UpdateWeightRequest
+		want       api.Weight
+		want_error error
+	}{
+		{
+			name:    "should update the weight and recalculate bmr and daily intake",
+			request: api.UpdateWeightRequest{ID: 1, Weight: 80},
+			want:    api.Weight{ID: 1, MeasuredAt: measuredAt, Weight: 80, Unit: "kg", UserID: 1, BMR: 1695, BMRFormula: "mifflin_st_jeor", DailyCaloricIntake: 3220, Metrics: metrics},
+		}, {
+			name:    "should convert a weight given in pounds to kilograms",
+			request: api.UpdateWeightRequest{ID: 1, Weight: 176.37, Unit: "lb"},
+			want:    api.Weight{ID: 1, MeasuredAt: measuredAt, Weight: 80, Unit: "kg", UserID: 1, BMR: 1695, BMRFormula: "mifflin_st_jeor", DailyCaloricIntake: 3220, Metrics: metrics},
+		}, {
+			name:    "should calculate the bmr with the age of the user when the weight was measured",
+			request: api.UpdateWeightRequest{ID: 3, Weight: 80},
+			want:    api.Weight{ID: 3, MeasuredAt: tenYearsAgo, Weight: 80, Unit: "kg", UserID: 1, BMR: 1745, BMRFormula: "mifflin_st_jeor", DailyCaloricIntake: 3315, Metrics: metricsAtTen},
+		}, {
+			name:       "should return an error for an unknown unit",
+			request:    api.UpdateWeightRequest{ID: 1, Weight: 80, Unit: "stone"},
+			want_error: api.ValidationError("unit", "invalid weight unit - must be kg or lb"),
+		}, {
+			name:       "should return an error when the weight is not positive",
+			request:    api.UpdateWeightRequest{ID: 1, Weight: 0},
+			want_error: api.ValidationError("weight", "weight service - weight must be greater than 0"),
+		}, {
+			name:       "should return an error when the entry does not exist",
+			request:    api.UpdateWeightRequest{ID: 4, Weight: 80},
+			want_error: api.NotFoundError("storage - weight does not exist"),
+		}, {
+			name:       "should return an error when the owner of the entry does not exist",
+			request:    api.UpdateWeightRequest{ID: 2, Weight: 80},
+			want_error: api.NotFoundError("storage - user does not exist"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			weight, err := mockWeightService.Update(context.Background(), test.request)
+
+			if !reflect.DeepEqual(err, test.want_error) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want_error)
+			}
+
+			if !reflect.DeepEqual(weight, test.want) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, weight, test.want)
+			}
+		})
+	}
+}
+
+func TestDeleteWeightEntry(t *testing.T) {
+	mockRepo := mockWeightRepo{weights: []api.Weight{{ID: 1, Weight: 70, UserID: 1}}}
+	mockWeightService := api.NewWeightService(&mockRepo)
+
+	tests := []struct {
+		name       string
+		request    int
+		want_id    int
+		want_error error
+	}{
+		{
+			name:    "should delete the entry since it exists",
+			request: 1,
+			want_id: 1,
+		}, {
+			name:       "should return an error when the entry does not exist",
+			request:    2,
+			want_id:    0,
+			want_error: api.NotFoundError("weight service - weight with given id does not exist"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			weightID, err := mockWeightService.Delete(context.Background(), test.request)
+
+			if !reflect.DeepEqual(err, test.want_error) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want_error)
+			}
+
+			if weightID != test.want_id {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, weightID, test.want_id)
+			}
+		})
+	}
+}
+
 func TestCalculateBMR(t *testing.T) {
 	mockRepo := mockWeightRepo{}
 	mockUserService := api.NewWeightService(&mockRepo)
 
 	tests := []struct {
 		name   string
-		Height int
+		Height float64
 		Age    int
-		Weight int
+		Weight float64
 		Sex    string
 		want   int
 		err    error
@@ -102,7 +374,7 @@ func TestCalculateBMR(t *testing.T) {
 			Weight: 65,
 			Sex:    "",
 			want:   0,
-			err:    errors.New("invalid variable sex provided to CalculateBMR. needs to be either male or female"),
+			err:    api.ValidationError("sex", "invalid variable sex provided to CalculateBMR. needs to be either male or female"),
 		},
 	}
 
@@ -183,3 +455,220 @@ func TestDailyIntake(t *testing.T) {
 		})
 	}
 }
+
+func TestWeightHistory(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2022, time.May, d, 8, 0, 0, 0, time.UTC)
+	}
+
+	var history []api.Weight
+	for i := 1; i <= 5; i++ {
+		history = append(history, api.Weight{ID: i, MeasuredAt: day(i), Weight: float64(70 + i), UserID: 1})
+	}
+
+	mockRepo := mockWeightRepo{weights: history}
+	mockWeightService := api.NewWeightService(&mockRepo)
+
+	from, to := day(2), day(4)
+
+	tests := []struct {
+		name        string
+		request     api.WeightHistoryRequest
+		want_ids    []int
+		want_cursor bool
+		want_error  error
+	}{
+		{
+			name:     "should return all entries newest first by default",
+			request:  api.WeightHistoryRequest{UserID: 1},
+			want_ids: []int{5, 4, 3, 2, 1},
+		}, {
+			name:     "should return entries oldest first when asked",
+			request:  api.WeightHistoryRequest{UserID: 1, Order: "asc"},
+			want_ids: []int{1, 2, 3, 4, 5},
+		}, {
+			name:     "should only return entries within the date range",
+			request:  api.WeightHistoryRequest{UserID: 1, From: &from, To: &to},
+			want_ids: []int{4, 3, 2},
+		}, {
+			name:        "should return a cursor when there are more entries",
+			request:     api.WeightHistoryRequest{UserID: 1, Limit: 2},
+			want_ids:    []int{5, 4},
+			want_cursor: true,
+		}, {
+			name:       "should return an error when from is after to",
+			request:    api.WeightHistoryRequest{UserID: 1, From: &to, To: &from},
+			want_error: api.ValidationError("from", "weight service - from must not be after to"),
+		}, {
+			name:       "should return an error for an invalid cursor",
+			request:    api.WeightHistoryRequest{UserID: 1, Cursor: "not a cursor"},
+			want_error: api.ValidationError("cursor", "weight service - invalid cursor"),
+		}, {
+			name:       "should return an error when the user does not exist",
+			request:    api.WeightHistoryRequest{UserID: 2},
+			want_error: api.NotFoundError("storage - user does not exist"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page, err := mockWeightService.History(context.Background(), test.request)
+
+			if !reflect.DeepEqual(err, test.want_error) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want_error)
+			}
+
+			var ids []int
+			for _, weight := range page.Weights {
+				ids = append(ids, weight.ID)
+			}
+
+			if !reflect.DeepEqual(ids, test.want_ids) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, ids, test.want_ids)
+			}
+
+			if (page.NextCursor != "") != test.want_cursor {
+				t.Errorf("test: %v failed. got cursor: %q", test.name, page.NextCursor)
+			}
+		})
+	}
+}
+
+func TestWeightHistoryPagination(t *testing.T) {
+	var history []api.Weight
+	for i := 1; i <= 5; i++ {
+		history = append(history, api.Weight{
+			ID: i, UserID: 1,
+			MeasuredAt: time.Date(2022, time.May, i, 8, 0, 0, 0, time.UTC),
+		})
+	}
+
+	mockRepo := mockWeightRepo{weights: history}
+	mockWeightService := api.NewWeightService(&mockRepo)
+
+	var ids []int
+	request := api.WeightHistoryRequest{UserID: 1, Limit: 2}
+
+	for {
+		page, err := mockWeightService.History(context.Background(), request)
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for _, weight := range page.Weights {
+			ids = append(ids, weight.ID)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+
+		request.Cursor = page.NextCursor
+	}
+
+	want := []int{5, 4, 3, 2, 1}
+
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("got: %v, wanted: %v", ids, want)
+	}
+}
+
+func TestCaloricTarget(t *testing.T) {
+	mockRepo := mockWeightRepo{}
+	mockWeightService := api.NewWeightService(&mockRepo)
+
+	rate := func(r float64) *float64 { return &r }
+
+	tests := []struct {
+		name          string
+		BMR           int
+		ActivityLevel int
+		sex           string
+		weightGoal    string
+		weeklyRate    *float64
+		unitSystem    string
+		want          api.CaloricTarget
+		err           error
+	}{
+		{
+			name:          "should fall back to the weight goal preset without a weekly rate",
+			BMR:           1441,
+			ActivityLevel: 1,
+			sex:           "female",
+			weightGoal:    "loose",
+			want:          api.CaloricTarget{Maintenance: 1729, Delta: -500, DailyCaloricIntake: 1229},
+		}, {
+			name:          "should convert a weekly loss into a daily deficit",
+			BMR:           1441,
+			ActivityLevel: 3,
+			sex:           "female",
+			weightGoal:    "loose",
+			weeklyRate:    rate(-0.5),
+			want:          api.CaloricTarget{Maintenance: 2233, Delta: -550, DailyCaloricIntake: 1683},
+		}, {
+			name:          "should convert a weekly gain into a daily surplus",
+			BMR:           1441,
+			ActivityLevel: 3,
+			sex:           "female",
+			weightGoal:    "gain",
+			weeklyRate:    rate(0.25),
+			want:          api.CaloricTarget{Maintenance: 2233, Delta: 275, DailyCaloricIntake: 2508},
+		}, {
+			name:          "should clamp a rate that is too aggressive",
+			BMR:           1441,
+			ActivityLevel: 5,
+			sex:           "male",
+			weightGoal:    "loose",
+			weeklyRate:    rate(-2),
+			want: api.CaloricTarget{
+				Maintenance: 2737, Delta: -1100, DailyCaloricIntake: 1637,
+				Warnings: []string{"the requested rate of -2.00 kg/week was clamped to -1.00 kg/week"},
+			},
+		}, {
+			name:          "should report a clamped rate in pounds to imperial users",
+			BMR:           1441,
+			ActivityLevel: 5,
+			sex:           "male",
+			weightGoal:    "loose",
+			weeklyRate:    rate(-2),
+			unitSystem:    "imperial",
+			want: api.CaloricTarget{
+				Maintenance: 2737, Delta: -1100, DailyCaloricIntake: 1637,
+				Warnings: []string{"the requested rate of -4.41 lb/week was clamped to -2.20 lb/week"},
+			},
+		}, {
+			name:          "should not go below the minimum intake",
+			BMR:           1441,
+			ActivityLevel: 1,
+			sex:           "female",
+			weightGoal:    "loose",
+			weeklyRate:    rate(-0.5),
+			want: api.CaloricTarget{
+				Maintenance: 1729, Delta: -529, DailyCaloricIntake: 1200,
+				Warnings: []string{"the daily intake was raised to the minimum of 1200 kcal"},
+			},
+		}, {
+			name:          "should return an error for an invalid weight goal",
+			BMR:           1441,
+			ActivityLevel: 1,
+			sex:           "female",
+			weightGoal:    "heavy",
+			err:           api.ValidationError("weight_goal", "invalid weight goal provided - must be gain, loose or maintain"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			target, err := mockWeightService.CaloricTarget(test.BMR, test.ActivityLevel, test.sex, test.weightGoal, test.weeklyRate, test.unitSystem)
+
+			if !reflect.DeepEqual(err, test.err) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.err)
+			}
+
+			if !reflect.DeepEqual(target, test.want) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, target, test.want)
+			}
+		})
+	}
+}
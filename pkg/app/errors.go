@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"weight-tracker/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorResponse is the body of every failed request:
+//
+//	{
+//	  "error": {
+//	    "code": "validation_failed",
+//	    "message": "user service - email required",
+//	    "fields": {"email": "user service - email required"}
+//	  }
+//	}
+//
+// code is one of validation_failed (400), unauthorized (401), forbidden (403),
+// not_found (404), conflict (409), precondition_failed (412),
+// precondition_required (428), timeout (503) or internal (500). fields is only
+// set for validation errors and names the invalid request fields.
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+type ErrorBody struct {
+	Code    api.ErrorCode     `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// codes of errors that do not come from the services
+const (
+	codeTimeout  api.ErrorCode = "timeout"
+	codeInternal api.ErrorCode = "internal"
+)
+
+var errorStatus = map[api.ErrorCode]int{
+	api.CodeValidation:   http.StatusBadRequest,
+	api.CodeUnauthorized: http.StatusUnauthorized,
+	api.CodeForbidden:    http.StatusForbidden,
+	api.CodeNotFound:     http.StatusNotFound,
+	api.CodeConflict:     http.StatusConflict,
+
+	api.CodePreconditionFailed:   http.StatusPreconditionFailed,
+	api.CodePreconditionRequired: http.StatusPreconditionRequired,
+}
+
+// HandleErrors writes the error a handler aborted with as an ErrorResponse
+func (s *Server) HandleErrors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		status, body := errorResponse(err)
+
+		if status >= http.StatusInternalServerError {
+			log.Printf("request error: %v", err)
+		}
+
+		c.JSON(status, ErrorResponse{Error: body})
+	}
+}
+
+func errorResponse(err error) (int, ErrorBody) {
+	var apiErr *api.Error
+
+	if errors.As(err, &apiErr) {
+		if status, ok := errorStatus[apiErr.Code]; ok {
+			return status, ErrorBody{Code: apiErr.Code, Message: apiErr.Message, Fields: apiErr.Fields}
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return http.StatusServiceUnavailable, ErrorBody{Code: codeTimeout, Message: "the request took too long"}
+	}
+
+	// unexpected errors are logged, but not shown to the client
+	return http.StatusInternalServerError, ErrorBody{Code: codeInternal, Message: "something went wrong"}
+}
+
+// abortWithError stops the request, HandleErrors writes the response
+func abortWithError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
@@ -0,0 +1,119 @@
+package app_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+	"weight-tracker/pkg/api"
+	"weight-tracker/pkg/app"
+)
+
+func TestHandleErrors(t *testing.T) {
+	member := map[string]interface{}{"name": "Rabbit", "email": "rabbit@email.com", "password": "correct horse"}
+
+	tests := []struct {
+		name        string
+		userService stubUserService
+		timeout     time.Duration
+		method      string
+		path        string
+		body        interface{}
+		headers     map[string]string
+		want_status int
+		want_body   app.ErrorBody
+	}{
+		{
+			name:        "should name the invalid field of a request",
+			method:      http.MethodGet,
+			path:        "/v1/api/user/rabbit",
+			headers:     memberAuth(),
+			want_status: http.StatusBadRequest,
+			want_body: app.ErrorBody{
+				Code:    api.CodeValidation,
+				Message: "userId must be a number",
+				Fields:  map[string]string{"userId": "userId must be a number"},
+			},
+		}, {
+			name:        "should answer not found for a user that does not exist",
+			userService: stubUserService{err: api.NotFoundError("storage - user does not exist")},
+			method:      http.MethodGet,
+			path:        userPath(1, ""),
+			headers:     memberAuth(),
+			want_status: http.StatusNotFound,
+			want_body:   app.ErrorBody{Code: api.CodeNotFound, Message: "storage - user does not exist"},
+		}, {
+			name:        "should answer conflict for a taken email",
+			userService: stubUserService{err: api.ConflictError("user service - user with email already exists")},
+			method:      http.MethodPost,
+			path:        "/v1/api/auth/register",
+			body:        member,
+			want_status: http.StatusConflict,
+			want_body:   app.ErrorBody{Code: api.CodeConflict, Message: "user service - user with email already exists"},
+		}, {
+			name:        "should answer not found for an unknown route",
+			method:      http.MethodGet,
+			path:        "/v1/api/unknown",
+			want_status: http.StatusNotFound,
+			want_body:   app.ErrorBody{Code: api.CodeNotFound, Message: "route not found"},
+		}, {
+			name:        "should answer service unavailable when the request runs out of time",
+			timeout:     50 * time.Millisecond,
+			method:      http.MethodGet,
+			path:        userPath(1, "/weights"),
+			headers:     memberAuth(),
+			want_status: http.StatusServiceUnavailable,
+			want_body:   app.ErrorBody{Code: "timeout", Message: "the request took too long"},
+		}, {
+			name:        "should answer unauthorized without a token",
+			method:      http.MethodGet,
+			path:        userPath(1, ""),
+			want_status: http.StatusUnauthorized,
+			want_body:   app.ErrorBody{Code: api.CodeUnauthorized, Message: "authorization token required"},
+		}, {
+			name:        "should answer forbidden for the data of another user",
+			method:      http.MethodGet,
+			path:        userPath(2, ""),
+			headers:     memberAuth(),
+			want_status: http.StatusForbidden,
+			want_body:   app.ErrorBody{Code: api.CodeForbidden, Message: "not allowed to access this user"},
+		}, {
+			name:        "should hide unexpected errors",
+			userService: stubUserService{err: errors.New("connection refused")},
+			method:      http.MethodGet,
+			path:        userPath(1, ""),
+			headers:     memberAuth(),
+			want_status: http.StatusInternalServerError,
+			want_body:   app.ErrorBody{Code: "internal", Message: "something went wrong"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			timeout := test.timeout
+
+			if timeout == 0 {
+				timeout = time.Second
+			}
+
+			router := newTestRouter(test.userService, stubWeightService{}, timeout)
+			recorder := serve(router, test.method, test.path, test.body, test.headers)
+
+			var response app.ErrorResponse
+
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("test: %v failed. could not decode %q: %v", test.name, recorder.Body.String(), err)
+			}
+
+			if recorder.Code != test.want_status {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, recorder.Code, test.want_status)
+			}
+
+			if !reflect.DeepEqual(response.Error, test.want_body) {
+				t.Errorf("test: %v failed. got: %+v, wanted: %+v", test.name, response.Error, test.want_body)
+			}
+		})
+	}
+}
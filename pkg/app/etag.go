@@ -0,0 +1,90 @@
+package app
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"weight-tracker/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// userETag is the entity tag of the representation of a user. The age is
+// derived from the date of birth on every request, so it is part of the tag
+// next to the version: a cached user goes stale on the user's birthday too.
+func userETag(user api.User) string {
+	return `"` + strconv.Itoa(user.Version) + "-" + strconv.Itoa(user.Age) + `"`
+}
+
+// ifMatch reads the version of the user a request changes from its If-Match
+// header. Changes without the header are refused, so clients cannot overwrite
+// changes they have not seen. If-Match: * changes whatever version is stored.
+func ifMatch(c *gin.Context) (version int, ok bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+
+	if header == "" {
+		abortWithError(c, api.PreconditionRequiredError("an If-Match header with the ETag of the user is required"))
+		return 0, false
+	}
+
+	if header == "*" {
+		return api.AnyVersion, true
+	}
+
+	version, err := parseETag(header)
+
+	if err != nil {
+		abortWithError(c, api.PreconditionFailedError("If-Match does not match the current version of the user"))
+		return 0, false
+	}
+
+	return version, true
+}
+
+// parseETag reads the version from a strong entity tag made by userETag. Weak
+// tags are never used for changes, so they are rejected too. The age in the
+// tag is not stored, so only the version is compared with the stored user.
+func parseETag(tag string) (int, error) {
+	if len(tag) < 2 || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+		return 0, errors.New("malformed entity tag")
+	}
+
+	parts := strings.SplitN(tag[1:len(tag)-1], "-", 2)
+
+	if len(parts) != 2 {
+		return 0, errors.New("malformed entity tag")
+	}
+
+	version, err := strconv.Atoi(parts[0])
+
+	if err != nil || version <= 0 {
+		return 0, errors.New("unknown entity tag")
+	}
+
+	if _, err := strconv.Atoi(parts[1]); err != nil {
+		return 0, errors.New("unknown entity tag")
+	}
+
+	return version, nil
+}
+
+// notModified tells whether the client already has the representation with
+// the entity tag, by its If-None-Match header
+func notModified(c *gin.Context, etag string) bool {
+	header := c.GetHeader("If-None-Match")
+
+	if header == "" {
+		return false
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		// If-None-Match compares weakly
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,156 @@
+package app_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+	"weight-tracker/pkg/api"
+	"weight-tracker/pkg/app"
+)
+
+// the signed in member, as it is stored
+var storedMember = api.User{ID: 1, Name: "rabbit", Age: 34, Role: api.RoleMember, Version: 1}
+
+func TestGetUserIfNoneMatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		want_status int
+	}{
+		{
+			name:        "should send the user without If-None-Match",
+			want_status: http.StatusOK,
+		}, {
+			name:        "should answer not modified when the client has the current version",
+			ifNoneMatch: `"1-34"`,
+			want_status: http.StatusNotModified,
+		}, {
+			name:        "should compare weakly",
+			ifNoneMatch: `"3-34", W/"1-34"`,
+			want_status: http.StatusNotModified,
+		}, {
+			name:        "should send the user when the client has another version",
+			ifNoneMatch: `"2-34"`,
+			want_status: http.StatusOK,
+		}, {
+			name:        "should send the user when the age has changed since the client got it",
+			ifNoneMatch: `"1-33"`,
+			want_status: http.StatusOK,
+		}, {
+			name:        "should send the user for a tag without the age",
+			ifNoneMatch: `"1"`,
+			want_status: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := newTestRouter(stubUserService{user: storedMember}, stubWeightService{}, time.Second)
+			headers := memberAuth()
+
+			if test.ifNoneMatch != "" {
+				headers["If-None-Match"] = test.ifNoneMatch
+			}
+
+			recorder := serve(router, http.MethodGet, userPath(1, ""), nil, headers)
+
+			if recorder.Code != test.want_status {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, recorder.Code, test.want_status)
+			}
+
+			if etag := recorder.Header().Get("ETag"); etag != `"1-34"` {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, etag, `"1-34"`)
+			}
+
+			if test.want_status == http.StatusNotModified && recorder.Body.Len() != 0 {
+				t.Errorf("test: %v failed. got: %q, wanted no body", test.name, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestChangeUserIfMatch(t *testing.T) {
+	changes := []struct {
+		method string
+		body   interface{}
+	}{
+		{method: http.MethodPut, body: map[string]interface{}{"name": "Rabbit", "email": "rabbit@email.com"}},
+		{method: http.MethodPatch, body: map[string]interface{}{"name": "Mole"}},
+		{method: http.MethodDelete},
+	}
+
+	tests := []struct {
+		name        string
+		ifMatch     string
+		want_status int
+		want_code   api.ErrorCode
+	}{
+		{
+			name:        "should require If-Match",
+			want_status: http.StatusPreconditionRequired,
+			want_code:   api.CodePreconditionRequired,
+		}, {
+			name:        "should refuse a stale version",
+			ifMatch:     `"2-34"`,
+			want_status: http.StatusPreconditionFailed,
+			want_code:   api.CodePreconditionFailed,
+		}, {
+			name:        "should refuse a weak tag",
+			ifMatch:     `W/"1-34"`,
+			want_status: http.StatusPreconditionFailed,
+			want_code:   api.CodePreconditionFailed,
+		}, {
+			name:        "should refuse a malformed tag",
+			ifMatch:     `1`,
+			want_status: http.StatusPreconditionFailed,
+			want_code:   api.CodePreconditionFailed,
+		}, {
+			name:        "should refuse a tag without the age",
+			ifMatch:     `"1"`,
+			want_status: http.StatusPreconditionFailed,
+			want_code:   api.CodePreconditionFailed,
+		}, {
+			name:        "should change the current version",
+			ifMatch:     `"1-34"`,
+			want_status: http.StatusOK,
+		}, {
+			name:        "should change the current version when the age has changed since",
+			ifMatch:     `"1-33"`,
+			want_status: http.StatusOK,
+		}, {
+			name:        "should change any version with *",
+			ifMatch:     `*`,
+			want_status: http.StatusOK,
+		},
+	}
+
+	for _, change := range changes {
+		for _, test := range tests {
+			t.Run(change.method+" "+test.name, func(t *testing.T) {
+				router := newTestRouter(stubUserService{user: storedMember}, stubWeightService{}, time.Second)
+				headers := memberAuth()
+
+				if test.ifMatch != "" {
+					headers["If-Match"] = test.ifMatch
+				}
+
+				recorder := serve(router, change.method, userPath(1, ""), change.body, headers)
+
+				if recorder.Code != test.want_status {
+					t.Fatalf("test: %v failed. got: %v %s, wanted: %v", test.name, recorder.Code, recorder.Body.String(), test.want_status)
+				}
+
+				if test.want_code != "" {
+					var response app.ErrorResponse
+
+					if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil || response.Error.Code != test.want_code {
+						t.Errorf("test: %v failed. got: %s, %v, wanted: %v", test.name, recorder.Body.String(), err, test.want_code)
+					}
+				} else if change.method != http.MethodDelete && recorder.Header().Get("ETag") != `"2-34"` {
+					t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, recorder.Header().Get("ETag"), `"2-34"`)
+				}
+			})
+		}
+	}
+}
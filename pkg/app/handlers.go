@@ -1,9 +1,10 @@
 package app
 
 import (
-	"log"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 	"weight-tracker/pkg/api"
 
 	"github.com/gin-gonic/gin"
@@ -22,21 +23,97 @@ func (s *Server) ApiStatus() gin.HandlerFunc {
 	}
 }
 
-func (s *Server) GetUser() gin.HandlerFunc {
+func (s *Server) Register() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "application/json")
+
+		var newUser api.NewUserRequest
+
+		if !bindJSON(c, &newUser) {
+			return
+		}
+
+		userID, err := s.userService.New(c.Request.Context(), newUser)
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		token, err := s.authService.IssueToken(userID)
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		response := struct {
+			Status string
+			Data   string
+			UserID int
+			Token  string
+		}{
+			Status: "success",
+			Data:   "user registered",
+			UserID: userID,
+			Token:  token,
+		}
+
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+func (s *Server) Login() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID, err := strconv.Atoi(c.Param("userId"))
+		c.Header("Content-Type", "application/json")
+
+		var login api.LoginRequest
+
+		if !bindJSON(c, &login) {
+			return
+		}
+
+		token, err := s.authService.Login(c.Request.Context(), login)
 
 		if err != nil {
-			log.Printf("handler error: %v", err)
-			c.JSON(http.StatusBadRequest, nil)
+			abortWithError(c, err)
+			return
+		}
+
+		response := struct {
+			Status string
+			Data   string
+			Token  string
+		}{
+			Status: "success",
+			Data:   "logged in",
+			Token:  token,
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+func (s *Server) GetUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := pathID(c, "userId")
+
+		if !ok {
 			return
 		}
 
-		user, err := s.userService.GetUser(userID)
+		user, err := s.userService.GetUser(c.Request.Context(), userID)
 
 		if err != nil {
-			log.Printf("handler error: %v", err)
-			c.JSON(http.StatusBadRequest, nil)
+			abortWithError(c, err)
+			return
+		}
+
+		etag := userETag(user)
+		c.Header("ETag", etag)
+
+		if notModified(c, etag) {
+			c.Status(http.StatusNotModified)
 			return
 		}
 
@@ -46,11 +123,10 @@ func (s *Server) GetUser() gin.HandlerFunc {
 
 func (s *Server) GetUsers() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		users, err := s.userService.All()
+		users, err := s.accessService.VisibleUsers(c.Request.Context(), currentUser(c))
 
 		if err != nil {
-			log.Printf("service error: %v", err)
-			c.JSON(http.StatusInternalServerError, nil)
+			abortWithError(c, err)
 			return
 		}
 
@@ -63,148 +139,922 @@ func (s *Server) CreateUser() gin.HandlerFunc {
 		c.Header("Content-Type", "application/json")
 
 		var newUser api.NewUserRequest
-		var userID int
-		var response = struct {
+
+		if !bindJSON(c, &newUser) {
+			return
+		}
+
+		userID, err := s.userService.New(c.Request.Context(), newUser)
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		response := struct {
 			Status string
 			Data   string
 			UserID int
 		}{
-			Status: "failed",
+			Status: "success",
+			Data:   "user created",
 			UserID: userID,
 		}
 
-		err := c.ShouldBindJSON(&newUser)
+		c.JSON(http.StatusCreated, response)
+	}
+}
 
-		if err != nil {
-			response.Data = err.Error()
+func (s *Server) DeleteUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "application/json")
+
+		userID, ok := pathID(c, "userId")
 
-			log.Printf("handler error: %v", err)
-			c.JSON(http.StatusBadRequest, response)
+		if !ok {
 			return
 		}
 
-		userID, err = s.userService.New(newUser)
+		version, ok := ifMatch(c)
 
-		if err != nil {
-			response.Data = err.Error()
+		if !ok {
+			return
+		}
+
+		userID, err := s.userService.Delete(c.Request.Context(), userID, version)
 
-			log.Printf("service error: %v", err)
-			c.JSON(http.StatusInternalServerError, response)
+		if err != nil {
+			abortWithError(c, err)
 			return
 		}
 
-		response.Status = "success"
-		response.Data = "user created"
-		response.UserID = userID
+		response := struct {
+			Status string
+			Data   string
+			UserID int
+		}{
+			Status: "success",
+			Data:   "user deleted",
+			UserID: userID,
+		}
 
-		c.JSON(http.StatusCreated, response)
+		c.JSON(http.StatusOK, response)
 	}
 }
 
-func (s *Server) DeleteUser() gin.HandlerFunc {
+func (s *Server) UpdateUser() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Content-Type", "application/json")
 
-		userID, err := strconv.Atoi(c.Param("userId"))
-		var response = struct {
+		var updateUser api.UpdateUserRequest
+
+		if !bindJSON(c, &updateUser) {
+			return
+		}
+
+		// the user to update is identified by the path, not the body
+		userID, ok := pathID(c, "userId")
+
+		if !ok {
+			return
+		}
+
+		updateUser.ID = userID
+		updateUser.Version, ok = ifMatch(c)
+
+		if !ok {
+			return
+		}
+
+		user, err := s.userService.Update(c.Request.Context(), updateUser)
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Header("ETag", userETag(user))
+
+		response := struct {
 			Status string
 			Data   string
-			UserID int
+			User   api.User
 		}{
-			Status: "failed",
-			UserID: userID,
+			Status: "success",
+			Data:   "user updated",
+			User:   user,
 		}
 
-		if err != nil {
-			response.Data = err.Error()
-			log.Printf("handler error: %v", err)
-			c.JSON(http.StatusBadRequest, nil)
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// RestoreUser undoes the deletion of a user
+func (s *Server) RestoreUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "application/json")
+
+		userID, ok := pathID(c, "userId")
+
+		if !ok {
 			return
 		}
 
-		userID, err = s.userService.Delete(userID)
+		user, err := s.userService.Restore(c.Request.Context(), userID)
 
 		if err != nil {
-			response.Data = err.Error()
-			log.Printf("handler error: %v", err)
-			c.JSON(http.StatusInternalServerError, nil)
+			abortWithError(c, err)
 			return
 		}
 
-		response.Status = "success"
-		response.Data = "user deleted"
+		c.Header("ETag", userETag(user))
+
+		response := struct {
+			Status string
+			Data   string
+			User   api.User
+		}{
+			Status: "success",
+			Data:   "user restored",
+			User:   user,
+		}
+
 		c.JSON(http.StatusOK, response)
 	}
 }
 
-func (s *Server) UpdateUser() gin.HandlerFunc {
+// PatchUser applies a JSON merge patch to the user, see api.UserPatch
+func (s *Server) PatchUser() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Content-Type", "application/json")
 
-		var updateUser api.UpdateUserRequest
-		var user api.User
-		var response = struct {
+		userID, ok := pathID(c, "userId")
+
+		if !ok {
+			return
+		}
+
+		version, ok := ifMatch(c)
+
+		if !ok {
+			return
+		}
+
+		var patch api.UserPatch
+
+		if !bindJSON(c, &patch) {
+			return
+		}
+
+		user, err := s.userService.Patch(c.Request.Context(), userID, version, patch)
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Header("ETag", userETag(user))
+
+		response := struct {
 			Status string
 			Data   string
 			User   api.User
 		}{
-			Status: "failed",
+			Status: "success",
+			Data:   "user updated",
+			User:   user,
 		}
 
-		err := c.ShouldBindJSON(&updateUser)
+		c.JSON(http.StatusOK, response)
+	}
+}
 
-		if err != nil {
-			response.Data = err.Error()
+func (s *Server) CreateWeightEntry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "application/json")
 
-			log.Printf("handler error: %v", err)
-			c.JSON(http.StatusBadRequest, nil)
+		var newWeight api.NewWeightRequest
+
+		if !bindJSON(c, &newWeight) {
+			return
+		}
+
+		// entries are created for the authenticated user, unless someone else is named
+		if newWeight.UserID == 0 {
+			newWeight.UserID = currentUser(c).ID
+		} else if !s.allowed(c, newWeight.UserID, api.ManageAccess) {
 			return
 		}
 
-		user, err = s.userService.Update(updateUser)
+		weight, err := s.weightService.New(c.Request.Context(), newWeight)
 
 		if err != nil {
-			response.Data = err.Error()
-
-			log.Printf("service error: %v", err)
-			c.JSON(http.StatusInternalServerError, response)
+			abortWithError(c, err)
 			return
 		}
 
-		response.Status = "success"
-		response.Data = "user updated"
-		response.User = user
+		response := map[string]interface{}{
+			"status": "success",
+			"data":   "new weight created",
+			"weight": weight,
+		}
 
 		c.JSON(http.StatusOK, response)
 	}
 }
 
-func (s *Server) CreateWeightEntry() gin.HandlerFunc {
+func (s *Server) GetWeightEntry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		weightID, ok := pathID(c, "weightId")
+
+		if !ok {
+			return
+		}
+
+		weight, ok := s.authorizedWeight(c, weightID, api.ReadAccess)
+
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, weight)
+	}
+}
+
+func (s *Server) UpdateWeightEntry() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Content-Type", "application/json")
 
-		var newWeight api.NewWeightRequest
-		err := c.ShouldBindJSON(&newWeight)
+		var updateWeight api.UpdateWeightRequest
+
+		weightID, ok := pathID(c, "weightId")
+
+		if !ok || !bindJSON(c, &updateWeight) {
+			return
+		}
+
+		// the entry to update is identified by the path, not the body
+		updateWeight.ID = weightID
+
+		if _, ok = s.authorizedWeight(c, weightID, api.ManageAccess); !ok {
+			return
+		}
+
+		weight, err := s.weightService.Update(c.Request.Context(), updateWeight)
 
 		if err != nil {
-			log.Printf("handler error: %v", err)
-			c.JSON(http.StatusBadRequest, nil)
+			abortWithError(c, err)
+			return
+		}
+
+		response := struct {
+			Status string
+			Data   string
+			Weight api.Weight
+		}{
+			Status: "success",
+			Data:   "weight updated",
+			Weight: weight,
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+func (s *Server) DeleteWeightEntry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "application/json")
+
+		weightID, ok := pathID(c, "weightId")
+
+		if !ok {
+			return
+		}
+
+		if _, ok = s.authorizedWeight(c, weightID, api.ManageAccess); !ok {
 			return
 		}
 
-		err = s.weightService.New(newWeight)
+		weightID, err := s.weightService.Delete(c.Request.Context(), weightID)
 
 		if err != nil {
-			log.Printf("service error: %v", err)
-			c.JSON(http.StatusInternalServerError, nil)
+			abortWithError(c, err)
 			return
 		}
 
-		response := map[string]string{
-			"status": "success",
-			"data":   "new user created",
+		response := struct {
+			Status   string
+			Data     string
+			WeightID int
+		}{
+			Status:   "success",
+			Data:     "weight deleted",
+			WeightID: weightID,
 		}
 
 		c.JSON(http.StatusOK, response)
 	}
 }
+
+// authorizedWeight returns the weight entry when the authenticated user has the
+// access to the user it belongs to. Entries they have no access to are not found,
+// the same as entries that do not exist, so their ids are not given away. When
+// the entry is not returned, the request is aborted.
+func (s *Server) authorizedWeight(c *gin.Context, weightID int, access api.Access) (api.Weight, bool) {
+	notFound := api.NotFoundError("weight does not exist")
+	weight, err := s.weightService.Get(c.Request.Context(), weightID)
+
+	if errors.Is(err, api.ErrNotFound) {
+		abortWithError(c, notFound)
+		return api.Weight{}, false
+	} else if err != nil {
+		abortWithError(c, err)
+		return api.Weight{}, false
+	}
+
+	allowed, err := s.accessService.Allowed(c.Request.Context(), currentUser(c), weight.UserID, access)
+
+	if err != nil {
+		abortWithError(c, err)
+		return api.Weight{}, false
+	}
+
+	if !allowed {
+		abortWithError(c, notFound)
+		return api.Weight{}, false
+	}
+
+	return weight, true
+}
+
+func (s *Server) GetWeights() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "application/json")
+
+		userID, ok := pathID(c, "userId")
+
+		if !ok {
+			return
+		}
+
+		request := api.WeightHistoryRequest{
+			UserID: userID,
+			Order:  c.Query("order"),
+			Cursor: c.Query("cursor"),
+		}
+
+		var err error
+
+		if limit := c.Query("limit"); limit != "" {
+			request.Limit, err = strconv.Atoi(limit)
+
+			if err != nil {
+				abortWithError(c, api.ValidationError("limit", "limit must be a number"))
+				return
+			}
+		}
+
+		if request.From, ok = dateQuery(c, "from", false); !ok {
+			return
+		}
+
+		if request.To, ok = dateQuery(c, "to", true); !ok {
+			return
+		}
+
+		history, err := s.weightService.History(c.Request.Context(), request)
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, history)
+	}
+}
+
+func (s *Server) GetWeightTrend() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "application/json")
+
+		userID, ok := pathID(c, "userId")
+
+		if !ok {
+			return
+		}
+
+		request := api.TrendRequest{
+			UserID: userID,
+			Period: c.Query("period"),
+		}
+
+		if request.From, ok = dateQuery(c, "from", false); !ok {
+			return
+		}
+
+		if request.To, ok = dateQuery(c, "to", true); !ok {
+			return
+		}
+
+		trend, err := s.analyticsService.Trend(c.Request.Context(), request)
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, trend)
+	}
+}
+
+func (s *Server) GetMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := pathID(c, "userId")
+
+		if !ok {
+			return
+		}
+
+		metrics, err := s.analyticsService.Metrics(c.Request.Context(), userID)
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, metrics)
+	}
+}
+
+// dateQuery reads a date from the query string. When it cannot be parsed the request is aborted.
+func dateQuery(c *gin.Context, name string, endOfDay bool) (*time.Time, bool) {
+	date, err := parseDateQuery(c.Query(name), endOfDay)
+
+	if err != nil {
+		abortWithError(c, api.ValidationError(name, err.Error()))
+		return nil, false
+	}
+
+	return date, true
+}
+
+// parseDateQuery accepts either a plain date (2006-01-02) or a full RFC3339 timestamp.
+// A plain date used as an upper bound covers the whole day.
+func parseDateQuery(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		if endOfDay {
+			date = date.Add(24*time.Hour - time.Nanosecond)
+		}
+
+		return &date, nil
+	}
+
+	timestamp, err := time.Parse(time.RFC3339, value)
+
+	if err != nil {
+		return nil, errors.New("dates must be formatted as 2006-01-02 or RFC3339")
+	}
+
+	return &timestamp, nil
+}
+
+func (s *Server) CreateGoal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "application/json")
+
+		var newGoal api.NewGoalRequest
+
+		userID, ok := pathID(c, "userId")
+
+		if !ok || !bindJSON(c, &newGoal) {
+			return
+		}
+
+		newGoal.UserID = userID
+
+		goal, err := s.goalService.New(c.Request.Context(), newGoal)
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		response := struct {
+			Status string
+			Data   string
+			Goal   api.GoalProgress
+		}{
+			Status: "success",
+			Data:   "goal created",
+			Goal:   goal,
+		}
+
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+func (s *Server) GetGoal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := pathID(c, "userId")
+
+		if !ok {
+			return
+		}
+
+		goal, err := s.goalService.Progress(c.Request.Context(), userID)
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, goal)
+	}
+}
+
+func (s *Server) UpdateGoal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "application/json")
+
+		var updateGoal api.UpdateGoalRequest
+
+		userID, ok := pathID(c, "userId")
+
+		if !ok || !bindJSON(c, &updateGoal) {
+			return
+		}
+
+		updateGoal.UserID = userID
+
+		goal, err := s.goalService.Update(c.Request.Context(), updateGoal)
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		response := struct {
+			Status string
+			Data   string
+			Goal   api.GoalProgress
+		}{
+			Status: "success",
+			Data:   "goal updated",
+			Goal:   goal,
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+func (s *Server) CreateMeasurement() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "application/json")
+
+		var newMeasurement api.NewMeasurementRequest
+
+		userID, ok := pathID(c, "userId")
+
+		if !ok || !bindJSON(c, &newMeasurement) {
+			return
+		}
+
+		newMeasurement.UserID = userID
+
+		measurement, err := s.measurementService.New(c.Request.Context(), newMeasurement)
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		response := struct {
+			Status      string
+			Data        string
+			Measurement api.Measurement
+		}{
+			Status:      "success",
+			Data:        "measurement created",
+			Measurement: measurement,
+		}
+
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+func (s *Server) GetMeasurement() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := pathID(c, "userId")
+
+		if !ok {
+			return
+		}
+
+		measurementID, ok := pathID(c, "measurementId")
+
+		if !ok {
+			return
+		}
+
+		measurement, err := s.measurementService.Get(c.Request.Context(), userID, measurementID)
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, measurement)
+	}
+}
+
+func (s *Server) GetMeasurements() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "application/json")
+
+		userID, ok := pathID(c, "userId")
+
+		if !ok {
+			return
+		}
+
+		request := api.MeasurementHistoryRequest{
+			UserID: userID,
+			Order:  c.Query("order"),
+		}
+
+		var err error
+
+		if limit := c.Query("limit"); limit != "" {
+			request.Limit, err = strconv.Atoi(limit)
+
+			if err != nil {
+				abortWithError(c, api.ValidationError("limit", "limit must be a number"))
+				return
+			}
+		}
+
+		if request.From, ok = dateQuery(c, "from", false); !ok {
+			return
+		}
+
+		if request.To, ok = dateQuery(c, "to", true); !ok {
+			return
+		}
+
+		history, err := s.measurementService.History(c.Request.Context(), request)
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, history)
+	}
+}
+
+func (s *Server) UpdateMeasurement() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "application/json")
+
+		var updateMeasurement api.UpdateMeasurementRequest
+
+		userID, ok := pathID(c, "userId")
+
+		if !ok {
+			return
+		}
+
+		measurementID, ok := pathID(c, "measurementId")
+
+		if !ok || !bindJSON(c, &updateMeasurement) {
+			return
+		}
+
+		// the measurement to update is identified by the path, not the body
+		updateMeasurement.ID = measurementID
+		updateMeasurement.UserID = userID
+
+		measurement, err := s.measurementService.Update(c.Request.Context(), updateMeasurement)
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		response := struct {
+			Status      string
+			Data        string
+			Measurement api.Measurement
+		}{
+			Status:      "success",
+			Data:        "measurement updated",
+			Measurement: measurement,
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+func (s *Server) DeleteMeasurement() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "application/json")
+
+		userID, ok := pathID(c, "userId")
+
+		if !ok {
+			return
+		}
+
+		measurementID, ok := pathID(c, "measurementId")
+
+		if !ok {
+			return
+		}
+
+		measurementID, err := s.measurementService.Delete(c.Request.Context(), userID, measurementID)
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		response := struct {
+			Status        string
+			Data          string
+			MeasurementID int
+		}{
+			Status:        "success",
+			Data:          "measurement deleted",
+			MeasurementID: measurementID,
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+func (s *Server) UpdateRole() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "application/json")
+
+		var updateRole api.UpdateRoleRequest
+
+		if !bindJSON(c, &updateRole) {
+			return
+		}
+
+		// the user to update is identified by the path, not the body
+		userID, ok := pathID(c, "userId")
+
+		if !ok {
+			return
+		}
+
+		updateRole.UserID = userID
+
+		user, err := s.accessService.UpdateRole(c.Request.Context(), updateRole)
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		response := struct {
+			Status string
+			Data   string
+			User   api.User
+		}{
+			Status: "success",
+			Data:   "role updated",
+			User:   user,
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+func (s *Server) GetClients() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		coachID, ok := pathID(c, "userId")
+
+		if !ok {
+			return
+		}
+
+		clients, err := s.accessService.Clients(c.Request.Context(), coachID)
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, clients)
+	}
+}
+
+func (s *Server) AddClient() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "application/json")
+
+		var client api.ClientRequest
+
+		if !bindJSON(c, &client) {
+			return
+		}
+
+		// the coach is identified by the path, not the body
+		coachID, ok := pathID(c, "userId")
+
+		if !ok {
+			return
+		}
+
+		client.CoachID = coachID
+
+		err := s.accessService.AddClient(c.Request.Context(), client)
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		response := struct {
+			Status string
+			Data   string
+		}{
+			Status: "success",
+			Data:   "client added",
+		}
+
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+func (s *Server) RemoveClient() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "application/json")
+
+		coachID, ok := pathID(c, "userId")
+
+		if !ok {
+			return
+		}
+
+		clientID, ok := pathID(c, "clientId")
+
+		if !ok {
+			return
+		}
+
+		err := s.accessService.RemoveClient(c.Request.Context(), api.ClientRequest{CoachID: coachID, ClientID: clientID})
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		response := struct {
+			Status string
+			Data   string
+		}{
+			Status: "success",
+			Data:   "client removed",
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// NotFound answers requests for routes that do not exist
+func (s *Server) NotFound() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		abortWithError(c, api.NotFoundError("route not found"))
+	}
+}
+
+// pathID reads a numeric id from the path. When it is not a number the request is aborted.
+func pathID(c *gin.Context, name string) (int, bool) {
+	id, err := strconv.Atoi(c.Param(name))
+
+	if err != nil {
+		abortWithError(c, api.ValidationError(name, name+" must be a number"))
+		return 0, false
+	}
+
+	return id, true
+}
+
+// bindJSON decodes the request body. When it cannot be decoded the request is aborted.
+func bindJSON(c *gin.Context, request interface{}) bool {
+	err := c.ShouldBindJSON(request)
+
+	if err != nil {
+		abortWithError(c, api.ValidationError("body", err.Error()))
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"weight-tracker/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// the key the authenticated user is stored under in the gin context
+const currentUserKey = "currentUser"
+
+// Authenticate only lets requests with a valid bearer token through, and
+// attaches the user the token was issued for to the context
+func (s *Server) Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		token := strings.TrimPrefix(header, "Bearer ")
+
+		if header == "" || token == header {
+			abortWithError(c, api.UnauthorizedError("authorization token required"))
+			return
+		}
+
+		user, err := s.authService.Authenticate(c.Request.Context(), token)
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Set(currentUserKey, user)
+		c.Next()
+	}
+}
+
+// RequestTimeout gives every request a deadline, so a slow or abandoned
+// request does not keep its queries running
+func (s *Server) RequestTimeout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), s.requestTimeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// AuthorizeUser only lets the authenticated user through when they have the
+// requested access to the user in the :userId of the route
+func (s *Server) AuthorizeUser(access api.Access) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := pathID(c, "userId")
+
+		if !ok || !s.allowed(c, userID, access) {
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireRole only lets authenticated users with the given role through
+func (s *Server) RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if currentUser(c).Role != role {
+			abortWithError(c, api.ForbiddenError("this requires the "+role+" role"))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// allowed checks the access of the authenticated user to the data of a user.
+// When access is not allowed, the request is aborted.
+func (s *Server) allowed(c *gin.Context, userID int, access api.Access) bool {
+	allowed, err := s.accessService.Allowed(c.Request.Context(), currentUser(c), userID, access)
+
+	if err != nil {
+		abortWithError(c, err)
+		return false
+	}
+
+	if !allowed {
+		abortWithError(c, api.ForbiddenError("not allowed to access this user"))
+		return false
+	}
+
+	return true
+}
+
+// currentUser is the user attached by Authenticate
+func currentUser(c *gin.Context) api.User {
+	user, _ := c.MustGet(currentUserKey).(api.User)
+	return user
+}
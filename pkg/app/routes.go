@@ -1,28 +1,74 @@
 package app
 
-import "github.com/gin-gonic/gin"
+import (
+	"weight-tracker/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
 
 func (s *Server) Routes() *gin.Engine {
 	router := s.router
 
+	// failed requests all answer with an ErrorResponse, and queries are
+	// cancelled when a request takes too long or the client goes away
+	router.Use(s.HandleErrors(), s.RequestTimeout())
+	router.NoRoute(s.NotFound())
+
 	// group all routes under /v1/api
 	v1 := router.Group("/v1/api")
 	{
 		v1.GET("/status", s.ApiStatus())
-		// prefix the user routes
-		user := v1.Group("/user")
+		// prefix the auth routes
+		auth := v1.Group("/auth")
+		{
+			auth.POST("/register", s.Register())
+			auth.POST("/login", s.Login())
+		}
+
+		// creating a user is open to everyone, so new users can sign up
+		v1.POST("/user", s.CreateUser())
+
+		// prefix the user routes, these all need an authenticated user
+		user := v1.Group("/user", s.Authenticate())
 		{
-			user.GET("/:userId", s.GetUser())       // show
-			user.GET("", s.GetUsers())              // index
-			user.POST("", s.CreateUser())           // create
-			user.DELETE("/:userId", s.DeleteUser()) // delete
-			user.PUT("/:userId", s.UpdateUser())    // edit
+			read := s.AuthorizeUser(api.ReadAccess)
+			manage := s.AuthorizeUser(api.ManageAccess)
+			admin := s.RequireRole(api.RoleAdmin)
+
+			user.GET("/:userId", read, s.GetUser())               // show
+			user.GET("", s.GetUsers())                            // index
+			user.DELETE("/:userId", manage, s.DeleteUser())       // delete
+			user.PUT("/:userId", manage, s.UpdateUser())          // edit
+			user.PATCH("/:userId", manage, s.PatchUser())         // edit some fields
+			user.POST("/:userId/restore", admin, s.RestoreUser()) // undo a delete, deleted users cannot sign in
+
+			user.GET("/:userId/weights", read, s.GetWeights())           // weight history
+			user.GET("/:userId/weights/trend", read, s.GetWeightTrend()) // weight trend
+			user.GET("/:userId/metrics", read, s.GetMetrics())           // bmi, tdee and ideal weight
+
+			user.POST("/:userId/goal", manage, s.CreateGoal()) // create goal
+			user.GET("/:userId/goal", read, s.GetGoal())       // show goal progress
+			user.PUT("/:userId/goal", manage, s.UpdateGoal())  // edit goal
+
+			user.GET("/:userId/measurements", read, s.GetMeasurements())                       // measurement history
+			user.POST("/:userId/measurements", manage, s.CreateMeasurement())                  // create measurement
+			user.GET("/:userId/measurements/:measurementId", read, s.GetMeasurement())         // show measurement
+			user.PUT("/:userId/measurements/:measurementId", manage, s.UpdateMeasurement())    // edit measurement
+			user.DELETE("/:userId/measurements/:measurementId", manage, s.DeleteMeasurement()) // delete measurement
+
+			user.PUT("/:userId/role", admin, s.UpdateRole())                   // change role
+			user.GET("/:userId/clients", read, s.GetClients())                 // list clients of a coach
+			user.POST("/:userId/clients", admin, s.AddClient())                // assign a client to a coach
+			user.DELETE("/:userId/clients/:clientId", admin, s.RemoveClient()) // unassign a client
 		}
 
-		// prefix the weight routes
-		weight := v1.Group("/weight")
+		// prefix the weight routes, these all need an authenticated user
+		weight := v1.Group("/weight", s.Authenticate())
 		{
 			weight.POST("", s.CreateWeightEntry())
+			weight.GET("/:weightId", s.GetWeightEntry())       // show
+			weight.PUT("/:weightId", s.UpdateWeightEntry())    // edit
+			weight.DELETE("/:weightId", s.DeleteWeightEntry()) // delete
 		}
 	}
 
@@ -1,37 +1,77 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
+	"time"
 	"weight-tracker/pkg/api"
 
 	"github.com/gin-gonic/gin"
 )
 
+// timeouts of the http server, so slow clients cannot hold on to connections
+const (
+	readHeaderTimeout = 5 * time.Second
+	readTimeout       = 15 * time.Second
+	writeTimeout      = 30 * time.Second
+	idleTimeout       = 2 * time.Minute
+)
+
 type Server struct {
-	router        *gin.Engine
-	userService   api.UserService
-	weightService api.WeightService
+	httpServer         *http.Server
+	router             *gin.Engine
+	userService        api.UserService
+	weightService      api.WeightService
+	goalService        api.GoalService
+	measurementService api.MeasurementService
+	analyticsService   api.AnalyticsService
+	authService        api.AuthService
+	accessService      api.AccessService
+	requestTimeout     time.Duration
 }
 
-func NewServer(router *gin.Engine, userService api.UserService, weightService api.WeightService) *Server {
+func NewServer(router *gin.Engine, userService api.UserService, weightService api.WeightService, goalService api.GoalService, measurementService api.MeasurementService, analyticsService api.AnalyticsService, authService api.AuthService, accessService api.AccessService, requestTimeout time.Duration) *Server {
 	return &Server{
-		router:        router,
-		userService:   userService,
-		weightService: weightService,
+		httpServer: &http.Server{
+			Handler:           router,
+			ReadHeaderTimeout: readHeaderTimeout,
+			ReadTimeout:       readTimeout,
+			WriteTimeout:      writeTimeout,
+			IdleTimeout:       idleTimeout,
+		},
+		router:             router,
+		userService:        userService,
+		weightService:      weightService,
+		goalService:        goalService,
+		measurementService: measurementService,
+		analyticsService:   analyticsService,
+		authService:        authService,
+		accessService:      accessService,
+		requestTimeout:     requestTimeout,
 	}
 }
 
-func (s *Server) Run() error {
+// Run serves the API on the given address, e.g. ":8080", until Shutdown is called
+func (s *Server) Run(address string) error {
 	// run function that initializes the routes
-	r := s.Routes()
+	s.Routes()
 
-	// run the server through the router
-	err := r.Run()
+	s.httpServer.Addr = address
+	err := s.httpServer.ListenAndServe()
 
-	if err != nil {
-		log.Printf("Server - there was an error calling Run on router: %v", err)
+	// a server that was shut down stopped on purpose
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Server - there was an error serving on %s: %v", address, err)
 		return err
 	}
 
 	return nil
 }
+
+// Shutdown stops accepting new connections and waits for the requests in flight
+// to finish, or for ctx to be done
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
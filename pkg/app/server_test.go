@@ -0,0 +1,193 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+	"weight-tracker/pkg/api"
+	"weight-tracker/pkg/app"
+
+	"github.com/gin-gonic/gin"
+)
+
+// the tokens stubAuthService accepts, they authenticate the member with ID 1
+// and the coach of that member with ID 3
+const (
+	memberToken = "rabbit"
+	coachToken  = "badger"
+)
+
+// the stubs embed the service interfaces, so calling a method a stub does not
+// implement panics and shows the handler does more than the test expects
+
+type stubAuthService struct {
+	api.AuthService
+}
+
+func (s stubAuthService) Authenticate(ctx context.Context, token string) (api.User, error) {
+	switch token {
+	case memberToken:
+		return api.User{ID: 1, Role: api.RoleMember}, nil
+	case coachToken:
+		return api.User{ID: 3, Role: api.RoleCoach}, nil
+	default:
+		return api.User{}, api.UnauthorizedError("invalid or expired token")
+	}
+}
+
+func (s stubAuthService) IssueToken(userID int) (string, error) {
+	return memberToken, nil
+}
+
+// stubAccessService lets users access their own data, and the coach read the
+// data of the member with ID 1
+type stubAccessService struct {
+	api.AccessService
+}
+
+func (s stubAccessService) Allowed(ctx context.Context, actor api.User, userID int, access api.Access) (bool, error) {
+	coaching := actor.Role == api.RoleCoach && userID == 1 && access == api.ReadAccess
+
+	return actor.ID == userID || coaching, nil
+}
+
+// stubUserService stores a single user, or fails every call with err
+type stubUserService struct {
+	api.UserService
+	user api.User
+	err  error
+}
+
+func (s stubUserService) New(ctx context.Context, user api.NewUserRequest) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	return s.user.ID, nil
+}
+
+func (s stubUserService) GetUser(ctx context.Context, id int) (api.User, error) {
+	if s.err != nil {
+		return api.User{}, s.err
+	}
+
+	if id != s.user.ID {
+		return api.User{}, api.NotFoundError("storage - user does not exist")
+	}
+
+	return s.user, nil
+}
+
+func (s stubUserService) Update(ctx context.Context, user api.UpdateUserRequest) (api.User, error) {
+	return s.change(user.Version)
+}
+
+func (s stubUserService) Patch(ctx context.Context, userID, version int, patch api.UserPatch) (api.User, error) {
+	return s.change(version)
+}
+
+func (s stubUserService) Delete(ctx context.Context, userID, version int) (int, error) {
+	if _, err := s.change(version); err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// change answers like the user service, changes only apply to the stored version
+func (s stubUserService) change(version int) (api.User, error) {
+	if version != api.AnyVersion && version != s.user.Version {
+		return api.User{}, api.PreconditionFailedError("user service - user was changed since it was read")
+	}
+
+	changed := s.user
+	changed.Version++
+
+	return changed, nil
+}
+
+// stubWeightService has the entries with ID 10 of user 1 and with ID 20 of
+// user 2, and takes until the request is cancelled to load weight histories
+type stubWeightService struct {
+	api.WeightService
+}
+
+func (s stubWeightService) Get(ctx context.Context, weightID int) (api.Weight, error) {
+	owners := map[int]int{10: 1, 20: 2}
+
+	if _, ok := owners[weightID]; !ok {
+		return api.Weight{}, api.NotFoundError("storage - weight does not exist")
+	}
+
+	return api.Weight{ID: weightID, UserID: owners[weightID], Weight: 70}, nil
+}
+
+func (s stubWeightService) Delete(ctx context.Context, weightID int) (int, error) {
+	return weightID, nil
+}
+
+func (s stubWeightService) History(ctx context.Context, request api.WeightHistoryRequest) (api.WeightHistory, error) {
+	<-ctx.Done()
+
+	return api.WeightHistory{}, ctx.Err()
+}
+
+// newTestRouter serves the routes of the server with the given services, see
+// stubAuthService for the users signed in
+func newTestRouter(userService api.UserService, weightService api.WeightService, requestTimeout time.Duration) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	server := app.NewServer(
+		gin.New(),
+		userService,
+		weightService,
+		nil,
+		nil,
+		nil,
+		stubAuthService{},
+		stubAccessService{},
+		requestTimeout,
+	)
+
+	return server.Routes()
+}
+
+// serve sends a request with a JSON body, when there is one, to the router
+func serve(router http.Handler, method, path string, body interface{}, headers map[string]string) *httptest.ResponseRecorder {
+	var reader bytes.Buffer
+
+	if body != nil {
+		_ = json.NewEncoder(&reader).Encode(body)
+	}
+
+	request := httptest.NewRequest(method, path, &reader)
+	request.Header.Set("Content-Type", "application/json")
+
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+// memberAuth is the header authenticating the member with ID 1
+func memberAuth() map[string]string {
+	return map[string]string{"Authorization": "Bearer " + memberToken}
+}
+
+// coachAuth is the header authenticating the coach of the member
+func coachAuth() map[string]string {
+	return map[string]string{"Authorization": "Bearer " + coachToken}
+}
+
+// userPath is the path of the user, with the rest appended
+func userPath(userID int, rest string) string {
+	return "/v1/api/user/" + strconv.Itoa(userID) + rest
+}
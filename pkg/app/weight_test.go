@@ -0,0 +1,93 @@
+package app_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+	"weight-tracker/pkg/api"
+	"weight-tracker/pkg/app"
+)
+
+func TestWeightEntryAccess(t *testing.T) {
+	notFound := app.ErrorBody{Code: api.CodeNotFound, Message: "weight does not exist"}
+
+	tests := []struct {
+		name        string
+		method      string
+		weightID    string
+		headers     map[string]string
+		want_status int
+		want_error  app.ErrorBody
+	}{
+		{
+			name:        "should show an entry of the member",
+			method:      http.MethodGet,
+			weightID:    "10",
+			headers:     memberAuth(),
+			want_status: http.StatusOK,
+		}, {
+			name:        "should show an entry of a client to their coach",
+			method:      http.MethodGet,
+			weightID:    "10",
+			headers:     coachAuth(),
+			want_status: http.StatusOK,
+		}, {
+			name:        "should delete an entry of the member",
+			method:      http.MethodDelete,
+			weightID:    "10",
+			headers:     memberAuth(),
+			want_status: http.StatusOK,
+		}, {
+			name:        "should not let a coach delete an entry of a client",
+			method:      http.MethodDelete,
+			weightID:    "10",
+			headers:     coachAuth(),
+			want_status: http.StatusNotFound,
+			want_error:  notFound,
+		}, {
+			name:        "should not show an entry of another user",
+			method:      http.MethodGet,
+			weightID:    "20",
+			headers:     memberAuth(),
+			want_status: http.StatusNotFound,
+			want_error:  notFound,
+		}, {
+			name:        "should answer the same for an entry that does not exist",
+			method:      http.MethodGet,
+			weightID:    "30",
+			headers:     memberAuth(),
+			want_status: http.StatusNotFound,
+			want_error:  notFound,
+		}, {
+			name:        "should not delete an entry of another user",
+			method:      http.MethodDelete,
+			weightID:    "20",
+			headers:     memberAuth(),
+			want_status: http.StatusNotFound,
+			want_error:  notFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := newTestRouter(stubUserService{}, stubWeightService{}, time.Second)
+			recorder := serve(router, test.method, "/v1/api/weight/"+test.weightID, nil, test.headers)
+
+			if recorder.Code != test.want_status {
+				t.Fatalf("test: %v failed. got: %v %s, wanted: %v", test.name, recorder.Code, recorder.Body.String(), test.want_status)
+			}
+
+			if test.want_status == http.StatusOK {
+				return
+			}
+
+			var response app.ErrorResponse
+
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil || !reflect.DeepEqual(response.Error, test.want_error) {
+				t.Errorf("test: %v failed. got: %s, %v, wanted: %+v", test.name, recorder.Body.String(), err, test.want_error)
+			}
+		})
+	}
+}
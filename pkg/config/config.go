@@ -0,0 +1,416 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config is everything the server needs to know about the environment it runs in.
+// It is loaded from, in order of precedence, command line flags, environment
+// variables, a YAML config file and the defaults.
+type Config struct {
+	Storage         string        `yaml:"storage"`
+	DatabaseURL     string        `yaml:"database_url"`
+	ListenAddress   string        `yaml:"listen_address"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	RequestTimeout  time.Duration `yaml:"request_timeout"`
+	CORSOrigins     []string      `yaml:"cors_origins"`
+	LogLevel        string        `yaml:"log_level"`
+	Migrations      Migrations    `yaml:"migrations"`
+	TokenSecret     string        `yaml:"token_secret"`
+	TokenTTL        time.Duration `yaml:"token_ttl"`
+	Purge           Purge         `yaml:"purge"`
+}
+
+// Migrations controls whether the server migrates the database on startup
+type Migrations struct {
+	Auto bool   `yaml:"auto"`
+	Dir  string `yaml:"dir"`
+}
+
+// Purge controls the job that removes deleted users for good. Every Interval
+// the users deleted more than Retention ago are purged, an Interval of 0 turns
+// the job off.
+type Purge struct {
+	Interval  time.Duration `yaml:"interval"`
+	Retention time.Duration `yaml:"retention"`
+}
+
+// storages the server can keep its data in. The database storage uses the
+// postgres or SQLite database at the database url, the memory storage is lost
+// when the server stops and is meant for tests and demos.
+const (
+	StorageDatabase = "database"
+	StorageMemory   = "memory"
+)
+
+// log levels, from the most to the least verbose
+const (
+	LogDebug = "debug"
+	LogInfo  = "info"
+	LogWarn  = "warn"
+	LogError = "error"
+)
+
+// the environment variables every setting can be read from
+const (
+	envConfigFile     = "WEIGHT_TRACKER_CONFIG"
+	envStorage        = "WEIGHT_TRACKER_STORAGE"
+	envDatabaseURL    = "WEIGHT_TRACKER_DATABASE_URL"
+	envListenAddress  = "WEIGHT_TRACKER_LISTEN_ADDRESS"
+	envShutdown       = "WEIGHT_TRACKER_SHUTDOWN_TIMEOUT"
+	envRequestTimeout = "WEIGHT_TRACKER_REQUEST_TIMEOUT"
+	envCORSOrigins    = "WEIGHT_TRACKER_CORS_ORIGINS"
+	envLogLevel       = "WEIGHT_TRACKER_LOG_LEVEL"
+	envMigrationsAuto = "WEIGHT_TRACKER_MIGRATIONS_AUTO"
+	envMigrationsDir  = "WEIGHT_TRACKER_MIGRATIONS_DIR"
+	envTokenSecret    = "WEIGHT_TRACKER_TOKEN_SECRET"
+	envTokenTTL       = "WEIGHT_TRACKER_TOKEN_TTL"
+	envPurgeInterval  = "WEIGHT_TRACKER_PURGE_INTERVAL"
+	envPurgeRetention = "WEIGHT_TRACKER_PURGE_RETENTION"
+)
+
+// Default is the configuration before anything is loaded. There is no default
+// database or token secret, those always have to be given.
+func Default() Config {
+	return Config{
+		Storage:         StorageDatabase,
+		ListenAddress:   ":8080",
+		ShutdownTimeout: 15 * time.Second,
+		RequestTimeout:  10 * time.Second,
+		CORSOrigins:     []string{"*"},
+		LogLevel:        LogInfo,
+		Migrations:      Migrations{Auto: true},
+		TokenTTL:        24 * time.Hour,
+		Purge:           Purge{Interval: time.Hour, Retention: 30 * 24 * time.Hour},
+	}
+}
+
+// Load reads the configuration from the command line arguments, without the
+// program name, the environment and the config file named by the -config flag
+// or the WEIGHT_TRACKER_CONFIG environment variable
+func Load(args []string, getenv func(string) string) (Config, error) {
+	config, _, err := load(args, getenv)
+
+	if err != nil {
+		return Config{}, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return config, nil
+}
+
+// LoadMigrate reads the configuration like Load for the migrate command, which
+// only needs the database settings. The arguments left after the flags are
+// returned as the migrate command to run.
+func LoadMigrate(args []string, getenv func(string) string) (Config, []string, error) {
+	return loadDatabaseCommand(args, getenv, "the memory storage has nothing to migrate")
+}
+
+// LoadPromote reads the configuration like LoadMigrate for the promote command.
+// The arguments left after the flags are the emails of the users to promote.
+func LoadPromote(args []string, getenv func(string) string) (Config, []string, error) {
+	return loadDatabaseCommand(args, getenv, "users in the memory storage only live as long as the server")
+}
+
+// loadDatabaseCommand reads the configuration of a command working on the
+// database, memoryProblem says why the command makes no sense for the memory storage
+func loadDatabaseCommand(args []string, getenv func(string) string, memoryProblem string) (Config, []string, error) {
+	config, command, err := load(args, getenv)
+
+	if err != nil {
+		return Config{}, nil, err
+	}
+
+	problems := config.databaseProblems()
+
+	if config.Storage == StorageMemory {
+		problems = append(problems, memoryProblem)
+	}
+
+	if len(problems) > 0 {
+		return Config{}, nil, errors.New("config - " + strings.Join(problems, "; "))
+	}
+
+	return config, command, nil
+}
+
+// load reads the configuration without validating it, and returns the
+// arguments left after the flags
+func load(args []string, getenv func(string) string) (Config, []string, error) {
+	config := Default()
+
+	flags := flag.NewFlagSet("weight-tracker", flag.ContinueOnError)
+	configFile := flags.String("config", "", "path to a YAML config file")
+	storage := flags.String("storage", "", "where data is kept, database or memory")
+	databaseURL := flags.String("database-url", "", "postgres connection string, or sqlite3:// followed by a file path")
+	listenAddress := flags.String("listen", "", "address the HTTP server listens on, e.g. :8080")
+	shutdownTimeout := flags.Duration("shutdown-timeout", 0, "how long requests in flight get to finish on shutdown")
+	requestTimeout := flags.Duration("request-timeout", 0, "how long a request may take before its queries are cancelled")
+	corsOrigins := flags.String("cors-origins", "", "comma separated origins allowed to call the API, * allows all")
+	logLevel := flags.String("log-level", "", "one of debug, info, warn or error")
+	migrationsAuto := flags.Bool("migrate", true, "run the database migrations on startup")
+	migrationsDir := flags.String("migrations-dir", "", "directory to read the migrations from instead of the ones built in")
+	tokenTTL := flags.Duration("token-ttl", 0, "how long issued tokens stay valid")
+	purgeInterval := flags.Duration("purge-interval", 0, "how often deleted users are purged, 0 turns purging off")
+	purgeRetention := flags.Duration("purge-retention", 0, "how long deleted users are kept before they are purged")
+
+	if err := flags.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	if *configFile == "" {
+		*configFile = getenv(envConfigFile)
+	}
+
+	if *configFile != "" {
+		if err := config.readFile(*configFile); err != nil {
+			return Config{}, nil, err
+		}
+	}
+
+	if err := config.readEnv(getenv); err != nil {
+		return Config{}, nil, err
+	}
+
+	// only the flags that were passed override the other sources
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "storage":
+			config.Storage = *storage
+		case "database-url":
+			config.DatabaseURL = *databaseURL
+		case "listen":
+			config.ListenAddress = *listenAddress
+		case "shutdown-timeout":
+			config.ShutdownTimeout = *shutdownTimeout
+		case "request-timeout":
+			config.RequestTimeout = *requestTimeout
+		case "cors-origins":
+			config.CORSOrigins = splitList(*corsOrigins)
+		case "log-level":
+			config.LogLevel = *logLevel
+		case "migrate":
+			config.Migrations.Auto = *migrationsAuto
+		case "migrations-dir":
+			config.Migrations.Dir = *migrationsDir
+		case "token-ttl":
+			config.TokenTTL = *tokenTTL
+		case "purge-interval":
+			config.Purge.Interval = *purgeInterval
+		case "purge-retention":
+			config.Purge.Retention = *purgeRetention
+		}
+	})
+
+	return config, flags.Args(), nil
+}
+
+func (c *Config) readFile(path string) error {
+	contents, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return fmt.Errorf("config - could not read config file: %w", err)
+	}
+
+	// keys missing from the file keep their defaults
+	if err = yaml.UnmarshalStrict(contents, c); err != nil {
+		return fmt.Errorf("config - invalid config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (c *Config) readEnv(getenv func(string) string) error {
+	if value := getenv(envStorage); value != "" {
+		c.Storage = value
+	}
+
+	if value := getenv(envDatabaseURL); value != "" {
+		c.DatabaseURL = value
+	}
+
+	if value := getenv(envListenAddress); value != "" {
+		c.ListenAddress = value
+	}
+
+	if value := getenv(envShutdown); value != "" {
+		timeout, err := time.ParseDuration(value)
+
+		if err != nil {
+			return fmt.Errorf("config - %s must be a duration like 15s", envShutdown)
+		}
+
+		c.ShutdownTimeout = timeout
+	}
+
+	if value := getenv(envRequestTimeout); value != "" {
+		timeout, err := time.ParseDuration(value)
+
+		if err != nil {
+			return fmt.Errorf("config - %s must be a duration like 10s", envRequestTimeout)
+		}
+
+		c.RequestTimeout = timeout
+	}
+
+	if value := getenv(envCORSOrigins); value != "" {
+		c.CORSOrigins = splitList(value)
+	}
+
+	if value := getenv(envLogLevel); value != "" {
+		c.LogLevel = value
+	}
+
+	if value := getenv(envMigrationsAuto); value != "" {
+		auto, err := strconv.ParseBool(value)
+
+		if err != nil {
+			return fmt.Errorf("config - %s must be true or false", envMigrationsAuto)
+		}
+
+		c.Migrations.Auto = auto
+	}
+
+	if value := getenv(envMigrationsDir); value != "" {
+		c.Migrations.Dir = value
+	}
+
+	// the token secret is only read from the file or the environment, so it
+	// does not end up in the process list
+	if value := getenv(envTokenSecret); value != "" {
+		c.TokenSecret = value
+	}
+
+	if value := getenv(envTokenTTL); value != "" {
+		ttl, err := time.ParseDuration(value)
+
+		if err != nil {
+			return fmt.Errorf("config - %s must be a duration like 24h", envTokenTTL)
+		}
+
+		c.TokenTTL = ttl
+	}
+
+	if value := getenv(envPurgeInterval); value != "" {
+		interval, err := time.ParseDuration(value)
+
+		if err != nil {
+			return fmt.Errorf("config - %s must be a duration like 1h", envPurgeInterval)
+		}
+
+		c.Purge.Interval = interval
+	}
+
+	if value := getenv(envPurgeRetention); value != "" {
+		retention, err := time.ParseDuration(value)
+
+		if err != nil {
+			return fmt.Errorf("config - %s must be a duration like 720h", envPurgeRetention)
+		}
+
+		c.Purge.Retention = retention
+	}
+
+	return nil
+}
+
+// Validate reports every problem with the configuration at once
+func (c Config) Validate() error {
+	problems := c.databaseProblems()
+
+	if _, _, err := net.SplitHostPort(c.ListenAddress); err != nil {
+		problems = append(problems, "listen address must be host:port or :port")
+	}
+
+	if c.ShutdownTimeout <= 0 {
+		problems = append(problems, "shutdown timeout must be positive")
+	}
+
+	if c.RequestTimeout <= 0 {
+		problems = append(problems, "request timeout must be positive")
+	}
+
+	if len(c.CORSOrigins) == 0 {
+		problems = append(problems, "at least one cors origin is required, use * to allow all")
+	}
+
+	switch c.LogLevel {
+	case LogDebug, LogInfo, LogWarn, LogError:
+	default:
+		problems = append(problems, "log level must be debug, info, warn or error")
+	}
+
+	if c.TokenSecret == "" {
+		problems = append(problems, "a token secret is required, set "+envTokenSecret)
+	}
+
+	if c.TokenTTL <= 0 {
+		problems = append(problems, "token ttl must be positive")
+	}
+
+	if c.Purge.Interval < 0 {
+		problems = append(problems, "purge interval cannot be negative, use 0 to turn purging off")
+	}
+
+	if c.Purge.Retention <= 0 {
+		problems = append(problems, "purge retention must be positive")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("config - " + strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// databaseProblems are the problems with the settings of the storage
+func (c Config) databaseProblems() []string {
+	var problems []string
+
+	switch c.Storage {
+	case StorageDatabase:
+		if c.DatabaseURL == "" {
+			problems = append(problems, "a database url is required, set "+envDatabaseURL+" or -database-url")
+		}
+	case StorageMemory:
+	default:
+		problems = append(problems, "storage must be database or memory")
+	}
+
+	return problems
+}
+
+// AllowsAllOrigins tells whether the API can be called from any origin
+func (c Config) AllowsAllOrigins() bool {
+	for _, origin := range c.CORSOrigins {
+		if origin == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func splitList(value string) []string {
+	var items []string
+
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
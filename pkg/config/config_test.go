@@ -0,0 +1,228 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+	"weight-tracker/pkg/config"
+)
+
+func TestLoad(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	contents := []byte(`
+database_url: postgres://file@localhost/weight_tracker
+listen_address: ":9000"
+cors_origins: ["https://file.example.com"]
+log_level: warn
+token_secret: file secret
+migrations:
+  auto: false
+purge:
+  retention: 168h
+`)
+
+	if err := os.WriteFile(configFile, contents, 0600); err != nil {
+		t.Fatalf("could not write config file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		args        []string
+		env         map[string]string
+		want_config config.Config
+		want_error  error
+	}{
+		{
+			name: "should fill in the defaults",
+			env: map[string]string{
+				"WEIGHT_TRACKER_DATABASE_URL": "postgres://env@localhost/weight_tracker",
+				"WEIGHT_TRACKER_TOKEN_SECRET": "env secret",
+			},
+			want_config: config.Config{
+				Storage:         config.StorageDatabase,
+				DatabaseURL:     "postgres://env@localhost/weight_tracker",
+				ListenAddress:   ":8080",
+				ShutdownTimeout: 15 * time.Second,
+				RequestTimeout:  10 * time.Second,
+				CORSOrigins:     []string{"*"},
+				LogLevel:        "info",
+				Migrations:      config.Migrations{Auto: true},
+				TokenSecret:     "env secret",
+				TokenTTL:        24 * time.Hour,
+				Purge:           config.Purge{Interval: time.Hour, Retention: 30 * 24 * time.Hour},
+			},
+		}, {
+			name: "should read the config file",
+			args: []string{"-config", configFile},
+			want_config: config.Config{
+				Storage:         config.StorageDatabase,
+				DatabaseURL:     "postgres://file@localhost/weight_tracker",
+				ListenAddress:   ":9000",
+				ShutdownTimeout: 15 * time.Second,
+				RequestTimeout:  10 * time.Second,
+				CORSOrigins:     []string{"https://file.example.com"},
+				LogLevel:        "warn",
+				Migrations:      config.Migrations{Auto: false},
+				TokenSecret:     "file secret",
+				TokenTTL:        24 * time.Hour,
+				Purge:           config.Purge{Interval: time.Hour, Retention: 7 * 24 * time.Hour},
+			},
+		}, {
+			name: "should prefer flags over the environment over the config file",
+			args: []string{"-listen", "127.0.0.1:7000", "-migrate", "-request-timeout", "3s", "-purge-retention", "48h"},
+			env: map[string]string{
+				"WEIGHT_TRACKER_CONFIG":         configFile,
+				"WEIGHT_TRACKER_LISTEN_ADDRESS": ":9500",
+				"WEIGHT_TRACKER_CORS_ORIGINS":   "https://a.example.com, https://b.example.com",
+				"WEIGHT_TRACKER_TOKEN_TTL":      "1h",
+				"WEIGHT_TRACKER_PURGE_INTERVAL": "0s",
+			},
+			want_config: config.Config{
+				Storage:         config.StorageDatabase,
+				DatabaseURL:     "postgres://file@localhost/weight_tracker",
+				ListenAddress:   "127.0.0.1:7000",
+				ShutdownTimeout: 15 * time.Second,
+				RequestTimeout:  3 * time.Second,
+				CORSOrigins:     []string{"https://a.example.com", "https://b.example.com"},
+				LogLevel:        "warn",
+				Migrations:      config.Migrations{Auto: true},
+				TokenSecret:     "file secret",
+				TokenTTL:        time.Hour,
+				Purge:           config.Purge{Interval: 0, Retention: 48 * time.Hour},
+			},
+		}, {
+			name: "should not need a database for the memory storage",
+			args: []string{"-storage", "memory"},
+			env:  map[string]string{"WEIGHT_TRACKER_TOKEN_SECRET": "env secret"},
+			want_config: config.Config{
+				Storage:         config.StorageMemory,
+				ListenAddress:   ":8080",
+				ShutdownTimeout: 15 * time.Second,
+				RequestTimeout:  10 * time.Second,
+				CORSOrigins:     []string{"*"},
+				LogLevel:        "info",
+				Migrations:      config.Migrations{Auto: true},
+				TokenSecret:     "env secret",
+				TokenTTL:        24 * time.Hour,
+				Purge:           config.Purge{Interval: time.Hour, Retention: 30 * 24 * time.Hour},
+			},
+		}, {
+			name:       "should report every missing and invalid setting",
+			args:       []string{"-log-level", "loud", "-listen", "8080", "-purge-interval", "-1h"},
+			want_error: errors.New("config - a database url is required, set WEIGHT_TRACKER_DATABASE_URL or -database-url; listen address must be host:port or :port; log level must be debug, info, warn or error; a token secret is required, set WEIGHT_TRACKER_TOKEN_SECRET; purge interval cannot be negative, use 0 to turn purging off"),
+		}, {
+			name:       "should return an error for an unknown storage",
+			args:       []string{"-storage", "sqlite"},
+			env:        map[string]string{"WEIGHT_TRACKER_TOKEN_SECRET": "env secret"},
+			want_error: errors.New("config - storage must be database or memory"),
+		}, {
+			name:       "should return an error for an invalid token ttl",
+			env:        map[string]string{"WEIGHT_TRACKER_TOKEN_TTL": "a day"},
+			want_error: errors.New("config - WEIGHT_TRACKER_TOKEN_TTL must be a duration like 24h"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			getenv := func(key string) string { return test.env[key] }
+
+			cfg, err := config.Load(test.args, getenv)
+
+			if !reflect.DeepEqual(err, test.want_error) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want_error)
+			}
+
+			if !reflect.DeepEqual(cfg, test.want_config) {
+				t.Errorf("test: %v failed. got: %+v, wanted: %+v", test.name, cfg, test.want_config)
+			}
+		})
+	}
+}
+
+func TestLoadMigrate(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		env          map[string]string
+		want_url     string
+		want_command []string
+		want_error   error
+	}{
+		{
+			name:         "should not need the server settings",
+			args:         []string{"-database-url", "sqlite3://weight-tracker.db", "goto", "3"},
+			want_url:     "sqlite3://weight-tracker.db",
+			want_command: []string{"goto", "3"},
+		}, {
+			name:         "should read the database url from the environment",
+			args:         []string{"up"},
+			env:          map[string]string{"WEIGHT_TRACKER_DATABASE_URL": "postgres://env@localhost/weight_tracker"},
+			want_url:     "postgres://env@localhost/weight_tracker",
+			want_command: []string{"up"},
+		}, {
+			name:       "should require a database url",
+			args:       []string{"up"},
+			want_error: errors.New("config - a database url is required, set WEIGHT_TRACKER_DATABASE_URL or -database-url"),
+		}, {
+			name:       "should return an error for the memory storage",
+			args:       []string{"-storage", "memory", "up"},
+			want_error: errors.New("config - the memory storage has nothing to migrate"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			getenv := func(key string) string { return test.env[key] }
+
+			cfg, command, err := config.LoadMigrate(test.args, getenv)
+
+			if !reflect.DeepEqual(err, test.want_error) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want_error)
+			}
+
+			if cfg.DatabaseURL != test.want_url || !reflect.DeepEqual(command, test.want_command) {
+				t.Errorf("test: %v failed. got: %v %v, wanted: %v %v", test.name, cfg.DatabaseURL, command, test.want_url, test.want_command)
+			}
+		})
+	}
+}
+
+func TestLoadPromote(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		want_url    string
+		want_emails []string
+		want_error  error
+	}{
+		{
+			name:        "should return the emails after the flags",
+			args:        []string{"-database-url", "sqlite3://weight-tracker.db", "admin@email.com"},
+			want_url:    "sqlite3://weight-tracker.db",
+			want_emails: []string{"admin@email.com"},
+		}, {
+			name:       "should return an error for the memory storage",
+			args:       []string{"-storage", "memory", "admin@email.com"},
+			want_error: errors.New("config - users in the memory storage only live as long as the server"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			getenv := func(key string) string { return "" }
+
+			cfg, emails, err := config.LoadPromote(test.args, getenv)
+
+			if !reflect.DeepEqual(err, test.want_error) {
+				t.Errorf("test: %v failed. got: %v, wanted: %v", test.name, err, test.want_error)
+			}
+
+			if cfg.DatabaseURL != test.want_url || !reflect.DeepEqual(emails, test.want_emails) {
+				t.Errorf("test: %v failed. got: %v %v, wanted: %v %v", test.name, cfg.DatabaseURL, emails, test.want_url, test.want_emails)
+			}
+		})
+	}
+}
@@ -0,0 +1,929 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"weight-tracker/pkg/api"
+)
+
+// memoryStorage keeps everything in maps, for tests and for trying the server
+// out without a database. It behaves like the postgres storage: IDs come from
+// sequences, emails are unique among users that are not deleted, rows cannot
+// reference users that do not exist and numbers are rounded like the columns.
+type memoryStorage struct {
+	// mu guards everything below, a transaction holds it until it ends
+	mu sync.Mutex
+
+	users   map[int]memoryUser
+	weights map[int]api.Weight
+	goals   map[int]api.Goal
+	clients map[coachClient]time.Time
+
+	measurements map[int]api.Measurement
+
+	// like postgres sequences, IDs are used up by inserts that fail and by
+	// transactions that are rolled back
+	lastUserID   int
+	lastWeightID int
+	lastGoalID   int
+
+	lastMeasurementID int
+}
+
+// memoryUser holds the columns of a user that are never returned with it
+type memoryUser struct {
+	api.User
+	passwordHash string
+	deletedAt    *time.Time
+}
+
+type coachClient struct {
+	coachID  int
+	clientID int
+}
+
+// errors postgres would fail with, these are unexpected for the services
+var (
+	errUserForeignKey = errors.New("storage - user referenced by the row does not exist")
+)
+
+// NewMemoryStorage returns an empty Storage that lives as long as the process
+func NewMemoryStorage() Storage {
+	return &memoryStorage{
+		users:   map[int]memoryUser{},
+		weights: map[int]api.Weight{},
+		goals:   map[int]api.Goal{},
+		clients: map[coachClient]time.Time{},
+
+		measurements: map[int]api.Measurement{},
+	}
+}
+
+// memoryTxKey is the context key of the storage a transaction runs in
+type memoryTxKey struct{}
+
+// lock locks the storage for a single call, unless the call is part of a
+// transaction, which holds the lock already. Use it as defer s.lock(ctx)().
+func (s *memoryStorage) lock(ctx context.Context) (unlock func()) {
+	if s.inTx(ctx) {
+		return func() {}
+	}
+
+	s.mu.Lock()
+
+	return s.mu.Unlock
+}
+
+func (s *memoryStorage) inTx(ctx context.Context) bool {
+	tx, ok := ctx.Value(memoryTxKey{}).(*memoryStorage)
+
+	return ok && tx == s
+}
+
+// WithTx runs fn while holding the storage, so transactions are serialized.
+// When fn fails every change it made is undone.
+func (s *memoryStorage) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.inTx(ctx) {
+		return fn(ctx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := s.snapshot()
+	committed := false
+
+	// changes are undone when fn panics too
+	defer func() {
+		if !committed {
+			s.restore(snapshot)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, memoryTxKey{}, s)); err != nil {
+		return err
+	}
+
+	committed = true
+
+	return nil
+}
+
+// memorySnapshot is the state of the storage at the start of a transaction,
+// without the sequences
+type memorySnapshot struct {
+	users   map[int]memoryUser
+	weights map[int]api.Weight
+	goals   map[int]api.Goal
+	clients map[coachClient]time.Time
+
+	measurements map[int]api.Measurement
+}
+
+// snapshot copies the maps. The values are not shared with callers, so copying
+// the maps themselves is enough.
+func (s *memoryStorage) snapshot() memorySnapshot {
+	snapshot := memorySnapshot{
+		users:   make(map[int]memoryUser, len(s.users)),
+		weights: make(map[int]api.Weight, len(s.weights)),
+		goals:   make(map[int]api.Goal, len(s.goals)),
+		clients: make(map[coachClient]time.Time, len(s.clients)),
+
+		measurements: make(map[int]api.Measurement, len(s.measurements)),
+	}
+
+	for id, user := range s.users {
+		snapshot.users[id] = user
+	}
+
+	for id, weight := range s.weights {
+		snapshot.weights[id] = weight
+	}
+
+	for id, goal := range s.goals {
+		snapshot.goals[id] = goal
+	}
+
+	for pair, createdAt := range s.clients {
+		snapshot.clients[pair] = createdAt
+	}
+
+	for id, measurement := range s.measurements {
+		snapshot.measurements[id] = measurement
+	}
+
+	return snapshot
+}
+
+func (s *memoryStorage) restore(snapshot memorySnapshot) {
+	s.users = snapshot.users
+	s.weights = snapshot.weights
+	s.goals = snapshot.goals
+	s.clients = snapshot.clients
+	s.measurements = snapshot.measurements
+}
+
+// RunMigrations has nothing to migrate
+func (s *memoryStorage) RunMigrations(migrationsDir, connectionString string) error {
+	return nil
+}
+
+// now is a timestamp as precise as the postgres columns
+func now() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+// roundColumn rounds like the numeric columns with two decimals
+func roundColumn(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+func roundColumnPtr(value *float64) *float64 {
+	if value == nil {
+		return nil
+	}
+
+	rounded := roundColumn(*value)
+
+	return &rounded
+}
+
+func copyTime(value *time.Time) *time.Time {
+	if value == nil {
+		return nil
+	}
+
+	copied := *value
+
+	return &copied
+}
+
+// active is the user when it exists and is not deleted
+func (s *memoryStorage) active(userID int) (memoryUser, bool) {
+	user, ok := s.users[userID]
+
+	return user, ok && user.deletedAt == nil
+}
+
+// emailTaken tells whether a user other than userID that is not deleted has the email
+func (s *memoryStorage) emailTaken(email string, userID int) bool {
+	for id, user := range s.users {
+		if id != userID && user.deletedAt == nil && user.Email == email {
+			return true
+		}
+	}
+
+	return false
+}
+
+// versionMismatch explains why a versioned change of a user did not apply
+func (s *memoryStorage) versionMismatch(userID int) error {
+	if _, ok := s.active(userID); !ok {
+		return errUserNotFound
+	}
+
+	return errUserVersionMismatch
+}
+
+func versionMatches(user memoryUser, version int) bool {
+	return version == api.AnyVersion || user.Version == version
+}
+
+// userResult copies a user so callers cannot change what is stored
+func userResult(user memoryUser) api.User {
+	result := user.User
+	result.WeeklyRate = roundColumnPtr(user.WeeklyRate)
+
+	return result
+}
+
+func (s *memoryStorage) CreateUser(ctx context.Context, request api.NewUserRequest) (userID int, err error) {
+	defer s.lock(ctx)()
+
+	s.lastUserID++
+
+	if s.emailTaken(request.Email, 0) {
+		return 0, errEmailTaken
+	}
+
+	createdAt := now()
+
+	s.users[s.lastUserID] = memoryUser{
+		User: api.User{
+			ID:            s.lastUserID,
+			CreatedAt:     createdAt,
+			UpdatedAt:     createdAt,
+			Name:          request.Name,
+			DateOfBirth:   api.DateOf(request.DateOfBirth.Time),
+			Height:        roundColumn(request.Height),
+			Sex:           request.Sex,
+			ActivityLevel: request.ActivityLevel,
+			WeightGoal:    request.WeightGoal,
+			Email:         request.Email,
+			UnitSystem:    request.UnitSystem,
+			BMRFormula:    request.BMRFormula,
+			WeeklyRate:    roundColumnPtr(request.WeeklyRate),
+			Role:          api.RoleMember,
+			Version:       1,
+		},
+		passwordHash: request.PasswordHash,
+	}
+
+	return s.lastUserID, nil
+}
+
+func (s *memoryStorage) DeleteUser(ctx context.Context, userID, version int) (deletedUserID int, err error) {
+	defer s.lock(ctx)()
+
+	user, ok := s.active(userID)
+
+	if !ok {
+		return 0, nil
+	} else if !versionMatches(user, version) {
+		return 0, errUserVersionMismatch
+	}
+
+	deletedAt := now()
+	user.deletedAt = &deletedAt
+	user.UpdatedAt = deletedAt
+	user.Version++
+	s.users[userID] = user
+
+	return userID, nil
+}
+
+func (s *memoryStorage) UpdateUser(ctx context.Context, request api.UpdateUserRequest) (api.User, error) {
+	defer s.lock(ctx)()
+
+	user, ok := s.active(request.ID)
+
+	if !ok || !versionMatches(user, request.Version) {
+		return api.User{}, s.versionMismatch(request.ID)
+	} else if s.emailTaken(request.Email, request.ID) {
+		return api.User{}, errEmailTaken
+	}
+
+	user.Name = request.Name
+	user.DateOfBirth = api.DateOf(request.DateOfBirth.Time)
+	user.Height = roundColumn(request.Height)
+	user.Sex = request.Sex
+	user.ActivityLevel = request.ActivityLevel
+	user.Email = request.Email
+	user.WeightGoal = request.WeightGoal
+	user.UnitSystem = request.UnitSystem
+	user.BMRFormula = request.BMRFormula
+	user.WeeklyRate = roundColumnPtr(request.WeeklyRate)
+	user.UpdatedAt = now()
+	user.Version++
+	s.users[request.ID] = user
+
+	return userResult(user), nil
+}
+
+func (s *memoryStorage) PatchUser(ctx context.Context, userID, version int, changes api.UserChanges) (api.User, error) {
+	defer s.lock(ctx)()
+
+	for column := range changes {
+		if !patchableUserColumns[column] {
+			return api.User{}, fmt.Errorf("storage - cannot patch column %q of a user", column)
+		}
+	}
+
+	user, ok := s.active(userID)
+
+	if !ok || !versionMatches(user, version) {
+		return api.User{}, s.versionMismatch(userID)
+	}
+
+	for column, value := range changes {
+		if err := setUserColumn(&user.User, column, value); err != nil {
+			return api.User{}, err
+		}
+	}
+
+	if s.emailTaken(user.Email, userID) {
+		return api.User{}, errEmailTaken
+	}
+
+	user.UpdatedAt = now()
+	user.Version++
+	s.users[userID] = user
+
+	return userResult(user), nil
+}
+
+// setUserColumn sets a column from a value of the type the user service uses for it
+func setUserColumn(user *api.User, column string, value interface{}) error {
+	ok := true
+
+	switch column {
+	case "name":
+		user.Name, ok = value.(string)
+	case "date_of_birth":
+		var dateOfBirth time.Time
+		dateOfBirth, ok = value.(time.Time)
+		user.DateOfBirth = api.DateOf(dateOfBirth)
+	case "height":
+		var height float64
+		height, ok = value.(float64)
+		user.Height = roundColumn(height)
+	case "sex":
+		user.Sex, ok = value.(string)
+	case "activity_level":
+		user.ActivityLevel, ok = value.(int)
+	case "email":
+		user.Email, ok = value.(string)
+	case "weight_goal":
+		user.WeightGoal, ok = value.(string)
+	case "unit_system":
+		user.UnitSystem, ok = value.(string)
+	case "bmr_formula":
+		user.BMRFormula, ok = value.(string)
+	case "weekly_rate":
+		var rate *float64
+		rate, ok = value.(*float64)
+		user.WeeklyRate = roundColumnPtr(rate)
+	}
+
+	if !ok {
+		return fmt.Errorf("storage - invalid value %v for column %q of a user", value, column)
+	}
+
+	return nil
+}
+
+func (s *memoryStorage) RestoreUser(ctx context.Context, userID int) (api.User, error) {
+	defer s.lock(ctx)()
+
+	user, ok := s.users[userID]
+
+	if !ok || user.deletedAt == nil {
+		return api.User{}, errDeletedUserNotFound
+	} else if s.emailTaken(user.Email, userID) {
+		return api.User{}, errEmailTaken
+	}
+
+	user.deletedAt = nil
+	user.UpdatedAt = now()
+	user.Version++
+	s.users[userID] = user
+
+	return userResult(user), nil
+}
+
+func (s *memoryStorage) PurgeUsers(ctx context.Context, deletedBefore time.Time) (purged int, err error) {
+	defer s.lock(ctx)()
+
+	for userID, user := range s.users {
+		if user.deletedAt == nil || !user.deletedAt.Before(deletedBefore) {
+			continue
+		}
+
+		for weightID, weight := range s.weights {
+			if weight.UserID == userID {
+				delete(s.weights, weightID)
+			}
+		}
+
+		for goalID, goal := range s.goals {
+			if goal.UserID == userID {
+				delete(s.goals, goalID)
+			}
+		}
+
+		for measurementID, measurement := range s.measurements {
+			if measurement.UserID == userID {
+				delete(s.measurements, measurementID)
+			}
+		}
+
+		for pair := range s.clients {
+			if pair.coachID == userID || pair.clientID == userID {
+				delete(s.clients, pair)
+			}
+		}
+
+		delete(s.users, userID)
+		purged++
+	}
+
+	return purged, nil
+}
+
+func (s *memoryStorage) GetUser(ctx context.Context, userID int) (api.User, error) {
+	defer s.lock(ctx)()
+
+	user, ok := s.active(userID)
+
+	if !ok {
+		return api.User{}, errUserNotFound
+	}
+
+	return userResult(user), nil
+}
+
+// GetUsers returns the users that are not deleted, ordered by id
+func (s *memoryStorage) GetUsers(ctx context.Context) ([]api.User, error) {
+	defer s.lock(ctx)()
+
+	var users []api.User
+
+	for _, user := range s.users {
+		if user.deletedAt == nil {
+			users = append(users, userResult(user))
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+
+	return users, nil
+}
+
+// GetUserByEmail returns an empty user when no user has the email
+func (s *memoryStorage) GetUserByEmail(ctx context.Context, userEmail string) (api.User, error) {
+	defer s.lock(ctx)()
+
+	for _, user := range s.users {
+		if user.deletedAt == nil && user.Email == userEmail {
+			return userResult(user), nil
+		}
+	}
+
+	return api.User{}, nil
+}
+
+func (s *memoryStorage) GetPasswordHash(ctx context.Context, userID int) (string, error) {
+	defer s.lock(ctx)()
+
+	user, ok := s.active(userID)
+
+	if !ok {
+		return "", errUserNotFound
+	}
+
+	return user.passwordHash, nil
+}
+
+func (s *memoryStorage) UpdateRole(ctx context.Context, userID int, role string) (api.User, error) {
+	defer s.lock(ctx)()
+
+	user, ok := s.active(userID)
+
+	if !ok {
+		return api.User{}, errUserNotFound
+	}
+
+	user.Role = role
+	user.UpdatedAt = now()
+	user.Version++
+	s.users[userID] = user
+
+	return userResult(user), nil
+}
+
+func (s *memoryStorage) IsCoachOf(ctx context.Context, coachID, clientID int) (bool, error) {
+	defer s.lock(ctx)()
+
+	_, ok := s.clients[coachClient{coachID: coachID, clientID: clientID}]
+
+	return ok, nil
+}
+
+func (s *memoryStorage) AddClient(ctx context.Context, coachID, clientID int) error {
+	defer s.lock(ctx)()
+
+	pair := coachClient{coachID: coachID, clientID: clientID}
+
+	if _, ok := s.clients[pair]; ok {
+		return api.ConflictError("storage - user is already a client of this coach")
+	}
+
+	// like the foreign keys, deleted users still count as existing
+	if _, ok := s.users[coachID]; !ok {
+		return errUserForeignKey
+	} else if _, ok := s.users[clientID]; !ok {
+		return errUserForeignKey
+	}
+
+	s.clients[pair] = now()
+
+	return nil
+}
+
+func (s *memoryStorage) RemoveClient(ctx context.Context, coachID, clientID int) (bool, error) {
+	defer s.lock(ctx)()
+
+	pair := coachClient{coachID: coachID, clientID: clientID}
+
+	if _, ok := s.clients[pair]; !ok {
+		return false, nil
+	}
+
+	delete(s.clients, pair)
+
+	return true, nil
+}
+
+// GetClients returns the clients of the coach that are not deleted, ordered by id
+func (s *memoryStorage) GetClients(ctx context.Context, coachID int) ([]api.User, error) {
+	defer s.lock(ctx)()
+
+	var clients []api.User
+
+	for pair := range s.clients {
+		if pair.coachID != coachID {
+			continue
+		}
+
+		if client, ok := s.active(pair.clientID); ok {
+			clients = append(clients, userResult(client))
+		}
+	}
+
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ID < clients[j].ID })
+
+	return clients, nil
+}
+
+// weightResult copies a weight entry so callers cannot change what is stored
+func weightResult(weight api.Weight) api.Weight {
+	weight.UpdatedAt = copyTime(weight.UpdatedAt)
+	weight.BodyFatPercentage = roundColumnPtr(weight.BodyFatPercentage)
+	weight.Unit = ""
+	weight.Warnings = nil
+	weight.Metrics = nil
+
+	return weight
+}
+
+func (s *memoryStorage) CreateWeightEntry(ctx context.Context, request api.Weight) (api.Weight, error) {
+	defer s.lock(ctx)()
+
+	s.lastWeightID++
+
+	if _, ok := s.users[request.UserID]; !ok {
+		return api.Weight{}, errUserForeignKey
+	}
+
+	weight := api.Weight{
+		ID:                 s.lastWeightID,
+		CreatedAt:          now(),
+		MeasuredAt:         request.MeasuredAt.Truncate(time.Microsecond),
+		Weight:             roundColumn(request.Weight),
+		UserID:             request.UserID,
+		BMR:                request.BMR,
+		BMRFormula:         request.BMRFormula,
+		DailyCaloricIntake: request.DailyCaloricIntake,
+		BodyFatPercentage:  roundColumnPtr(request.BodyFatPercentage),
+	}
+	s.weights[weight.ID] = weight
+
+	return weightResult(weight), nil
+}
+
+func (s *memoryStorage) GetWeight(ctx context.Context, weightID int) (api.Weight, error) {
+	defer s.lock(ctx)()
+
+	weight, ok := s.weights[weightID]
+
+	if !ok {
+		return api.Weight{}, errWeightNotFound
+	}
+
+	return weightResult(weight), nil
+}
+
+func (s *memoryStorage) UpdateWeightEntry(ctx context.Context, request api.Weight) (api.Weight, error) {
+	defer s.lock(ctx)()
+
+	weight, ok := s.weights[request.ID]
+
+	if !ok {
+		return api.Weight{}, errWeightNotFound
+	}
+
+	updatedAt := now()
+
+	weight.Weight = roundColumn(request.Weight)
+	weight.BMR = request.BMR
+	weight.DailyCaloricIntake = request.DailyCaloricIntake
+	weight.MeasuredAt = request.MeasuredAt.Truncate(time.Microsecond)
+	weight.BodyFatPercentage = roundColumnPtr(request.BodyFatPercentage)
+	weight.BMRFormula = request.BMRFormula
+	weight.UpdatedAt = &updatedAt
+	s.weights[request.ID] = weight
+
+	return weightResult(weight), nil
+}
+
+// DeleteWeightEntry returns a deletedWeightID of 0 when there was no entry with the given id
+func (s *memoryStorage) DeleteWeightEntry(ctx context.Context, weightID int) (int, error) {
+	defer s.lock(ctx)()
+
+	if _, ok := s.weights[weightID]; !ok {
+		return 0, nil
+	}
+
+	delete(s.weights, weightID)
+
+	return weightID, nil
+}
+
+// GetWeights returns the weight entries of a user matching the filter, ordered
+// by measured_at with the id breaking ties
+func (s *memoryStorage) GetWeights(ctx context.Context, filter api.WeightFilter) ([]api.Weight, error) {
+	defer s.lock(ctx)()
+
+	// before tells whether a comes first in ascending order
+	before := func(a api.Weight, measuredAt time.Time, id int) bool {
+		if a.MeasuredAt.Equal(measuredAt) {
+			return a.ID < id
+		}
+
+		return a.MeasuredAt.Before(measuredAt)
+	}
+
+	var weights []api.Weight
+
+	for _, weight := range s.weights {
+		if weight.UserID != filter.UserID {
+			continue
+		}
+
+		if filter.From != nil && weight.MeasuredAt.Before(*filter.From) {
+			continue
+		}
+
+		if filter.To != nil && weight.MeasuredAt.After(*filter.To) {
+			continue
+		}
+
+		if after := filter.After; after != nil {
+			isBefore := before(weight, after.MeasuredAt, after.ID)
+			isCursor := weight.MeasuredAt.Equal(after.MeasuredAt) && weight.ID == after.ID
+
+			// only entries strictly past the cursor, in the direction of the order
+			if isCursor || (filter.Descending != isBefore) {
+				continue
+			}
+		}
+
+		weights = append(weights, weightResult(weight))
+	}
+
+	sort.Slice(weights, func(i, j int) bool {
+		if filter.Descending {
+			return before(weights[j], weights[i].MeasuredAt, weights[i].ID)
+		}
+
+		return before(weights[i], weights[j].MeasuredAt, weights[j].ID)
+	})
+
+	if filter.Limit > 0 && len(weights) > filter.Limit {
+		weights = weights[:filter.Limit]
+	}
+
+	return weights, nil
+}
+
+// goalResult copies a goal so callers cannot change what is stored
+func goalResult(goal api.Goal) api.Goal {
+	goal.TargetDate = copyTime(goal.TargetDate)
+	goal.Unit = ""
+
+	return goal
+}
+
+func (s *memoryStorage) CreateGoal(ctx context.Context, goal api.Goal) (api.Goal, error) {
+	defer s.lock(ctx)()
+
+	s.lastGoalID++
+
+	for _, existing := range s.goals {
+		if existing.UserID == goal.UserID {
+			return api.Goal{}, api.ConflictError("storage - user already has a goal")
+		}
+	}
+
+	if _, ok := s.users[goal.UserID]; !ok {
+		return api.Goal{}, errUserForeignKey
+	}
+
+	createdAt := now()
+
+	created := api.Goal{
+		ID:           s.lastGoalID,
+		UserID:       goal.UserID,
+		CreatedAt:    createdAt,
+		UpdatedAt:    createdAt,
+		StartWeight:  roundColumn(goal.StartWeight),
+		TargetWeight: roundColumn(goal.TargetWeight),
+		TargetDate:   copyTime(goal.TargetDate),
+	}
+	s.goals[created.ID] = created
+
+	return goalResult(created), nil
+}
+
+// GetGoal returns an empty goal when the user has none
+func (s *memoryStorage) GetGoal(ctx context.Context, userID int) (api.Goal, error) {
+	defer s.lock(ctx)()
+
+	for _, goal := range s.goals {
+		if goal.UserID == userID {
+			return goalResult(goal), nil
+		}
+	}
+
+	return api.Goal{}, nil
+}
+
+func (s *memoryStorage) UpdateGoal(ctx context.Context, goal api.Goal) (api.Goal, error) {
+	defer s.lock(ctx)()
+
+	updated, ok := s.goals[goal.ID]
+
+	if !ok {
+		return api.Goal{}, api.NotFoundError("storage - goal does not exist")
+	}
+
+	updated.StartWeight = roundColumn(goal.StartWeight)
+	updated.TargetWeight = roundColumn(goal.TargetWeight)
+	updated.TargetDate = copyTime(goal.TargetDate)
+	updated.UpdatedAt = now()
+	s.goals[goal.ID] = updated
+
+	return goalResult(updated), nil
+}
+
+// measurementResult copies a measurement so callers cannot change what is stored
+func measurementResult(m api.Measurement) api.Measurement {
+	m.UpdatedAt = copyTime(m.UpdatedAt)
+	m.BodyFatPercentage = roundColumnPtr(m.BodyFatPercentage)
+	m.MuscleMass = roundColumnPtr(m.MuscleMass)
+	m.WaterPercentage = roundColumnPtr(m.WaterPercentage)
+	m.Waist = roundColumnPtr(m.Waist)
+	m.Hip = roundColumnPtr(m.Hip)
+	m.Neck = roundColumnPtr(m.Neck)
+	m.Chest = roundColumnPtr(m.Chest)
+	m.LengthUnit = ""
+	m.WeightUnit = ""
+	m.Derived = api.BodyComposition{}
+
+	return m
+}
+
+func (s *memoryStorage) CreateMeasurement(ctx context.Context, m api.Measurement) (api.Measurement, error) {
+	defer s.lock(ctx)()
+
+	s.lastMeasurementID++
+
+	if _, ok := s.users[m.UserID]; !ok {
+		return api.Measurement{}, errUserForeignKey
+	}
+
+	created := measurementResult(m)
+	created.ID = s.lastMeasurementID
+	created.CreatedAt = now()
+	created.UpdatedAt = nil
+	created.MeasuredAt = m.MeasuredAt.Truncate(time.Microsecond)
+	s.measurements[created.ID] = created
+
+	return measurementResult(created), nil
+}
+
+func (s *memoryStorage) GetMeasurement(ctx context.Context, measurementID int) (api.Measurement, error) {
+	defer s.lock(ctx)()
+
+	measurement, ok := s.measurements[measurementID]
+
+	if !ok {
+		return api.Measurement{}, errMeasurementNotFound
+	}
+
+	return measurementResult(measurement), nil
+}
+
+func (s *memoryStorage) UpdateMeasurement(ctx context.Context, m api.Measurement) (api.Measurement, error) {
+	defer s.lock(ctx)()
+
+	stored, ok := s.measurements[m.ID]
+
+	if !ok {
+		return api.Measurement{}, errMeasurementNotFound
+	}
+
+	updatedAt := now()
+
+	updated := measurementResult(m)
+	updated.UserID = stored.UserID
+	updated.CreatedAt = stored.CreatedAt
+	updated.UpdatedAt = &updatedAt
+	updated.MeasuredAt = m.MeasuredAt.Truncate(time.Microsecond)
+	s.measurements[m.ID] = updated
+
+	return measurementResult(updated), nil
+}
+
+// DeleteMeasurement returns a deletedMeasurementID of 0 when there was no measurement with the given id
+func (s *memoryStorage) DeleteMeasurement(ctx context.Context, measurementID int) (int, error) {
+	defer s.lock(ctx)()
+
+	if _, ok := s.measurements[measurementID]; !ok {
+		return 0, nil
+	}
+
+	delete(s.measurements, measurementID)
+
+	return measurementID, nil
+}
+
+// GetMeasurements returns the measurements of a user matching the filter, ordered
+// by measured_at with the id breaking ties
+func (s *memoryStorage) GetMeasurements(ctx context.Context, filter api.MeasurementFilter) ([]api.Measurement, error) {
+	defer s.lock(ctx)()
+
+	var measurements []api.Measurement
+
+	for _, measurement := range s.measurements {
+		if measurement.UserID != filter.UserID {
+			continue
+		}
+
+		if filter.From != nil && measurement.MeasuredAt.Before(*filter.From) {
+			continue
+		}
+
+		if filter.To != nil && measurement.MeasuredAt.After(*filter.To) {
+			continue
+		}
+
+		measurements = append(measurements, measurementResult(measurement))
+	}
+
+	sort.Slice(measurements, func(i, j int) bool {
+		a, b := measurements[i], measurements[j]
+
+		if filter.Descending {
+			a, b = b, a
+		}
+
+		if a.MeasuredAt.Equal(b.MeasuredAt) {
+			return a.ID < b.ID
+		}
+
+		return a.MeasuredAt.Before(b.MeasuredAt)
+	})
+
+	if filter.Limit > 0 && len(measurements) > filter.Limit {
+		measurements = measurements[:filter.Limit]
+	}
+
+	return measurements, nil
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// migrationFiles are built into the binary, so it can migrate wherever it is deployed
+//
+//go:embed migrations/*.sql migrations/sqlite/*.sql
+var migrationFiles embed.FS
+
+// Migrator moves the schema of a database between the versions of its migrations
+type Migrator struct {
+	migrate *migrate.Migrate
+}
+
+// NewMigrator returns a Migrator for the database at databaseURL. It uses the
+// migrations in migrationsDir, or the ones built into the binary when it is
+// empty, which come in a postgres and a SQLite set.
+func NewMigrator(migrationsDir, databaseURL string) (*Migrator, error) {
+	if databaseURL == "" {
+		return nil, errors.New("migrate - the database url is empty")
+	}
+
+	var m *migrate.Migrate
+	var err error
+
+	if migrationsDir != "" {
+		m, err = migrate.New("file://"+migrationsDir, databaseURL)
+	} else {
+		embedded := "migrations"
+
+		if strings.HasPrefix(databaseURL, SQLiteScheme) {
+			embedded = "migrations/sqlite"
+		}
+
+		source, sourceErr := iofs.New(migrationFiles, embedded)
+
+		if sourceErr != nil {
+			return nil, sourceErr
+		}
+
+		m, err = migrate.NewWithSourceInstance("iofs", source, databaseURL)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("migrate - could not open the migrations: %w", err)
+	}
+
+	return &Migrator{migrate: m}, nil
+}
+
+// Up applies every migration that has not been applied yet
+func (m *Migrator) Up() error {
+	return migrationError(m.migrate.Up())
+}
+
+// Down undoes the last migration that was applied
+func (m *Migrator) Down() error {
+	err := m.migrate.Steps(-1)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return errors.New("migrate - there is no migration to undo")
+	}
+
+	return migrationError(err)
+}
+
+// Goto migrates up or down to the given version
+func (m *Migrator) Goto(version uint) error {
+	err := m.migrate.Migrate(version)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("migrate - there is no migration %d", version)
+	}
+
+	return migrationError(err)
+}
+
+// Force sets the version without running any migration, which is how a dirty
+// database is marked as fixed. A version of -1 means no migration is applied.
+func (m *Migrator) Force(version int) error {
+	return migrationError(m.migrate.Force(version))
+}
+
+// Version returns the version of the database and whether the migration to it
+// failed halfway. It is 0 when no migration has been applied.
+func (m *Migrator) Version() (version uint, dirty bool, err error) {
+	version, dirty, err = m.migrate.Version()
+
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+
+	return version, dirty, err
+}
+
+// Close closes the connections to the migrations and the database
+func (m *Migrator) Close() error {
+	sourceErr, databaseErr := m.migrate.Close()
+
+	if sourceErr != nil {
+		return sourceErr
+	}
+
+	return databaseErr
+}
+
+// migrationError treats a migration with nothing to do as a success and
+// explains what to do about a dirty database
+func migrationError(err error) error {
+	var dirty migrate.ErrDirty
+
+	switch {
+	case err == nil, errors.Is(err, migrate.ErrNoChange):
+		return nil
+	case errors.As(err, &dirty):
+		return fmt.Errorf("migrate - migration %d failed halfway and left the database dirty, "+
+			"fix the schema by hand and mark the version it matches with migrate force", dirty.Version)
+	default:
+		return fmt.Errorf("migrate - %w", err)
+	}
+}
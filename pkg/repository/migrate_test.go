@@ -0,0 +1,88 @@
+package repository_test
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"weight-tracker/pkg/repository"
+)
+
+func TestMigrator(t *testing.T) {
+	databaseURL := repository.SQLiteScheme + filepath.Join(t.TempDir(), "weight-tracker.db")
+	migrator, err := repository.NewMigrator("", databaseURL)
+
+	if err != nil {
+		t.Fatalf("could not create the migrator: %v", err)
+	}
+
+	defer migrator.Close()
+
+	wantVersion := func(step string, want uint, wantDirty bool) {
+		t.Helper()
+
+		version, dirty, err := migrator.Version()
+
+		if err != nil || version != want || dirty != wantDirty {
+			t.Errorf("%s: got: %v, %v, %v, wanted: %v, %v, nil", step, version, dirty, err, want, wantDirty)
+		}
+	}
+
+	wantVersion("before migrating", 0, false)
+
+	if err = migrator.Up(); err != nil {
+		t.Fatalf("could not migrate up: %v", err)
+	}
+
+	// migrating an up to date database is not an error
+	if err = migrator.Up(); err != nil {
+		t.Errorf("migrating up twice: got: %v, wanted: nil", err)
+	}
+
+	wantVersion("after migrating up", 3, false)
+
+	if err = migrator.Down(); err != nil {
+		t.Errorf("could not migrate down: %v", err)
+	}
+
+	wantVersion("after migrating down", 2, false)
+
+	for i := 0; i < 2; i++ {
+		if err = migrator.Down(); err != nil {
+			t.Errorf("could not migrate down: %v", err)
+		}
+	}
+
+	if err = migrator.Down(); err == nil {
+		t.Errorf("migrating down without migrations should fail")
+	}
+
+	if err = migrator.Goto(1); err != nil {
+		t.Errorf("could not go to version 1: %v", err)
+	}
+
+	// a migration failing halfway leaves the database dirty until it is forced
+	db, err := sql.Open("sqlite3", repository.SQLiteDataSource(databaseURL))
+
+	if err != nil {
+		t.Fatalf("could not open the database: %v", err)
+	}
+
+	defer db.Close()
+
+	if _, err = db.Exec(`UPDATE schema_migrations SET dirty = 1;`); err != nil {
+		t.Fatalf("could not make the database dirty: %v", err)
+	}
+
+	wantVersion("after failing", 1, true)
+
+	if err = migrator.Up(); err == nil || errors.Unwrap(err) != nil {
+		t.Errorf("migrating a dirty database: got: %v, wanted an error explaining what to do", err)
+	}
+
+	if err = migrator.Force(1); err != nil {
+		t.Errorf("could not force version 1: %v", err)
+	}
+
+	wantVersion("after forcing", 1, false)
+}
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS weight_user_id_measured_at_idx;
+
+ALTER TABLE weight DROP COLUMN IF EXISTS measured_at;
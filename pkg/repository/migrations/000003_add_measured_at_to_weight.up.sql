@@ -0,0 +1,8 @@
+ALTER TABLE weight ADD COLUMN IF NOT EXISTS measured_at timestamp with time zone;
+
+UPDATE weight SET measured_at = created_at WHERE measured_at IS NULL;
+
+ALTER TABLE weight ALTER COLUMN measured_at SET DEFAULT now();
+ALTER TABLE weight ALTER COLUMN measured_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS weight_user_id_measured_at_idx ON weight (user_id, measured_at, id);
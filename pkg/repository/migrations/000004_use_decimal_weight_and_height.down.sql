@@ -0,0 +1,4 @@
+ALTER TABLE "user" DROP COLUMN IF EXISTS unit_system;
+ALTER TABLE "user" ALTER COLUMN height TYPE integer USING round(height);
+
+ALTER TABLE weight ALTER COLUMN weight TYPE integer USING round(weight);
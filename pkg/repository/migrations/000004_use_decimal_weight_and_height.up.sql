@@ -0,0 +1,4 @@
+ALTER TABLE weight ALTER COLUMN weight TYPE numeric(6, 2);
+
+ALTER TABLE "user" ALTER COLUMN height TYPE numeric(5, 2);
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS unit_system varchar(255) not null default 'metric';
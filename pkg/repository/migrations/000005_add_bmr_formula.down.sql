@@ -0,0 +1,4 @@
+ALTER TABLE weight DROP COLUMN IF EXISTS bmr_formula;
+ALTER TABLE weight DROP COLUMN IF EXISTS body_fat_percentage;
+
+ALTER TABLE "user" DROP COLUMN IF EXISTS bmr_formula;
@@ -0,0 +1,4 @@
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS bmr_formula varchar(255) not null default 'mifflin_st_jeor';
+
+ALTER TABLE weight ADD COLUMN IF NOT EXISTS body_fat_percentage numeric(4, 2);
+ALTER TABLE weight ADD COLUMN IF NOT EXISTS bmr_formula varchar(255) not null default 'mifflin_st_jeor';
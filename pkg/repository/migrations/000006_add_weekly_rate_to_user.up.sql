@@ -0,0 +1 @@
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS weekly_rate numeric(4, 2);
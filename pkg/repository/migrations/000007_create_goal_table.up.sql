@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS goal(
+    id serial PRIMARY KEY,
+    created_at      timestamp with time zone default now() not null,
+    updated_at      timestamp with time zone default now() not null,
+    user_id integer unique not null,
+    start_weight numeric(6, 2) not null,
+    target_weight numeric(6, 2) not null,
+    target_date timestamp with time zone,
+    FOREIGN KEY (user_id) REFERENCES "user" (id)
+);
@@ -0,0 +1 @@
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS password_hash varchar(255);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS coach_client;
+
+ALTER TABLE "user" DROP COLUMN IF EXISTS role;
@@ -0,0 +1,10 @@
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS role varchar(255) not null default 'member';
+
+CREATE TABLE IF NOT EXISTS coach_client(
+    created_at      timestamp with time zone default now() not null,
+    coach_id integer not null,
+    client_id integer not null,
+    PRIMARY KEY (coach_id, client_id),
+    FOREIGN KEY (coach_id) REFERENCES "user" (id),
+    FOREIGN KEY (client_id) REFERENCES "user" (id)
+);
@@ -0,0 +1 @@
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS version integer not null default 1;
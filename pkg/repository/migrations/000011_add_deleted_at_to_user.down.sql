@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS user_email_active_key;
+ALTER TABLE "user" ADD CONSTRAINT user_email_key UNIQUE (email);
+
+ALTER TABLE "user" DROP COLUMN IF EXISTS deleted_at;
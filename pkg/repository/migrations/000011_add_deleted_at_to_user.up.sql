@@ -0,0 +1,5 @@
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS deleted_at timestamp with time zone;
+
+-- deleted users give up their email, so it can be used to sign up again
+ALTER TABLE "user" DROP CONSTRAINT IF EXISTS user_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS user_email_active_key ON "user" (email) WHERE deleted_at IS NULL;
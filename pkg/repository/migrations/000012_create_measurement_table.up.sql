@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS measurement(
+    id serial PRIMARY KEY,
+    created_at      timestamp with time zone default now() not null,
+    updated_at      timestamp with time zone,
+    measured_at     timestamp with time zone default now() not null,
+    user_id integer not null,
+    body_fat_percentage numeric(4, 2),
+    muscle_mass numeric(5, 2),
+    water_percentage numeric(4, 2),
+    waist numeric(5, 2),
+    hip numeric(5, 2),
+    neck numeric(5, 2),
+    chest numeric(5, 2),
+    FOREIGN KEY (user_id) REFERENCES "user" (id)
+);
+
+CREATE INDEX IF NOT EXISTS measurement_user_id_measured_at_idx ON measurement (user_id, measured_at, id);
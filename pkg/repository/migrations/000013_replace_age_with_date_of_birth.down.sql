@@ -0,0 +1,6 @@
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS age integer;
+
+UPDATE "user" SET age = date_part('year', age(date_of_birth)) WHERE age IS NULL;
+
+ALTER TABLE "user" ALTER COLUMN age SET NOT NULL;
+ALTER TABLE "user" DROP COLUMN IF EXISTS date_of_birth;
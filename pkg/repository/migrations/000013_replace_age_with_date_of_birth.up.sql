@@ -0,0 +1,9 @@
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS date_of_birth date;
+
+-- the age was most likely entered when the user signed up. The birthday could be
+-- any day of the year, the middle of it is the best guess.
+UPDATE "user" SET date_of_birth = (created_at - make_interval(years => age, months => 6))::date
+WHERE date_of_birth IS NULL;
+
+ALTER TABLE "user" ALTER COLUMN date_of_birth SET NOT NULL;
+ALTER TABLE "user" DROP COLUMN IF EXISTS age;
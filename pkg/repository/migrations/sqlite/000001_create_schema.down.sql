@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS coach_client;
+DROP TABLE IF EXISTS goal;
+DROP TABLE IF EXISTS weight;
+DROP TABLE IF EXISTS "user";
@@ -0,0 +1,60 @@
+-- the schema the postgres migrations build up to, in SQLite. Timestamps are
+-- kept as text in UTC, so they sort and compare like the postgres columns.
+CREATE TABLE IF NOT EXISTS "user"(
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at      timestamp default (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')) not null,
+    updated_at      timestamp default (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')) not null,
+    deleted_at      timestamp,
+    name varchar(255) not null,
+    age integer not null,
+    height numeric(5, 2) not null,
+    sex varchar(255) not null,
+    activity_level integer not null,
+    weight_goal varchar not null default 'maintain',
+    email varchar not null,
+    unit_system varchar(255) not null default 'metric',
+    bmr_formula varchar(255) not null default 'mifflin_st_jeor',
+    weekly_rate numeric(4, 2),
+    password_hash varchar(255),
+    role varchar(255) not null default 'member',
+    version integer not null default 1
+);
+
+-- deleted users give up their email, so it can be used to sign up again
+CREATE UNIQUE INDEX IF NOT EXISTS user_email_active_key ON "user" (email) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS weight(
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at      timestamp default (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')) not null,
+    updated_at      timestamp,
+    measured_at     timestamp default (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')) not null,
+    weight numeric(6, 2) not null,
+    bmr integer not null,
+    daily_caloric_intake integer,
+    body_fat_percentage numeric(4, 2),
+    bmr_formula varchar(255) not null default 'mifflin_st_jeor',
+    user_id integer not null,
+    FOREIGN KEY (user_id) REFERENCES "user" (id)
+);
+
+CREATE INDEX IF NOT EXISTS weight_user_id_measured_at_idx ON weight (user_id, measured_at, id);
+
+CREATE TABLE IF NOT EXISTS goal(
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at      timestamp default (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')) not null,
+    updated_at      timestamp default (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')) not null,
+    user_id integer unique not null,
+    start_weight numeric(6, 2) not null,
+    target_weight numeric(6, 2) not null,
+    target_date timestamp,
+    FOREIGN KEY (user_id) REFERENCES "user" (id)
+);
+
+CREATE TABLE IF NOT EXISTS coach_client(
+    created_at      timestamp default (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')) not null,
+    coach_id integer not null,
+    client_id integer not null,
+    PRIMARY KEY (coach_id, client_id),
+    FOREIGN KEY (coach_id) REFERENCES "user" (id),
+    FOREIGN KEY (client_id) REFERENCES "user" (id)
+);
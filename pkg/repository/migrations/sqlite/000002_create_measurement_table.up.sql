@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS measurement(
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at      timestamp default (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')) not null,
+    updated_at      timestamp,
+    measured_at     timestamp default (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')) not null,
+    user_id integer not null,
+    body_fat_percentage numeric(4, 2),
+    muscle_mass numeric(5, 2),
+    water_percentage numeric(4, 2),
+    waist numeric(5, 2),
+    hip numeric(5, 2),
+    neck numeric(5, 2),
+    chest numeric(5, 2),
+    FOREIGN KEY (user_id) REFERENCES "user" (id)
+);
+
+CREATE INDEX IF NOT EXISTS measurement_user_id_measured_at_idx ON measurement (user_id, measured_at, id);
@@ -0,0 +1,6 @@
+ALTER TABLE "user" ADD COLUMN age integer not null default 0;
+
+UPDATE "user" SET age = CAST(strftime('%Y', 'now') AS integer) - CAST(strftime('%Y', date_of_birth) AS integer)
+    - (strftime('%m-%d', 'now') < strftime('%m-%d', date_of_birth));
+
+ALTER TABLE "user" DROP COLUMN date_of_birth;
@@ -0,0 +1,7 @@
+ALTER TABLE "user" ADD COLUMN date_of_birth date not null default '1970-01-01';
+
+-- the age was most likely entered when the user signed up. The birthday could be
+-- any day of the year, the middle of it is the best guess.
+UPDATE "user" SET date_of_birth = date(created_at, '-' || age || ' years', '-6 months');
+
+ALTER TABLE "user" DROP COLUMN age;
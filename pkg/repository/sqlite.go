@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLiteScheme starts the database urls of SQLite databases, e.g.
+// sqlite3:///var/lib/weight-tracker/weight-tracker.db. Anything else is a
+// postgres url.
+const SQLiteScheme = "sqlite3://"
+
+// sqlitePragmas are added to every SQLite connection. Foreign keys are off by
+// default, and transactions take the write lock right away, so two of them
+// cannot both read a row and then fail to upgrade to a write.
+const sqlitePragmas = "_foreign_keys=on&_txlock=immediate&_busy_timeout=5000&_journal_mode=WAL"
+
+// NewSQLiteStorage returns a Storage for a database opened with the sqlite3
+// driver and SQLiteDataSource. It runs the same statements as the postgres
+// storage, adapted by sqliteQuerier.
+func NewSQLiteStorage(db *sql.DB) Storage {
+	return &storage{
+		db:     db,
+		sqlite: true,
+	}
+}
+
+// SQLiteDataSource turns a sqlite3:// database url into what the sqlite3
+// driver opens. Pragmas given in the url win over sqlitePragmas.
+func SQLiteDataSource(databaseURL string) string {
+	dataSource := strings.TrimPrefix(databaseURL, SQLiteScheme)
+
+	if strings.Contains(dataSource, "?") {
+		return dataSource + "&" + sqlitePragmas
+	}
+
+	return dataSource + "?" + sqlitePragmas
+}
+
+// sqliteQuerier runs the postgres statements of the storage against SQLite
+type sqliteQuerier struct {
+	querier
+}
+
+func (q sqliteQuerier) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return q.querier.ExecContext(ctx, sqliteStatement(query), sqliteArgs(args)...)
+}
+
+func (q sqliteQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return q.querier.QueryContext(ctx, sqliteStatement(query), sqliteArgs(args)...)
+}
+
+func (q sqliteQuerier) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return q.querier.QueryRowContext(ctx, sqliteStatement(query), sqliteArgs(args)...)
+}
+
+var postgresPlaceholder = regexp.MustCompile(`\$(\d+)`)
+
+// sqliteStatement numbers the placeholders the SQLite way. SQLite reads $1 as
+// a named parameter, numbered by where it first appears, while ?1 is always
+// the first argument.
+func sqliteStatement(query string) string {
+	return postgresPlaceholder.ReplaceAllString(query, "?$1")
+}
+
+// sqliteArgs makes the arguments behave like they do in postgres. Times are
+// stored as text, so they are given in UTC to compare correctly and cut to
+// microseconds like timestamp columns, and decimals are rounded to the two
+// places every numeric column of the schema has.
+func sqliteArgs(args []interface{}) []interface{} {
+	converted := make([]interface{}, len(args))
+
+	for i, arg := range args {
+		switch value := arg.(type) {
+		case time.Time:
+			converted[i] = value.UTC().Truncate(time.Microsecond)
+		case *time.Time:
+			if value != nil {
+				converted[i] = value.UTC().Truncate(time.Microsecond)
+			}
+		case float64:
+			converted[i] = roundColumn(value)
+		case *float64:
+			converted[i] = roundColumnPtr(value)
+		default:
+			converted[i] = arg
+		}
+	}
+
+	return converted
+}
+
+// isSQLiteUniqueViolation tells whether a SQLite statement failed on a unique or primary key constraint
+func isSQLiteUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+
+	return errors.As(err, &sqliteErr) &&
+		(sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey)
+}
@@ -2,34 +2,57 @@ package repository
 
 // update your imports to look like this:
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"log"
-	"path/filepath"
-	"runtime"
+	"sort"
+	"strings"
 	"time"
 
 	"weight-tracker/pkg/api"
 
-	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type Storage interface {
-	RunMigrations(connectionString string) error
-	CreateUser(request api.NewUserRequest) (userID int, err error)
-	CreateWeightEntry(request api.Weight) error
-	DeleteUser(userID int) (deletedUserID int, err error)
-	UpdateUser(request api.UpdateUserRequest) (api.User, error)
-	GetUser(userID int) (api.User, error)
-	GetUsers() ([]api.User, error)
-	GetUserByEmail(userEmail string) (api.User, error)
+	api.Transactor
+	RunMigrations(migrationsDir, connectionString string) error
+	CreateUser(ctx context.Context, request api.NewUserRequest) (userID int, err error)
+	CreateWeightEntry(ctx context.Context, request api.Weight) (api.Weight, error)
+	GetWeight(ctx context.Context, weightID int) (api.Weight, error)
+	UpdateWeightEntry(ctx context.Context, request api.Weight) (api.Weight, error)
+	DeleteWeightEntry(ctx context.Context, weightID int) (deletedWeightID int, err error)
+	GetWeights(ctx context.Context, filter api.WeightFilter) ([]api.Weight, error)
+	DeleteUser(ctx context.Context, userID, version int) (deletedUserID int, err error)
+	UpdateUser(ctx context.Context, request api.UpdateUserRequest) (api.User, error)
+	PatchUser(ctx context.Context, userID, version int, changes api.UserChanges) (api.User, error)
+	RestoreUser(ctx context.Context, userID int) (api.User, error)
+	PurgeUsers(ctx context.Context, deletedBefore time.Time) (purged int, err error)
+	GetUser(ctx context.Context, userID int) (api.User, error)
+	GetUsers(ctx context.Context) ([]api.User, error)
+	GetUserByEmail(ctx context.Context, userEmail string) (api.User, error)
+	GetPasswordHash(ctx context.Context, userID int) (string, error)
+	UpdateRole(ctx context.Context, userID int, role string) (api.User, error)
+	IsCoachOf(ctx context.Context, coachID, clientID int) (bool, error)
+	AddClient(ctx context.Context, coachID, clientID int) error
+	RemoveClient(ctx context.Context, coachID, clientID int) (removed bool, err error)
+	GetClients(ctx context.Context, coachID int) ([]api.User, error)
+	CreateGoal(ctx context.Context, goal api.Goal) (api.Goal, error)
+	GetGoal(ctx context.Context, userID int) (api.Goal, error)
+	UpdateGoal(ctx context.Context, goal api.Goal) (api.Goal, error)
+	CreateMeasurement(ctx context.Context, m api.Measurement) (api.Measurement, error)
+	GetMeasurement(ctx context.Context, measurementID int) (api.Measurement, error)
+	UpdateMeasurement(ctx context.Context, m api.Measurement) (api.Measurement, error)
+	DeleteMeasurement(ctx context.Context, measurementID int) (deletedMeasurementID int, err error)
+	GetMeasurements(ctx context.Context, filter api.MeasurementFilter) ([]api.Measurement, error)
 }
 
 type storage struct {
 	db *sql.DB
+	// sqlite is set when db is a SQLite database rather than postgres
+	sqlite bool
 }
 
 func NewStorage(db *sql.DB) Storage {
@@ -38,42 +61,105 @@ func NewStorage(db *sql.DB) Storage {
 	}
 }
 
-// add this below NewStorage
-func (s *storage) RunMigrations(connectionString string) error {
-	if connectionString == "" {
-		return errors.New("repository: the connString was empty")
+// querier is what *sql.DB and *sql.Tx have in common
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// txKey is the context key of the transaction started by WithTx
+type txKey struct{}
+
+// txFrom returns the transaction WithTx started for ctx
+func txFrom(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
+
+	return tx, ok
+}
+
+// conn is the transaction of ctx, or the connection pool outside of one
+func (s *storage) conn(ctx context.Context) querier {
+	var conn querier = s.db
+
+	if tx, ok := txFrom(ctx); ok {
+		conn = tx
 	}
-	// get base path
-	_, b, _, _ := runtime.Caller(0)
-	basePath := filepath.Join(filepath.Dir(b), "../..")
 
-	migrationsPath := filepath.Join("file://", basePath, "/pkg/repository/migrations/")
+	if s.sqlite {
+		return sqliteQuerier{conn}
+	}
+
+	return conn
+}
 
-	m, err := migrate.New(migrationsPath, connectionString)
+// forUpdate locks the rows a query reads until the transaction of ctx ends,
+// outside of a transaction there is nothing to hold the lock. SQLite has no
+// row locks, its transactions hold the write lock of the whole database.
+func (s *storage) forUpdate(ctx context.Context) string {
+	if _, ok := txFrom(ctx); ok && !s.sqlite {
+		return " FOR UPDATE"
+	}
+
+	return ""
+}
+
+// WithTx runs fn in a transaction. Calling it again with the ctx given to fn
+// joins the transaction that is already running.
+func (s *storage) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := txFrom(ctx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
 
 	if err != nil {
 		return err
 	}
 
-	err = m.Up()
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("storage - rolling back failed: %v", rollbackErr)
+		}
 
-	switch err {
-	case errors.New("no change"):
-		return nil
+		return err
 	}
 
-	return nil
+	return tx.Commit()
 }
 
-func (s *storage) CreateUser(request api.NewUserRequest) (userID int, err error) {
+// RunMigrations applies the migrations in migrationsDir, or the ones built into
+// the binary when it is empty, see NewMigrator
+func (s *storage) RunMigrations(migrationsDir, connectionString string) error {
+	migrator, err := NewMigrator(migrationsDir, connectionString)
+
+	if err != nil {
+		return err
+	}
+
+	defer migrator.Close()
+
+	return migrator.Up()
+}
+
+func (s *storage) CreateUser(ctx context.Context, request api.NewUserRequest) (userID int, err error) {
 	newUserStatement := `
-		INSERT INTO "user" (name, age, height, sex, activity_level, email, weight_goal)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO "user" (name, date_of_birth, height, sex, activity_level, email, weight_goal, unit_system, bmr_formula, weekly_rate, password_hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 		RETURNING id;
 		`
-	err = s.db.QueryRow(newUserStatement, request.Name, request.Age, request.Height, request.Sex, request.ActivityLevel, request.Email, request.WeightGoal).Scan(&userID)
+	err = s.conn(ctx).QueryRowContext(ctx, newUserStatement, request.Name, request.DateOfBirth.Time, request.Height, request.Sex, request.ActivityLevel, request.Email, request.WeightGoal, request.UnitSystem, request.BMRFormula, request.WeeklyRate, request.PasswordHash).Scan(&userID)
 
-	if err != nil {
+	if isUniqueViolation(err) {
+		return 0, errEmailTaken
+	} else if err != nil {
 		log.Printf("this was the error: %v", err.Error())
 		return
 	}
@@ -81,16 +167,28 @@ func (s *storage) CreateUser(request api.NewUserRequest) (userID int, err error)
 	return
 }
 
-func (s *storage) DeleteUser(userID int) (deletedUserID int, err error) {
+// DeleteUser only marks the user as deleted, PurgeUsers removes it along with
+// its weights once it has been deleted for long enough
+func (s *storage) DeleteUser(ctx context.Context, userID, version int) (deletedUserID int, err error) {
 	deleteUserStatement := `
-	DELETE FROM "user" 
-	WHERE id=$1
+	UPDATE "user"
+	SET deleted_at = $3, updated_at = $3, version = version + 1
+	WHERE id=$1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2)
 	RETURNING id ;
 	`
 
-	err = s.db.QueryRow(deleteUserStatement, userID).Scan(&deletedUserID)
+	err = s.conn(ctx).QueryRowContext(ctx, deleteUserStatement, userID, version, time.Now()).Scan(&deletedUserID)
 
-	if err != nil {
+	if errors.Is(err, sql.ErrNoRows) {
+		// the user is either gone, or at another version
+		if err = s.userExists(ctx, userID); errors.Is(err, errUserNotFound) {
+			return 0, nil
+		} else if err != nil {
+			return 0, err
+		}
+
+		return 0, errUserVersionMismatch
+	} else if err != nil {
 		log.Printf("storage error - this was the error: %v", err.Error())
 		return
 	}
@@ -98,29 +196,95 @@ func (s *storage) DeleteUser(userID int) (deletedUserID int, err error) {
 	return
 }
 
-func (s *storage) UpdateUser(request api.UpdateUserRequest) (user api.User, err error) {
+// RestoreUser undoes DeleteUser, as long as the user has not been purged
+func (s *storage) RestoreUser(ctx context.Context, userID int) (api.User, error) {
+	restoreUserStatement := `
+		UPDATE "user"
+		SET deleted_at = NULL, updated_at = $2, version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		RETURNING ` + userColumns + `;
+		`
+
+	user, err := scanUser(s.conn(ctx).QueryRowContext(ctx, restoreUserStatement, userID, time.Now()))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return api.User{}, errDeletedUserNotFound
+	} else if isUniqueViolation(err) {
+		return api.User{}, errEmailTaken
+	} else if err != nil {
+		log.Printf("this was the error: %v", err.Error())
+		return api.User{}, err
+	}
+
+	return user, nil
+}
+
+// PurgeUsers removes the users deleted before the given time for good, together
+// with their weights, goals, measurements and coach assignments
+func (s *storage) PurgeUsers(ctx context.Context, deletedBefore time.Time) (purged int, err error) {
+	purgedUsers := `SELECT id FROM "user" WHERE deleted_at < $1`
+
+	purgeStatements := []string{
+		`DELETE FROM weight WHERE user_id IN (` + purgedUsers + `);`,
+		`DELETE FROM goal WHERE user_id IN (` + purgedUsers + `);`,
+		`DELETE FROM measurement WHERE user_id IN (` + purgedUsers + `);`,
+		`DELETE FROM coach_client WHERE coach_id IN (` + purgedUsers + `) OR client_id IN (` + purgedUsers + `);`,
+	}
+
+	err = s.WithTx(ctx, func(ctx context.Context) error {
+		for _, statement := range purgeStatements {
+			if _, err := s.conn(ctx).ExecContext(ctx, statement, deletedBefore); err != nil {
+				log.Printf("this was the error: %v", err.Error())
+				return err
+			}
+		}
+
+		result, err := s.conn(ctx).ExecContext(ctx, `DELETE FROM "user" WHERE deleted_at < $1;`, deletedBefore)
+
+		if err != nil {
+			log.Printf("this was the error: %v", err.Error())
+			return err
+		}
+
+		affected, err := result.RowsAffected()
+		purged = int(affected)
+
+		return err
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
+
+func (s *storage) UpdateUser(ctx context.Context, request api.UpdateUserRequest) (user api.User, err error) {
 	updateUserStatement := `
 		UPDATE "user" 
-		SET name = $2, age = $3, height = $4,
+		SET name = $2, date_of_birth = $3, height = $4,
 		sex = $5, activity_level = $6, email = $7, 
-		weight_goal = $8, updated_at = $9 WHERE id = $1
-		RETURNING id, name, age, height, sex, activity_level, email,	
-		weight_goal
-		;`
+		weight_goal = $8, unit_system = $9, bmr_formula = $10,
+		weekly_rate = $11, updated_at = $12, version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL AND ($13 = 0 OR version = $13)
+		RETURNING ` + userColumns + `;`
 
 	updateTime := time.Now()
 
-	err = s.db.QueryRow(updateUserStatement,
-		request.ID, request.Name, request.Age,
+	row := s.conn(ctx).QueryRowContext(ctx, updateUserStatement,
+		request.ID, request.Name, request.DateOfBirth.Time,
 		request.Height, request.Sex, request.ActivityLevel,
-		request.Email, request.WeightGoal, updateTime,
-	).Scan(
-		&user.ID, &user.Name, &user.Age,
-		&user.Height, &user.Sex, &user.ActivityLevel,
-		&user.Email, &user.WeightGoal,
+		request.Email, request.WeightGoal, request.UnitSystem,
+		request.BMRFormula, request.WeeklyRate, updateTime,
+		request.Version,
 	)
+	user, err = scanUser(row)
 
-	if err != nil {
+	if errors.Is(err, sql.ErrNoRows) {
+		return api.User{}, s.userVersionMismatch(ctx, request.ID)
+	} else if isUniqueViolation(err) {
+		return api.User{}, errEmailTaken
+	} else if err != nil {
 		log.Printf("this was the error: %v", err.Error())
 		return
 	}
@@ -128,65 +292,381 @@ func (s *storage) UpdateUser(request api.UpdateUserRequest) (user api.User, err
 	return
 }
 
-func (s *storage) CreateWeightEntry(request api.Weight) error {
+// patchableUserColumns are the columns PatchUser may set. Column names cannot
+// be passed as parameters, so they are checked against this list instead.
+var patchableUserColumns = map[string]bool{
+	"name": true, "date_of_birth": true, "height": true, "sex": true,
+	"activity_level": true, "email": true, "weight_goal": true,
+	"unit_system": true, "bmr_formula": true, "weekly_rate": true,
+}
+
+// PatchUser only sets the columns in changes, along with updated_at and version
+func (s *storage) PatchUser(ctx context.Context, userID, version int, changes api.UserChanges) (api.User, error) {
+	columns := make([]string, 0, len(changes))
+
+	for column := range changes {
+		if !patchableUserColumns[column] {
+			return api.User{}, fmt.Errorf("storage - cannot patch column %q of a user", column)
+		}
+
+		columns = append(columns, column)
+	}
+
+	// a stable order keeps the statements the same for the same columns
+	sort.Strings(columns)
+
+	args := []interface{}{userID, version, time.Now()}
+	assignments := []string{"updated_at = $3", "version = version + 1"}
+
+	for _, column := range columns {
+		args = append(args, changes[column])
+		assignments = append(assignments, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	patchUserStatement := `
+		UPDATE "user"
+		SET ` + strings.Join(assignments, ", ") + `
+		WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2)
+		RETURNING ` + userColumns + `;`
+
+	user, err := scanUser(s.conn(ctx).QueryRowContext(ctx, patchUserStatement, args...))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return api.User{}, s.userVersionMismatch(ctx, userID)
+	} else if isUniqueViolation(err) {
+		return api.User{}, errEmailTaken
+	} else if err != nil {
+		log.Printf("this was the error: %v", err.Error())
+		return api.User{}, err
+	}
+
+	return user, nil
+}
+
+func (s *storage) CreateWeightEntry(ctx context.Context, request api.Weight) (api.Weight, error) {
 	newWeightStatement := `
-		INSERT INTO weight (weight, user_id, bmr, daily_caloric_intake)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO weight (weight, user_id, bmr, daily_caloric_intake, measured_at,
+		body_fat_percentage, bmr_formula)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + weightColumns + `;
+		`
+
+	weight, err := scanWeight(s.conn(ctx).QueryRowContext(ctx, newWeightStatement,
+		request.Weight, request.UserID, request.BMR, request.DailyCaloricIntake,
+		request.MeasuredAt, request.BodyFatPercentage, request.BMRFormula,
+	))
+
+	if err != nil {
+		log.Printf("this was the error: %v", err.Error())
+		return api.Weight{}, err
+	}
+
+	return weight, nil
+}
+
+func (s *storage) GetWeight(ctx context.Context, weightID int) (weight api.Weight, err error) {
+	getWeightStatement := `
+		SELECT ` + weightColumns + `
+		FROM weight
+		WHERE id = $1` + s.forUpdate(ctx) + `;
+		`
+
+	weight, err = scanWeight(s.conn(ctx).QueryRowContext(ctx, getWeightStatement, weightID))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return api.Weight{}, errWeightNotFound
+	} else if err != nil {
+		log.Printf("this was the error: %v", err.Error())
+		return api.Weight{}, err
+	}
+
+	return
+}
+
+func (s *storage) UpdateWeightEntry(ctx context.Context, request api.Weight) (weight api.Weight, err error) {
+	updateWeightStatement := `
+		UPDATE weight
+		SET weight = $2, bmr = $3, daily_caloric_intake = $4, measured_at = $5,
+		body_fat_percentage = $6, bmr_formula = $7, updated_at = $8
+		WHERE id = $1
+		RETURNING ` + weightColumns + `;
+		`
+
+	updateTime := time.Now()
+
+	row := s.conn(ctx).QueryRowContext(ctx, updateWeightStatement,
+		request.ID, request.Weight, request.BMR,
+		request.DailyCaloricIntake, request.MeasuredAt,
+		request.BodyFatPercentage, request.BMRFormula, updateTime,
+	)
+	weight, err = scanWeight(row)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return api.Weight{}, errWeightNotFound
+	} else if err != nil {
+		log.Printf("this was the error: %v", err.Error())
+		return api.Weight{}, err
+	}
+
+	return
+}
+
+// DeleteWeightEntry returns a deletedWeightID of 0 when there was no entry with the given id
+func (s *storage) DeleteWeightEntry(ctx context.Context, weightID int) (deletedWeightID int, err error) {
+	deleteWeightStatement := `
+		DELETE FROM weight
+		WHERE id = $1
 		RETURNING id;
 		`
 
-	var ID int
-	err := s.db.QueryRow(newWeightStatement, request.Weight, request.UserID, request.BMR, request.DailyCaloricIntake).Scan(&ID)
+	err = s.conn(ctx).QueryRowContext(ctx, deleteWeightStatement, weightID).Scan(&deletedWeightID)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	} else if err != nil {
+		log.Printf("storage error - this was the error: %v", err.Error())
+		return
+	}
+
+	return
+}
+
+// GetWeights returns the weight entries of a user matching the filter. Entries are
+// ordered by measured_at, with the id breaking ties, so the cursor stays stable.
+func (s *storage) GetWeights(ctx context.Context, filter api.WeightFilter) (weights []api.Weight, err error) {
+	getWeightsStatement := `
+		SELECT ` + weightColumns + `
+		FROM weight
+		WHERE user_id = $1`
+
+	args := []interface{}{filter.UserID}
+
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		getWeightsStatement += fmt.Sprintf(" AND measured_at >= $%d", len(args))
+	}
+
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		getWeightsStatement += fmt.Sprintf(" AND measured_at <= $%d", len(args))
+	}
+
+	direction, comparison := "ASC", ">"
+
+	if filter.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if filter.After != nil {
+		args = append(args, filter.After.MeasuredAt, filter.After.ID)
+		getWeightsStatement += fmt.Sprintf(
+			" AND (measured_at, id) %s ($%d, $%d)",
+			comparison, len(args)-1, len(args),
+		)
+	}
+
+	getWeightsStatement += fmt.Sprintf(" ORDER BY measured_at %s, id %s", direction, direction)
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		getWeightsStatement += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := s.conn(ctx).QueryContext(ctx, getWeightsStatement, args...)
+
+	if err != nil {
+		log.Printf("this was the error: %v", err.Error())
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var weight api.Weight
+		if weight, err = scanWeight(rows); err != nil {
+			return
+		}
+		weights = append(weights, weight)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+func (s *storage) CreateMeasurement(ctx context.Context, m api.Measurement) (api.Measurement, error) {
+	newMeasurementStatement := `
+		INSERT INTO measurement (user_id, measured_at, body_fat_percentage, muscle_mass,
+		water_percentage, waist, hip, neck, chest)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING ` + measurementColumns + `;
+		`
+
+	created, err := scanMeasurement(s.conn(ctx).QueryRowContext(ctx, newMeasurementStatement,
+		m.UserID, m.MeasuredAt, m.BodyFatPercentage, m.MuscleMass,
+		m.WaterPercentage, m.Waist, m.Hip, m.Neck, m.Chest,
+	))
 
 	if err != nil {
 		log.Printf("this was the error: %v", err.Error())
-		return err
+		return api.Measurement{}, err
 	}
 
-	return nil
+	return created, nil
+}
+
+func (s *storage) GetMeasurement(ctx context.Context, measurementID int) (api.Measurement, error) {
+	getMeasurementStatement := `
+		SELECT ` + measurementColumns + `
+		FROM measurement
+		WHERE id = $1` + s.forUpdate(ctx) + `;
+		`
+
+	measurement, err := scanMeasurement(s.conn(ctx).QueryRowContext(ctx, getMeasurementStatement, measurementID))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return api.Measurement{}, errMeasurementNotFound
+	} else if err != nil {
+		log.Printf("this was the error: %v", err.Error())
+		return api.Measurement{}, err
+	}
+
+	return measurement, nil
+}
+
+func (s *storage) UpdateMeasurement(ctx context.Context, m api.Measurement) (api.Measurement, error) {
+	updateMeasurementStatement := `
+		UPDATE measurement
+		SET measured_at = $2, body_fat_percentage = $3, muscle_mass = $4, water_percentage = $5,
+		waist = $6, hip = $7, neck = $8, chest = $9, updated_at = $10
+		WHERE id = $1
+		RETURNING ` + measurementColumns + `;
+		`
+
+	updated, err := scanMeasurement(s.conn(ctx).QueryRowContext(ctx, updateMeasurementStatement,
+		m.ID, m.MeasuredAt, m.BodyFatPercentage, m.MuscleMass, m.WaterPercentage,
+		m.Waist, m.Hip, m.Neck, m.Chest, time.Now(),
+	))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return api.Measurement{}, errMeasurementNotFound
+	} else if err != nil {
+		log.Printf("this was the error: %v", err.Error())
+		return api.Measurement{}, err
+	}
+
+	return updated, nil
+}
+
+// DeleteMeasurement returns a deletedMeasurementID of 0 when there was no measurement with the given id
+func (s *storage) DeleteMeasurement(ctx context.Context, measurementID int) (deletedMeasurementID int, err error) {
+	err = s.conn(ctx).QueryRowContext(ctx, `DELETE FROM measurement WHERE id = $1 RETURNING id;`, measurementID).Scan(&deletedMeasurementID)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	} else if err != nil {
+		log.Printf("this was the error: %v", err.Error())
+		return 0, err
+	}
+
+	return deletedMeasurementID, nil
 }
 
-func (s *storage) GetUsers() (users []api.User, err error) {
+// GetMeasurements returns the measurements of a user matching the filter, ordered
+// by measured_at with the id breaking ties
+func (s *storage) GetMeasurements(ctx context.Context, filter api.MeasurementFilter) (measurements []api.Measurement, err error) {
+	getMeasurementsStatement := `
+		SELECT ` + measurementColumns + `
+		FROM measurement
+		WHERE user_id = $1`
+
+	args := []interface{}{filter.UserID}
+
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		getMeasurementsStatement += fmt.Sprintf(" AND measured_at >= $%d", len(args))
+	}
+
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		getMeasurementsStatement += fmt.Sprintf(" AND measured_at <= $%d", len(args))
+	}
+
+	direction := "ASC"
+
+	if filter.Descending {
+		direction = "DESC"
+	}
+
+	getMeasurementsStatement += fmt.Sprintf(" ORDER BY measured_at %s, id %s", direction, direction)
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		getMeasurementsStatement += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := s.conn(ctx).QueryContext(ctx, getMeasurementsStatement, args...)
+
+	if err != nil {
+		log.Printf("this was the error: %v", err.Error())
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var measurement api.Measurement
+		if measurement, err = scanMeasurement(rows); err != nil {
+			return
+		}
+		measurements = append(measurements, measurement)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+func (s *storage) GetUsers(ctx context.Context) (users []api.User, err error) {
 	getAllUsersStatement := `
-		SELECT id, name, age, height, sex, activity_level, email, weight_goal,
-		created_at, updated_at
-		FROM "user";
+		SELECT ` + userColumns + `
+		FROM "user"
+		WHERE deleted_at IS NULL;
 	`
 	// query users here
-	rows, err := s.db.Query(getAllUsersStatement)
+	rows, err := s.conn(ctx).QueryContext(ctx, getAllUsersStatement)
 
 	if err != nil {
 		return
 	}
 
+	defer rows.Close()
+
 	// scan each user and add to users
 	for rows.Next() {
-		user := api.User{}
-		if err = rows.Scan(
-			&user.ID, &user.Name, &user.Age,
-			&user.Height, &user.Sex, &user.ActivityLevel,
-			&user.Email, &user.WeightGoal,
-			&user.CreatedAt, &user.UpdatedAt,
-		); err != nil {
+		var user api.User
+		if user, err = scanUser(rows); err != nil {
 			return
 		}
 		users = append(users, user)
 	}
 
-	rows.Close()
+	err = rows.Err()
+
 	return
 }
 
-func (s *storage) GetUser(userID int) (api.User, error) {
+func (s *storage) GetUser(ctx context.Context, userID int) (api.User, error) {
 	getUserStatement := `
-		SELECT id, name, age, height, sex, activity_level, email, weight_goal FROM "user"
-		where id=$1;
+		SELECT ` + userColumns + ` FROM "user"
+		where id=$1 AND deleted_at IS NULL` + s.forUpdate(ctx) + `;
 		`
 
-	var user api.User
-	err := s.db.QueryRow(getUserStatement, userID).Scan(&user.ID, &user.Name, &user.Age, &user.Height, &user.Sex, &user.ActivityLevel, &user.Email, &user.WeightGoal)
+	user, err := scanUser(s.conn(ctx).QueryRowContext(ctx, getUserStatement, userID))
 
-	if err != nil {
+	if errors.Is(err, sql.ErrNoRows) {
+		return api.User{}, errUserNotFound
+	} else if err != nil {
 		log.Printf("this was the error: %v", err.Error())
 		return api.User{}, err
 	}
@@ -195,13 +675,13 @@ func (s *storage) GetUser(userID int) (api.User, error) {
 }
 
 // queries for a user with given email. Returns
-func (s *storage) GetUserByEmail(userEmail string) (user api.User, err error) {
+func (s *storage) GetUserByEmail(ctx context.Context, userEmail string) (user api.User, err error) {
 	getUserByEmailStatement := `
-		SELECT id, name, age, height, sex, activity_level, email, weight_goal FROM "user"
-		where email=$1;
+		SELECT ` + userColumns + ` FROM "user"
+		where email=$1 AND deleted_at IS NULL;
 		`
 
-	err = s.db.QueryRow(getUserByEmailStatement, userEmail).Scan(&user.ID, &user.Name, &user.Age, &user.Height, &user.Sex, &user.ActivityLevel, &user.Email, &user.WeightGoal)
+	user, err = scanUser(s.conn(ctx).QueryRowContext(ctx, getUserByEmailStatement, userEmail))
 
 	// no user with the given email was found in this case
 	if errors.Is(err, sql.ErrNoRows) {
@@ -214,3 +694,301 @@ func (s *storage) GetUserByEmail(userEmail string) (user api.User, err error) {
 	// return the queried user if it does exist
 	return user, nil
 }
+
+// GetPasswordHash returns an empty hash for users that never set a password
+func (s *storage) GetPasswordHash(ctx context.Context, userID int) (passwordHash string, err error) {
+	getPasswordHashStatement := `
+		SELECT COALESCE(password_hash, '') FROM "user"
+		WHERE id = $1 AND deleted_at IS NULL;
+		`
+
+	err = s.conn(ctx).QueryRowContext(ctx, getPasswordHashStatement, userID).Scan(&passwordHash)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errUserNotFound
+	} else if err != nil {
+		log.Printf("this was the error: %v", err.Error())
+		return "", err
+	}
+
+	return
+}
+
+func (s *storage) UpdateRole(ctx context.Context, userID int, role string) (api.User, error) {
+	updateRoleStatement := `
+		UPDATE "user"
+		SET role = $2, updated_at = $3, version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING ` + userColumns + `;
+		`
+
+	user, err := scanUser(s.conn(ctx).QueryRowContext(ctx, updateRoleStatement, userID, role, time.Now()))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return api.User{}, errUserNotFound
+	} else if err != nil {
+		log.Printf("this was the error: %v", err.Error())
+		return api.User{}, err
+	}
+
+	return user, nil
+}
+
+func (s *storage) IsCoachOf(ctx context.Context, coachID, clientID int) (isCoach bool, err error) {
+	isCoachStatement := `
+		SELECT EXISTS (
+			SELECT 1 FROM coach_client
+			WHERE coach_id = $1 AND client_id = $2
+		);
+		`
+
+	err = s.conn(ctx).QueryRowContext(ctx, isCoachStatement, coachID, clientID).Scan(&isCoach)
+
+	if err != nil {
+		log.Printf("this was the error: %v", err.Error())
+		return false, err
+	}
+
+	return
+}
+
+func (s *storage) AddClient(ctx context.Context, coachID, clientID int) error {
+	addClientStatement := `
+		INSERT INTO coach_client (coach_id, client_id)
+		VALUES ($1, $2);
+		`
+
+	_, err := s.conn(ctx).ExecContext(ctx, addClientStatement, coachID, clientID)
+
+	if isUniqueViolation(err) {
+		return api.ConflictError("storage - user is already a client of this coach")
+	} else if err != nil {
+		log.Printf("this was the error: %v", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (s *storage) RemoveClient(ctx context.Context, coachID, clientID int) (removed bool, err error) {
+	removeClientStatement := `
+		DELETE FROM coach_client
+		WHERE coach_id = $1 AND client_id = $2;
+		`
+
+	result, err := s.conn(ctx).ExecContext(ctx, removeClientStatement, coachID, clientID)
+
+	if err != nil {
+		log.Printf("this was the error: %v", err.Error())
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (s *storage) GetClients(ctx context.Context, coachID int) (clients []api.User, err error) {
+	getClientsStatement := `
+		SELECT ` + userColumns + `
+		FROM "user"
+		WHERE id IN (SELECT client_id FROM coach_client WHERE coach_id = $1)
+		AND deleted_at IS NULL
+		ORDER BY id;
+		`
+
+	rows, err := s.conn(ctx).QueryContext(ctx, getClientsStatement, coachID)
+
+	if err != nil {
+		log.Printf("this was the error: %v", err.Error())
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var client api.User
+		if client, err = scanUser(rows); err != nil {
+			return
+		}
+		clients = append(clients, client)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+func (s *storage) CreateGoal(ctx context.Context, goal api.Goal) (api.Goal, error) {
+	newGoalStatement := `
+		INSERT INTO goal (user_id, start_weight, target_weight, target_date)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + goalColumns + `;
+		`
+
+	created, err := scanGoal(s.conn(ctx).QueryRowContext(ctx, newGoalStatement,
+		goal.UserID, goal.StartWeight, goal.TargetWeight, goal.TargetDate,
+	))
+
+	if isUniqueViolation(err) {
+		return api.Goal{}, api.ConflictError("storage - user already has a goal")
+	} else if err != nil {
+		log.Printf("this was the error: %v", err.Error())
+		return api.Goal{}, err
+	}
+
+	return created, nil
+}
+
+// GetGoal returns an empty goal when the user has none
+func (s *storage) GetGoal(ctx context.Context, userID int) (api.Goal, error) {
+	getGoalStatement := `
+		SELECT ` + goalColumns + `
+		FROM goal
+		WHERE user_id = $1;
+		`
+
+	goal, err := scanGoal(s.conn(ctx).QueryRowContext(ctx, getGoalStatement, userID))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return api.Goal{}, nil
+	} else if err != nil {
+		log.Printf("this was the error: %v", err.Error())
+		return api.Goal{}, err
+	}
+
+	return goal, nil
+}
+
+func (s *storage) UpdateGoal(ctx context.Context, goal api.Goal) (api.Goal, error) {
+	updateGoalStatement := `
+		UPDATE goal
+		SET start_weight = $2, target_weight = $3, target_date = $4, updated_at = $5
+		WHERE id = $1
+		RETURNING ` + goalColumns + `;
+		`
+
+	updated, err := scanGoal(s.conn(ctx).QueryRowContext(ctx, updateGoalStatement,
+		goal.ID, goal.StartWeight, goal.TargetWeight, goal.TargetDate, time.Now(),
+	))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return api.Goal{}, api.NotFoundError("storage - goal does not exist")
+	} else if err != nil {
+		log.Printf("this was the error: %v", err.Error())
+		return api.Goal{}, err
+	}
+
+	return updated, nil
+}
+
+// returned instead of sql.ErrNoRows, so the services can tell a missing row
+// from a failing database
+var (
+	errUserNotFound        = api.NotFoundError("storage - user does not exist")
+	errWeightNotFound      = api.NotFoundError("storage - weight does not exist")
+	errMeasurementNotFound = api.NotFoundError("storage - measurement does not exist")
+	errEmailTaken          = api.ConflictError("storage - user with email already exists")
+
+	errUserVersionMismatch = api.PreconditionFailedError("storage - user was changed since it was read")
+	errDeletedUserNotFound = api.NotFoundError("storage - deleted user does not exist")
+)
+
+// userVersionMismatch explains why a versioned update of a user changed no rows
+func (s *storage) userVersionMismatch(ctx context.Context, userID int) error {
+	if err := s.userExists(ctx, userID); err != nil {
+		return err
+	}
+
+	return errUserVersionMismatch
+}
+
+// userExists fails with errUserNotFound when there is no user with the ID that is not deleted
+func (s *storage) userExists(ctx context.Context, userID int) error {
+	var exists bool
+
+	err := s.conn(ctx).QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM "user" WHERE id = $1 AND deleted_at IS NULL);`, userID).Scan(&exists)
+
+	if err != nil {
+		log.Printf("this was the error: %v", err.Error())
+		return err
+	} else if !exists {
+		return errUserNotFound
+	}
+
+	return nil
+}
+
+// isUniqueViolation tells whether a query failed on a unique or primary key constraint
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" || isSQLiteUniqueViolation(err)
+}
+
+// columns read whenever a whole user is queried, in the order scanUser expects them
+const userColumns = `id, name, date_of_birth, height, sex, activity_level, email, weight_goal,
+		unit_system, bmr_formula, weekly_rate, role, created_at, updated_at, version`
+
+// columns read whenever a whole weight entry is queried, in the order scanWeight expects them
+const weightColumns = `id, created_at, updated_at, measured_at, weight, user_id, bmr,
+		COALESCE(daily_caloric_intake, 0), body_fat_percentage, bmr_formula`
+
+// columns read whenever a whole goal is queried, in the order scanGoal expects them
+const goalColumns = `id, user_id, created_at, updated_at, start_weight, target_weight, target_date`
+
+// columns read whenever a whole measurement is queried, in the order scanMeasurement expects them
+const measurementColumns = `id, user_id, created_at, updated_at, measured_at, body_fat_percentage,
+		muscle_mass, water_percentage, waist, hip, neck, chest`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (user api.User, err error) {
+	var dateOfBirth time.Time
+
+	err = row.Scan(
+		&user.ID, &user.Name, &dateOfBirth,
+		&user.Height, &user.Sex, &user.ActivityLevel,
+		&user.Email, &user.WeightGoal, &user.UnitSystem,
+		&user.BMRFormula, &user.WeeklyRate, &user.Role,
+		&user.CreatedAt, &user.UpdatedAt, &user.Version,
+	)
+	user.DateOfBirth = api.DateOf(dateOfBirth)
+
+	return
+}
+
+func scanWeight(row rowScanner) (weight api.Weight, err error) {
+	err = row.Scan(
+		&weight.ID, &weight.CreatedAt, &weight.UpdatedAt, &weight.MeasuredAt,
+		&weight.Weight, &weight.UserID, &weight.BMR, &weight.DailyCaloricIntake,
+		&weight.BodyFatPercentage, &weight.BMRFormula,
+	)
+
+	return
+}
+
+func scanGoal(row rowScanner) (goal api.Goal, err error) {
+	err = row.Scan(
+		&goal.ID, &goal.UserID, &goal.CreatedAt, &goal.UpdatedAt,
+		&goal.StartWeight, &goal.TargetWeight, &goal.TargetDate,
+	)
+
+	return
+}
+
+func scanMeasurement(row rowScanner) (m api.Measurement, err error) {
+	err = row.Scan(
+		&m.ID, &m.UserID, &m.CreatedAt, &m.UpdatedAt, &m.MeasuredAt, &m.BodyFatPercentage,
+		&m.MuscleMass, &m.WaterPercentage, &m.Waist, &m.Hip, &m.Neck, &m.Chest,
+	)
+
+	return
+}
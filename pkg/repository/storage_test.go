@@ -0,0 +1,473 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+	"weight-tracker/pkg/api"
+	"weight-tracker/pkg/repository"
+)
+
+// the date of birth of every user the tests create
+var dateOfBirth = api.DateOf(time.Date(1992, 5, 17, 0, 0, 0, 0, time.UTC))
+
+// the postgres storage is only tested when this points at a database the tests may wipe
+const envTestDatabaseURL = "WEIGHT_TRACKER_TEST_DATABASE_URL"
+
+func TestMemoryStorage(t *testing.T) {
+	testStorage(t, func(t *testing.T) repository.Storage {
+		return repository.NewMemoryStorage()
+	})
+}
+
+func TestSQLiteStorage(t *testing.T) {
+	testStorage(t, func(t *testing.T) repository.Storage {
+		databaseURL := repository.SQLiteScheme + filepath.Join(t.TempDir(), "weight-tracker.db")
+
+		return openStorage(t, "sqlite3", repository.SQLiteDataSource(databaseURL), databaseURL, repository.NewSQLiteStorage)
+	})
+}
+
+func TestPostgresStorage(t *testing.T) {
+	databaseURL := os.Getenv(envTestDatabaseURL)
+
+	if databaseURL == "" {
+		t.Skipf("set %s to test against postgres", envTestDatabaseURL)
+	}
+
+	testStorage(t, func(t *testing.T) repository.Storage {
+		return openStorage(t, "postgres", databaseURL, databaseURL, repository.NewStorage)
+	})
+}
+
+// openStorage opens the database, migrates it and closes it once the test is done
+func openStorage(t *testing.T, driver, dataSource, databaseURL string, newStorage func(db *sql.DB) repository.Storage) repository.Storage {
+	db, err := sql.Open(driver, dataSource)
+
+	if err != nil {
+		t.Fatalf("could not open the database: %v", err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	storage := newStorage(db)
+
+	if err = storage.RunMigrations("", databaseURL); err != nil {
+		t.Fatalf("could not migrate the database: %v", err)
+	}
+
+	// every test starts from empty tables, with the sequences reset
+	if driver == "postgres" {
+		if _, err = db.Exec(`TRUNCATE weight, goal, measurement, coach_client, "user" RESTART IDENTITY;`); err != nil {
+			t.Fatalf("could not empty the database: %v", err)
+		}
+	}
+
+	return storage
+}
+
+// testStorage is the suite every Storage has to pass, each test gets an empty storage
+func testStorage(t *testing.T, newStorage func(t *testing.T) repository.Storage) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, storage repository.Storage)
+	}{
+		{name: "users", test: testStorageUsers},
+		{name: "weights", test: testStorageWeights},
+		{name: "measurements", test: testStorageMeasurements},
+		{name: "goals and clients", test: testStorageGoalsAndClients},
+		{name: "transactions", test: testStorageWithTx},
+		{name: "concurrent writes", test: testStorageConcurrentWrites},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.test(t, newStorage(t))
+		})
+	}
+}
+
+func newMemberRequest(email string) api.NewUserRequest {
+	return api.NewUserRequest{
+		Name:          "Rabbit",
+		DateOfBirth:   dateOfBirth,
+		Height:        170,
+		Sex:           "female",
+		ActivityLevel: 2,
+		WeightGoal:    "maintain",
+		Email:         email,
+		UnitSystem:    "metric",
+		BMRFormula:    "mifflin_st_jeor",
+		PasswordHash:  "hash",
+	}
+}
+
+func testStorageUsers(t *testing.T, storage repository.Storage) {
+	ctx := context.Background()
+
+	first, err := storage.CreateUser(ctx, newMemberRequest("rabbit@email.com"))
+
+	if err != nil || first == 0 {
+		t.Fatalf("got: %v, %v, wanted a user id", first, err)
+	}
+
+	if _, err = storage.CreateUser(ctx, newMemberRequest("rabbit@email.com")); !errors.Is(err, api.ErrConflict) {
+		t.Errorf("creating a user with a taken email: got: %v, wanted a conflict", err)
+	}
+
+	user, err := storage.GetUser(ctx, first)
+
+	if err != nil || user.Role != api.RoleMember || user.Version != 1 || user.Height != 170 || user.DateOfBirth != dateOfBirth || user.CreatedAt.IsZero() {
+		t.Errorf("got: %+v, %v, wanted a member at version 1", user, err)
+	}
+
+	if _, err = storage.DeleteUser(ctx, first, 2); !errors.Is(err, api.ErrPreconditionFailed) {
+		t.Errorf("deleting a user with a stale version: got: %v, wanted a failed precondition", err)
+	}
+
+	if deleted, err := storage.DeleteUser(ctx, first, 1); err != nil || deleted != first {
+		t.Fatalf("got: %v, %v, wanted: %v, nil", deleted, err, first)
+	}
+
+	if _, err = storage.GetUser(ctx, first); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("getting a deleted user: got: %v, wanted not found", err)
+	}
+
+	// the email of a deleted user can be used again, which keeps the deleted user from being restored
+	second, err := storage.CreateUser(ctx, newMemberRequest("rabbit@email.com"))
+
+	if err != nil || second <= first {
+		t.Fatalf("got: %v, %v, wanted an id after %v", second, err, first)
+	}
+
+	if _, err = storage.RestoreUser(ctx, first); !errors.Is(err, api.ErrConflict) {
+		t.Errorf("restoring a user whose email is taken: got: %v, wanted a conflict", err)
+	}
+
+	rate := 0.456
+	bornLater := api.DateOf(time.Date(2001, 2, 3, 0, 0, 0, 0, time.UTC))
+	patched, err := storage.PatchUser(ctx, second, api.AnyVersion, api.UserChanges{"name": "Mole", "date_of_birth": bornLater.Time, "weekly_rate": &rate})
+
+	if err != nil || patched.Name != "Mole" || patched.DateOfBirth != bornLater || patched.Version != 2 || patched.WeeklyRate == nil || *patched.WeeklyRate != 0.46 {
+		t.Errorf("got: %+v, %v, wanted Mole aged 40 losing 0.46 a week at version 2", patched, err)
+	}
+
+	if _, err = storage.PatchUser(ctx, second, 1, api.UserChanges{"name": "Vole"}); !errors.Is(err, api.ErrPreconditionFailed) {
+		t.Errorf("patching a user with a stale version: got: %v, wanted a failed precondition", err)
+	}
+
+	if _, err = storage.PatchUser(ctx, second, api.AnyVersion, api.UserChanges{"role": "admin"}); err == nil {
+		t.Errorf("patching a column that cannot be patched should fail")
+	}
+
+	purged, err := storage.PurgeUsers(ctx, time.Now().Add(time.Minute))
+
+	if err != nil || purged != 1 {
+		t.Errorf("got: %v, %v, wanted: 1, nil", purged, err)
+	}
+
+	if _, err = storage.RestoreUser(ctx, first); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("restoring a purged user: got: %v, wanted not found", err)
+	}
+
+	users, err := storage.GetUsers(ctx)
+
+	if err != nil || len(users) != 1 || users[0].ID != second {
+		t.Errorf("got: %+v, %v, wanted only user %v", users, err, second)
+	}
+
+	byEmail, err := storage.GetUserByEmail(ctx, "rabbit@email.com")
+
+	if err != nil || byEmail.ID != second {
+		t.Errorf("got: %+v, %v, wanted user %v", byEmail, err, second)
+	}
+}
+
+func testStorageWeights(t *testing.T, storage repository.Storage) {
+	ctx := context.Background()
+
+	if _, err := storage.CreateWeightEntry(ctx, api.Weight{UserID: 1, Weight: 80, MeasuredAt: time.Now()}); err == nil {
+		t.Errorf("creating a weight entry for a user that does not exist should fail")
+	}
+
+	userID, _ := storage.CreateUser(ctx, newMemberRequest("rabbit@email.com"))
+	day := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// two entries measured at the same time are ordered by id
+	var created []int
+
+	for _, measuredAt := range []time.Time{day.Add(48 * time.Hour), day, day.Add(24 * time.Hour), day} {
+		weight, err := storage.CreateWeightEntry(ctx, api.Weight{UserID: userID, Weight: 80.456, BMR: 1600, BMRFormula: "mifflin_st_jeor", MeasuredAt: measuredAt})
+
+		if err != nil {
+			t.Fatalf("could not create a weight entry: %v", err)
+		}
+
+		created = append(created, weight.ID)
+	}
+
+	weight, err := storage.GetWeight(ctx, created[0])
+
+	if err != nil || weight.Weight != 80.46 || !weight.MeasuredAt.Equal(day.Add(48*time.Hour)) {
+		t.Errorf("got: %+v, %v, wanted the weight rounded to 80.46", weight, err)
+	}
+
+	ids := func(weights []api.Weight) (ids []int) {
+		for _, weight := range weights {
+			ids = append(ids, weight.ID)
+		}
+
+		return ids
+	}
+
+	to := day.Add(24 * time.Hour)
+
+	tests := []struct {
+		name     string
+		filter   api.WeightFilter
+		want_ids []int
+	}{
+		{
+			name:     "should order by measured_at and id",
+			filter:   api.WeightFilter{UserID: userID},
+			want_ids: []int{created[1], created[3], created[2], created[0]},
+		}, {
+			name:     "should order descending",
+			filter:   api.WeightFilter{UserID: userID, Descending: true},
+			want_ids: []int{created[0], created[2], created[3], created[1]},
+		}, {
+			name:     "should include both ends of the range",
+			filter:   api.WeightFilter{UserID: userID, From: &day, To: &to},
+			want_ids: []int{created[1], created[3], created[2]},
+		}, {
+			name:     "should continue after the cursor",
+			filter:   api.WeightFilter{UserID: userID, After: &api.WeightCursor{MeasuredAt: day, ID: created[1]}, Limit: 2},
+			want_ids: []int{created[3], created[2]},
+		}, {
+			name:     "should continue after the cursor descending",
+			filter:   api.WeightFilter{UserID: userID, Descending: true, After: &api.WeightCursor{MeasuredAt: day, ID: created[3]}},
+			want_ids: []int{created[1]},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			weights, err := storage.GetWeights(ctx, test.filter)
+
+			if err != nil || !reflect.DeepEqual(ids(weights), test.want_ids) {
+				t.Errorf("test: %v failed. got: %v, %v, wanted: %v", test.name, ids(weights), err, test.want_ids)
+			}
+		})
+	}
+
+	if deleted, err := storage.DeleteWeightEntry(ctx, created[0]); err != nil || deleted != created[0] {
+		t.Errorf("got: %v, %v, wanted: %v, nil", deleted, err, created[0])
+	}
+
+	if _, err = storage.GetWeight(ctx, created[0]); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("getting a deleted weight entry: got: %v, wanted not found", err)
+	}
+}
+
+func testStorageMeasurements(t *testing.T, storage repository.Storage) {
+	ctx := context.Background()
+	userID, _ := storage.CreateUser(ctx, newMemberRequest("rabbit@email.com"))
+	day := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	waist, hip := 80.456, 100.0
+
+	first, err := storage.CreateMeasurement(ctx, api.Measurement{UserID: userID, MeasuredAt: day.Add(24 * time.Hour), Waist: &waist, Hip: &hip})
+
+	if err != nil || first.Waist == nil || *first.Waist != 80.46 || first.Neck != nil || first.UpdatedAt != nil {
+		t.Fatalf("got: %+v, %v, wanted the waist rounded to 80.46 and no neck", first, err)
+	}
+
+	second, err := storage.CreateMeasurement(ctx, api.Measurement{UserID: userID, MeasuredAt: day, Hip: &hip})
+
+	if err != nil {
+		t.Fatalf("could not create a measurement: %v", err)
+	}
+
+	if _, err = storage.CreateMeasurement(ctx, api.Measurement{UserID: userID + 100, MeasuredAt: day}); err == nil {
+		t.Errorf("creating a measurement for a user that does not exist should fail")
+	}
+
+	// an update replaces every value
+	first.Waist = nil
+	updated, err := storage.UpdateMeasurement(ctx, first)
+
+	if err != nil || updated.Waist != nil || updated.Hip == nil || updated.UpdatedAt == nil || !updated.MeasuredAt.Equal(first.MeasuredAt) {
+		t.Errorf("got: %+v, %v, wanted the waist removed", updated, err)
+	}
+
+	if _, err = storage.UpdateMeasurement(ctx, api.Measurement{ID: second.ID + 100}); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("updating a measurement that does not exist: got: %v, wanted not found", err)
+	}
+
+	measurements, err := storage.GetMeasurements(ctx, api.MeasurementFilter{UserID: userID, Descending: true, Limit: 1})
+
+	if err != nil || len(measurements) != 1 || measurements[0].ID != first.ID {
+		t.Errorf("got: %+v, %v, wanted only measurement %v", measurements, err, first.ID)
+	}
+
+	measurements, err = storage.GetMeasurements(ctx, api.MeasurementFilter{UserID: userID, To: &day})
+
+	if err != nil || len(measurements) != 1 || measurements[0].ID != second.ID {
+		t.Errorf("got: %+v, %v, wanted only measurement %v", measurements, err, second.ID)
+	}
+
+	if deleted, err := storage.DeleteMeasurement(ctx, second.ID); err != nil || deleted != second.ID {
+		t.Errorf("got: %v, %v, wanted: %v, nil", deleted, err, second.ID)
+	}
+
+	if deleted, err := storage.DeleteMeasurement(ctx, second.ID); err != nil || deleted != 0 {
+		t.Errorf("deleting a measurement twice: got: %v, %v, wanted: 0, nil", deleted, err)
+	}
+
+	if _, err = storage.GetMeasurement(ctx, second.ID); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("getting a deleted measurement: got: %v, wanted not found", err)
+	}
+
+	// purging the user removes its measurements
+	if _, err = storage.DeleteUser(ctx, userID, api.AnyVersion); err != nil {
+		t.Fatalf("could not delete the user: %v", err)
+	}
+
+	if _, err = storage.PurgeUsers(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("could not purge the user: %v", err)
+	}
+
+	if _, err = storage.GetMeasurement(ctx, first.ID); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("getting a measurement of a purged user: got: %v, wanted not found", err)
+	}
+}
+
+func testStorageGoalsAndClients(t *testing.T, storage repository.Storage) {
+	ctx := context.Background()
+	coachID, _ := storage.CreateUser(ctx, newMemberRequest("coach@email.com"))
+	clientID, _ := storage.CreateUser(ctx, newMemberRequest("client@email.com"))
+
+	goal, err := storage.CreateGoal(ctx, api.Goal{UserID: clientID, StartWeight: 90, TargetWeight: 80})
+
+	if err != nil || goal.ID == 0 {
+		t.Fatalf("got: %+v, %v, wanted a goal", goal, err)
+	}
+
+	if _, err = storage.CreateGoal(ctx, api.Goal{UserID: clientID, StartWeight: 90, TargetWeight: 85}); !errors.Is(err, api.ErrConflict) {
+		t.Errorf("creating a second goal: got: %v, wanted a conflict", err)
+	}
+
+	if _, err = storage.UpdateGoal(ctx, api.Goal{ID: goal.ID + 100, StartWeight: 90, TargetWeight: 85}); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("updating a goal that does not exist: got: %v, wanted not found", err)
+	}
+
+	if err = storage.AddClient(ctx, coachID, clientID); err != nil {
+		t.Fatalf("could not add a client: %v", err)
+	}
+
+	if err = storage.AddClient(ctx, coachID, clientID); !errors.Is(err, api.ErrConflict) {
+		t.Errorf("adding a client twice: got: %v, wanted a conflict", err)
+	}
+
+	if err = storage.AddClient(ctx, coachID, clientID+100); err == nil {
+		t.Errorf("adding a client that does not exist should fail")
+	}
+
+	if isCoach, err := storage.IsCoachOf(ctx, coachID, clientID); err != nil || !isCoach {
+		t.Errorf("got: %v, %v, wanted: true, nil", isCoach, err)
+	}
+
+	clients, err := storage.GetClients(ctx, coachID)
+
+	if err != nil || len(clients) != 1 || clients[0].ID != clientID {
+		t.Errorf("got: %+v, %v, wanted only client %v", clients, err, clientID)
+	}
+
+	// purging the client removes its goal and its coach
+	if _, err = storage.DeleteUser(ctx, clientID, api.AnyVersion); err != nil {
+		t.Fatalf("could not delete the client: %v", err)
+	}
+
+	if purged, err := storage.PurgeUsers(ctx, time.Now().Add(time.Minute)); err != nil || purged != 1 {
+		t.Errorf("got: %v, %v, wanted: 1, nil", purged, err)
+	}
+
+	if isCoach, err := storage.IsCoachOf(ctx, coachID, clientID); err != nil || isCoach {
+		t.Errorf("got: %v, %v, wanted: false, nil", isCoach, err)
+	}
+
+	if goal, err = storage.GetGoal(ctx, clientID); err != nil || goal.ID != 0 {
+		t.Errorf("got: %+v, %v, wanted no goal", goal, err)
+	}
+}
+
+func testStorageWithTx(t *testing.T, storage repository.Storage) {
+	ctx := context.Background()
+	failed := errors.New("failed")
+
+	err := storage.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := storage.CreateUser(ctx, newMemberRequest("rabbit@email.com")); err != nil {
+			return err
+		}
+
+		return failed
+	})
+
+	if err != failed {
+		t.Errorf("got: %v, wanted: %v", err, failed)
+	}
+
+	// the user is rolled back, so its email is free again
+	if user, err := storage.GetUserByEmail(ctx, "rabbit@email.com"); err != nil || user.ID != 0 {
+		t.Errorf("got: %+v, %v, wanted no user", user, err)
+	}
+
+	if _, err = storage.CreateUser(ctx, newMemberRequest("rabbit@email.com")); err != nil {
+		t.Errorf("got: %v, wanted: nil", err)
+	}
+}
+
+func testStorageConcurrentWrites(t *testing.T, storage repository.Storage) {
+	ctx := context.Background()
+	userID, _ := storage.CreateUser(ctx, newMemberRequest("rabbit@email.com"))
+
+	var wg sync.WaitGroup
+
+	// every read-modify-write in a transaction sees the write before it
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			err := storage.WithTx(ctx, func(ctx context.Context) error {
+				user, err := storage.GetUser(ctx, userID)
+
+				if err != nil {
+					return err
+				}
+
+				_, err = storage.PatchUser(ctx, userID, user.Version, api.UserChanges{"height": user.Height + 1})
+
+				return err
+			})
+
+			if err != nil {
+				t.Errorf("could not patch the user: %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	user, err := storage.GetUser(ctx, userID)
+
+	if err != nil || user.Height != 220 || user.Version != 51 {
+		t.Errorf("got: height %v, version %v, %v, wanted: height 220, version 51", user.Height, user.Version, err)
+	}
+}